@@ -388,7 +388,7 @@ func handleConnection(conn net.Conn, spaceManager *spaces.SpaceManager, authMana
 
 		// Enforce role-based access
 		switch strings.ToUpper(query.Type) {
-		case "CREATE_SPACE", "LIST_SPACES":
+		case "CREATE_SPACE", "LIST_SPACES", "CREATE_INDEX", "MIGRATE_INDEX", "IMPORT_VECTORS":
 			if user.Role != auth.RoleAdmin {
 				fmt.Fprintf(conn, `{"status":"ERROR","message":"admin access required"}`+"\n")
 				continue
@@ -398,19 +398,24 @@ func handleConnection(conn net.Conn, spaceManager *spaces.SpaceManager, authMana
 				fmt.Fprintf(conn, `{"status":"ERROR","message":"write permission denied"}`+"\n")
 				continue
 			}
-		case "GET":
+		case "INSERT_DOCUMENT", "UPDATE_DOCUMENT", "DELETE_DOCUMENT", "INSERT_POINT", "INDEX_DOC", "DELETE_DOC", "INSERT_SPARSE":
+			if !(user.Role == auth.RoleAdmin || authManager.HasRole(user, query.Space, auth.RoleWrite)) {
+				fmt.Fprintf(conn, `{"status":"ERROR","message":"write permission denied"}`+"\n")
+				continue
+			}
+		case "GET", "FIND", "GET_DOCUMENT", "FIND_DOCUMENTS", "QUERY_SERIES", "SEARCH_TEXT", "SEARCH_SPARSE":
 			if !(authManager.HasRole(user, query.Space, auth.RoleRead) ||
 				authManager.HasRole(user, query.Space, auth.RoleWrite)) {
 				fmt.Fprintf(conn, `{"status":"ERROR","message":"read permission denied"}`+"\n")
 				continue
 			}
 		// Vector engine access checks
-		case "INSERT_VECTOR":
+		case "INSERT_VECTOR", "INSERT_VECTORS", "COMPACT_SPACE", "REBUILD_INDEX", "UPSERT_VECTOR", "INSERT_VECTOR_IF_ABSENT", "UPDATE_VECTOR", "UPDATE_PAYLOAD":
 			if !(user.Role == auth.RoleAdmin || authManager.HasRole(user, query.Space, auth.RoleWrite)) {
 				fmt.Fprintf(conn, `{"status":"ERROR","message":"write permission denied"}`+"\n")
 				continue
 			}
-		case "SEARCH_TOPK", "GET_VECTOR", "RANGE_SEARCH":
+		case "SEARCH_TOPK", "GET_VECTOR", "RANGE_SEARCH", "HYBRID_SEARCH", "GET_PAYLOAD", "SEARCH_TOPK_BATCH", "SEARCH_BY_ID", "EVAL_RECALL", "DESCRIBE_SPACE", "LIST_VECTOR_IDS", "EXPORT_VECTORS":
 			if !(user.Role == auth.RoleAdmin || authManager.HasRole(user, query.Space, auth.RoleRead) || authManager.HasRole(user, query.Space, auth.RoleWrite)) {
 				fmt.Fprintf(conn, `{"status":"ERROR","message":"read permission denied"}`+"\n")
 				continue
@@ -0,0 +1,339 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/shibudb.org/shibudb-server/internal/jsonpath"
+)
+
+// Expr is a parsed filter expression evaluated against decoded JSON documents.
+//
+// Filters are written as JSON objects:
+//
+//	{"eq": {"status": "active"}}
+//	{"ne": {"status": "deleted"}}
+//	{"gt": {"age": 30}}, {"gte": ...}, {"lt": ...}, {"lte": ...}
+//	{"in": {"status": ["active", "pending"]}}
+//	{"exists": "email"}
+//	{"and": [<filter>, ...]}, {"or": [<filter>, ...]}, {"not": <filter>}
+//
+// An object with several keys, or an operator with several fields, is the
+// conjunction of its parts. Field names are dotted paths (see jsonpath).
+type Expr interface {
+	Match(doc interface{}) bool
+}
+
+// Compare tests a single field against a value with eq, ne, gt, gte, lt or lte.
+type Compare struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+// In matches when the field equals any of Values.
+type In struct {
+	Path   string
+	Values []interface{}
+}
+
+// Exists matches when the field is present (including null).
+type Exists struct {
+	Path string
+}
+
+// And matches when every child matches.
+type And []Expr
+
+// Or matches when any child matches.
+type Or []Expr
+
+// Not inverts its child.
+type Not struct {
+	Expr Expr
+}
+
+// Parse decodes a filter expression. An empty string yields nil, which
+// callers should treat as "match everything".
+func Parse(s string) (Expr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return parseNode(raw)
+}
+
+func parseNode(raw interface{}) (Expr, error) {
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid filter: expected an object")
+	}
+	if len(obj) == 0 {
+		return nil, errors.New("invalid filter: empty object")
+	}
+
+	// Iterate operators in a stable order so errors are deterministic
+	ops := make([]string, 0, len(obj))
+	for op := range obj {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	var parts And
+	for _, op := range ops {
+		arg := obj[op]
+		switch op {
+		case "and", "or":
+			list, ok := arg.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("invalid filter: %q expects a non-empty array", op)
+			}
+			children := make([]Expr, 0, len(list))
+			for _, item := range list {
+				child, err := parseNode(item)
+				if err != nil {
+					return nil, err
+				}
+				children = append(children, child)
+			}
+			if op == "and" {
+				parts = append(parts, And(children))
+			} else {
+				parts = append(parts, Or(children))
+			}
+		case "not":
+			child, err := parseNode(arg)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, Not{Expr: child})
+		case "exists":
+			switch a := arg.(type) {
+			case string:
+				parts = append(parts, Exists{Path: a})
+			case []interface{}:
+				for _, item := range a {
+					path, ok := item.(string)
+					if !ok {
+						return nil, errors.New(`invalid filter: "exists" expects field names`)
+					}
+					parts = append(parts, Exists{Path: path})
+				}
+			default:
+				return nil, errors.New(`invalid filter: "exists" expects a field name`)
+			}
+		case "eq", "ne", "gt", "gte", "lt", "lte", "in":
+			fields, ok := arg.(map[string]interface{})
+			if !ok || len(fields) == 0 {
+				return nil, fmt.Errorf("invalid filter: %q expects an object of field/value pairs", op)
+			}
+			paths := make([]string, 0, len(fields))
+			for path := range fields {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				if !jsonpath.Valid(path) {
+					return nil, fmt.Errorf("invalid filter: bad field %q", path)
+				}
+				value := normalize(fields[path])
+				if op == "in" {
+					list, ok := value.([]interface{})
+					if !ok {
+						return nil, fmt.Errorf("invalid filter: \"in\" on %q expects an array", path)
+					}
+					parts = append(parts, In{Path: path, Values: list})
+					continue
+				}
+				parts = append(parts, Compare{Op: op, Path: path, Value: value})
+			}
+		default:
+			return nil, fmt.Errorf("invalid filter: unknown operator %q", op)
+		}
+	}
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return parts, nil
+}
+
+func (c Compare) Match(doc interface{}) bool {
+	field, found := jsonpath.Lookup(doc, c.Path)
+	if c.Op == "ne" {
+		return !found || !matchesValue(normalize(field), c.Value)
+	}
+	if !found {
+		return false
+	}
+	field = normalize(field)
+	if c.Op == "eq" {
+		return matchesValue(field, c.Value)
+	}
+
+	// Ordered comparisons apply element-wise to arrays
+	if arr, ok := field.([]interface{}); ok {
+		for _, elem := range arr {
+			if compareOrdered(c.Op, elem, c.Value) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareOrdered(c.Op, field, c.Value)
+}
+
+func (in In) Match(doc interface{}) bool {
+	field, found := jsonpath.Lookup(doc, in.Path)
+	if !found {
+		return false
+	}
+	field = normalize(field)
+	for _, v := range in.Values {
+		if matchesValue(field, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Exists) Match(doc interface{}) bool {
+	_, found := jsonpath.Lookup(doc, e.Path)
+	return found
+}
+
+func (a And) Match(doc interface{}) bool {
+	for _, child := range a {
+		if !child.Match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o Or) Match(doc interface{}) bool {
+	for _, child := range o {
+		if child.Match(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n Not) Match(doc interface{}) bool {
+	return !n.Expr.Match(doc)
+}
+
+// matchesValue reports equality, treating an array field as matching when any
+// element equals a scalar value.
+func matchesValue(field, value interface{}) bool {
+	if equal(field, value) {
+		return true
+	}
+	if arr, ok := field.([]interface{}); ok {
+		if _, valueIsArr := value.([]interface{}); !valueIsArr {
+			for _, elem := range arr {
+				if equal(elem, value) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	af, aNum := toFloat(a)
+	bf, bNum := toFloat(b)
+	if aNum || bNum {
+		return aNum && bNum && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compareOrdered(op string, a, b interface{}) bool {
+	var cmp int
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		if !ok {
+			return false
+		}
+		switch {
+		case af < bf:
+			cmp = -1
+		case af > bf:
+			cmp = 1
+		}
+	} else if as, ok := a.(string); ok {
+		bs, ok := b.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(as, bs)
+	} else {
+		return false
+	}
+
+	switch op {
+	case "gt":
+		return cmp > 0
+	case "gte":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "lte":
+		return cmp <= 0
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// normalize converts json.Number values (recursively) to float64 so that
+// documents decoded with or without UseNumber compare the same way.
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val.String()
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, elem := range val {
+			out[i] = normalize(elem)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			out[k] = normalize(elem)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAndMatch(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"status":"active","age":34,"tags":["go","db"],"profile":{"email":"a@b.c"},"score":null}`), &doc)
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`{"eq":{"status":"active"}}`, true},
+		{`{"eq":{"status":"inactive"}}`, false},
+		{`{"ne":{"status":"inactive"}}`, true},
+		{`{"ne":{"missing":1}}`, true},
+		{`{"gt":{"age":30}}`, true},
+		{`{"lt":{"age":30}}`, false},
+		{`{"gte":{"age":34}}`, true},
+		{`{"lte":{"age":33.5}}`, false},
+		{`{"gt":{"status":"a"}}`, true},
+		{`{"gt":{"status":1}}`, false},
+		{`{"in":{"status":["pending","active"]}}`, true},
+		{`{"in":{"age":[1,2]}}`, false},
+		{`{"eq":{"tags":"db"}}`, true},
+		{`{"exists":"profile.email"}`, true},
+		{`{"exists":"score"}`, true},
+		{`{"exists":"profile.phone"}`, false},
+		{`{"and":[{"eq":{"status":"active"}},{"gt":{"age":40}}]}`, false},
+		{`{"or":[{"eq":{"status":"x"}},{"gt":{"age":30}}]}`, true},
+		{`{"not":{"eq":{"status":"active"}}}`, false},
+		{`{"eq":{"status":"active"},"lt":{"age":35}}`, true},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.filter)
+		if err != nil {
+			t.Errorf("Parse(%s) failed: %v", tt.filter, err)
+			continue
+		}
+		if got := expr.Match(doc); got != tt.want {
+			t.Errorf("Match(%s) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		`[]`,
+		`{}`,
+		`{"between":{"a":1}}`,
+		`{"and":[]}`,
+		`{"in":{"a":1}}`,
+		`{"eq":{"a..b":1}}`,
+		`{"exists":3}`,
+		`not json`,
+	}
+	for _, f := range invalid {
+		if _, err := Parse(f); err == nil {
+			t.Errorf("Parse(%s) should fail", f)
+		}
+	}
+
+	expr, err := Parse("  ")
+	if err != nil || expr != nil {
+		t.Errorf("Parse of empty filter should return nil, nil; got %v, %v", expr, err)
+	}
+}
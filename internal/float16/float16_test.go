@@ -0,0 +1,32 @@
+package float16
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		in   float32
+		bits uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},                         // largest half
+		{1e6, 0x7c00},                           // overflow to +Inf
+		{float32(math.Pow(2, -24)), 0x0001},     // smallest subnormal
+		{float32(1 + math.Pow(2, -11)), 0x3c00}, // halfway, rounds to even
+	}
+	for _, tt := range tests {
+		if got := FromFloat32(tt.in); got != tt.bits {
+			t.Errorf("FromFloat32(%v) = %#04x, want %#04x", tt.in, got, tt.bits)
+		}
+	}
+	for _, h := range []uint16{0x0000, 0x3c00, 0xc000, 0x3555, 0x7bff, 0x0001, 0x03ff} {
+		if got := FromFloat32(ToFloat32(h)); got != h {
+			t.Errorf("round trip of %#04x gave %#04x", h, got)
+		}
+	}
+}
@@ -15,7 +15,8 @@ type BTreeIndex struct {
 	btree       *btree.BTree
 	file        *os.File
 	mmapData    []byte
-	writeOffset int // Track where to write next
+	writeOffset int  // Track where to write next
+	stale       bool // File still holds entries removed by Discard
 }
 
 type Item struct {
@@ -100,6 +101,46 @@ func (idx *BTreeIndex) Get(key string) (int64, bool) {
 	return item.(Item).Value, true
 }
 
+// AddBatch inserts many entries and syncs the mapped file once, which keeps
+// bulk builds (e.g. secondary index rebuilds) from paying an msync per key.
+func (idx *BTreeIndex) AddBatch(items []Item) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	for _, item := range items {
+		idx.btree.ReplaceOrInsert(item)
+		if err := idx.writeIndexEntry(item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	idx.mmapLock.Lock()
+	defer idx.mmapLock.Unlock()
+	return unix.Msync(idx.mmapData, unix.MS_SYNC)
+}
+
+// Ascend calls fn for every entry in key order until fn returns false.
+func (idx *BTreeIndex) Ascend(fn func(key string, pos int64) bool) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	idx.btree.Ascend(func(i btree.Item) bool {
+		item := i.(Item)
+		return fn(item.Key, item.Value)
+	})
+}
+
+// AscendRange calls fn for every entry with greaterOrEqual <= key < lessThan,
+// in key order, until fn returns false.
+func (idx *BTreeIndex) AscendRange(greaterOrEqual, lessThan string, fn func(key string, pos int64) bool) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	idx.btree.AscendRange(Item{Key: greaterOrEqual}, Item{Key: lessThan}, func(i btree.Item) bool {
+		item := i.(Item)
+		return fn(item.Key, item.Value)
+	})
+}
+
 func (idx *BTreeIndex) Remove(key string) error {
 	idx.lock.Lock()
 	defer idx.lock.Unlock()
@@ -111,6 +152,30 @@ func (idx *BTreeIndex) Remove(key string) error {
 	return idx.persistIndex()
 }
 
+// Discard removes key from the tree without rewriting the file, which
+// keeps the entry until the next Compact. Callers that reload the file must
+// Compact first (e.g. before a clean close).
+func (idx *BTreeIndex) Discard(key string) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if idx.btree.Delete(Item{Key: key}) != nil {
+		idx.stale = true
+	}
+}
+
+// Compact rewrites the file from the tree if entries were discarded since
+// the last rewrite.
+func (idx *BTreeIndex) Compact() error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if !idx.stale {
+		return nil
+	}
+	return idx.persistIndex()
+}
+
 func (idx *BTreeIndex) persistIndex() error {
 	if err := syscall.Munmap(idx.mmapData); err != nil {
 		return err
@@ -128,16 +193,35 @@ func (idx *BTreeIndex) persistIndex() error {
 	}
 	idx.mmapData = mmapData
 	idx.writeOffset = 0
+	idx.stale = false
 
 	idx.btree.Ascend(func(i btree.Item) bool {
 		item := i.(Item)
-		_ = idx.appendIndexEntry(item.Key, item.Value)
+		_ = idx.writeIndexEntry(item.Key, item.Value)
 		return true
 	})
 	return unix.Msync(idx.mmapData, unix.MS_SYNC)
 }
 
 func (idx *BTreeIndex) appendIndexEntry(key string, pos int64) error {
+	if err := idx.writeIndexEntry(key, pos); err != nil {
+		return err
+	}
+
+	idx.mmapLock.Lock()
+	defer idx.mmapLock.Unlock()
+
+	// Optional: sync to make data visible to all threads immediately
+	if err := unix.Msync(idx.mmapData, unix.MS_SYNC); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeIndexEntry copies one entry into the mapped file, growing it as needed.
+// Callers are responsible for syncing the mapping.
+func (idx *BTreeIndex) writeIndexEntry(key string, pos int64) error {
 	keyBytes := []byte(key)
 	keySize := uint32(len(keyBytes))
 	entrySize := 8 + len(keyBytes)
@@ -168,12 +252,6 @@ func (idx *BTreeIndex) appendIndexEntry(key string, pos int64) error {
 	copy(idx.mmapData[offset+8:offset+8+int(keySize)], keyBytes)
 
 	idx.writeOffset += entrySize
-
-	// Optional: sync to make data visible to all threads immediately
-	if err := unix.Msync(idx.mmapData, unix.MS_SYNC); err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -197,3 +197,78 @@ func TestBTreeIndex(t *testing.T) {
 		}
 	})
 }
+
+func TestBTreeIndexAscendRange(t *testing.T) {
+	os.Remove("test_index_range.dat")
+	defer os.Remove("test_index_range.dat")
+
+	idx, err := NewBTreeIndex("test_index_range.dat")
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	defer idx.Close()
+
+	err = idx.AddBatch([]Item{
+		{Key: "a", Value: 1},
+		{Key: "b", Value: 2},
+		{Key: "c", Value: 3},
+		{Key: "d", Value: 4},
+	})
+	if err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+
+	var keys []string
+	idx.AscendRange("b", "d", func(key string, pos int64) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "c" {
+		t.Errorf("Expected [b c], got %v", keys)
+	}
+
+	count := 0
+	idx.Ascend(func(key string, pos int64) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Errorf("Expected Ascend to stop after 2 entries, got %d", count)
+	}
+
+	if pos, found := idx.Get("d"); !found || pos != 4 {
+		t.Errorf("Expected position 4 for d, got %d", pos)
+	}
+}
+
+func TestBTreeIndexDiscard(t *testing.T) {
+	os.Remove("test_discard_index.dat")
+	defer os.Remove("test_discard_index.dat")
+
+	idx, err := NewBTreeIndex("test_discard_index.dat")
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	idx.Add("key1", 100)
+	idx.Add("key2", 200)
+	idx.Discard("key1")
+	if _, found := idx.Get("key1"); found {
+		t.Errorf("Expected key1 to be discarded")
+	}
+	if err := idx.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	idx.Close()
+
+	idx, err = NewBTreeIndex("test_discard_index.dat")
+	if err != nil {
+		t.Fatalf("Failed to reopen index: %v", err)
+	}
+	defer idx.Close()
+	if _, found := idx.Get("key1"); found {
+		t.Errorf("Expected key1 to stay removed after Compact")
+	}
+	if pos, found := idx.Get("key2"); !found || pos != 200 {
+		t.Errorf("Expected position 200 for key2, got %d", pos)
+	}
+}
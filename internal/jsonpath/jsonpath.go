@@ -0,0 +1,48 @@
+package jsonpath
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lookup resolves a dotted path such as "user.address.city" or "tags.0"
+// against a document decoded with encoding/json. Numeric segments index into
+// arrays. The second return value is false when any segment is missing.
+func Lookup(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+	cur := doc
+	for _, seg := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			next, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			cur = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// Valid reports whether path is a well-formed dotted path (no empty segments).
+func Valid(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, seg := range strings.Split(path, ".") {
+		if strings.TrimSpace(seg) == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"user":{"name":"ana","tags":["x","y"]},"n":3}`), &doc)
+
+	tests := []struct {
+		path  string
+		want  interface{}
+		found bool
+	}{
+		{"n", float64(3), true},
+		{"user.name", "ana", true},
+		{"user.tags.1", "y", true},
+		{"user.tags.2", nil, false},
+		{"user.missing", nil, false},
+		{"n.deeper", nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		got, found := Lookup(doc, tt.path)
+		if found != tt.found || (found && got != tt.want) {
+			t.Errorf("Lookup(%q) = %v, %v; want %v, %v", tt.path, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	if !Valid("a.b") || Valid("") || Valid("a..b") || Valid(".a") {
+		t.Errorf("Valid returned unexpected results")
+	}
+}
@@ -18,21 +18,147 @@ const (
 	TypeSearchTopK            = "SEARCH_TOPK"
 	TypeGetVector             = "GET_VECTOR"
 	TypeRangeSearch           = "RANGE_SEARCH"
+	TypeCreateIndex           = "CREATE_INDEX"
+	TypeFind                  = "FIND"
+	TypeInsertDocument        = "INSERT_DOCUMENT"
+	TypeGetDocument           = "GET_DOCUMENT"
+	TypeUpdateDocument        = "UPDATE_DOCUMENT"
+	TypeDeleteDocument        = "DELETE_DOCUMENT"
+	TypeFindDocuments         = "FIND_DOCUMENTS"
+	TypeInsertPoint           = "INSERT_POINT"
+	TypeQuerySeries           = "QUERY_SERIES"
+	TypeIndexDoc              = "INDEX_DOC"
+	TypeDeleteDoc             = "DELETE_DOC"
+	TypeSearchText            = "SEARCH_TEXT"
+	TypeHybridSearch          = "HYBRID_SEARCH"
+	TypeGetPayload            = "GET_PAYLOAD"
+	TypeInsertVectors         = "INSERT_VECTORS"
+	TypeSearchTopKBatch       = "SEARCH_TOPK_BATCH"
+	TypeCompactSpace          = "COMPACT_SPACE"
+	TypeRebuildIndex          = "REBUILD_INDEX"
+	TypeMigrateIndex          = "MIGRATE_INDEX"
+	TypeSearchByID            = "SEARCH_BY_ID"
+	TypeInsertSparse          = "INSERT_SPARSE"
+	TypeSearchSparse          = "SEARCH_SPARSE"
+	TypeImportVectors         = "IMPORT_VECTORS"
+	TypeEvalRecall            = "EVAL_RECALL"
+	TypeDescribeSpace         = "DESCRIBE_SPACE"
+	TypeListVectorIDs         = "LIST_VECTOR_IDS"
+	TypeExportVectors         = "EXPORT_VECTORS"
+	TypeUpsertVector          = "UPSERT_VECTOR"
+	TypeInsertVectorIfAbsent  = "INSERT_VECTOR_IF_ABSENT"
+	TypeUpdateVector          = "UPDATE_VECTOR"
+	TypeUpdatePayload         = "UPDATE_PAYLOAD"
 )
 
 type Query struct {
-	Type       string  `json:"type"`
-	Key        string  `json:"key,omitempty"`
-	Value      string  `json:"value,omitempty"`
-	Space      string  `json:"space,omitempty"`
-	User       string  `json:"user,omitempty"`
-	Data       string  `json:"data,omitempty"`
-	NewUser    *User   `json:"new_user,omitempty"`
-	DeleteUser *User   `json:"delete_user,omitempty"`
-	EngineType string  `json:"engine_type,omitempty"`
-	Dimension  int     `json:"dimension,omitempty"`
-	IndexType  string  `json:"index_type,omitempty"`
-	Metric     string  `json:"metric,omitempty"`
-	Radius     float32 `json:"radius,omitempty"`
-	EnableWAL  bool    `json:"enable_wal,omitempty"`
+	Type       string   `json:"type"`
+	Key        string   `json:"key,omitempty"`
+	Value      string   `json:"value,omitempty"`
+	Space      string   `json:"space,omitempty"`
+	User       string   `json:"user,omitempty"`
+	Data       string   `json:"data,omitempty"`
+	NewUser    *User    `json:"new_user,omitempty"`
+	DeleteUser *User    `json:"delete_user,omitempty"`
+	EngineType string   `json:"engine_type,omitempty"`
+	Dimension  int      `json:"dimension,omitempty"`
+	IndexType  string   `json:"index_type,omitempty"`
+	Metric     string   `json:"metric,omitempty"`
+	Radius     float32  `json:"radius,omitempty"`
+	EnableWAL  bool     `json:"enable_wal,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	Min        string   `json:"min,omitempty"`
+	Max        string   `json:"max,omitempty"`
+	Equal      bool     `json:"equal,omitempty"`
+	Filter     string   `json:"filter,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
+	Limit      int      `json:"limit,omitempty"`
+
+	// Time-series fields; timestamps and step are Unix milliseconds
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	Start         int64             `json:"start,omitempty"`
+	End           int64             `json:"end,omitempty"`
+	Step          int64             `json:"step,omitempty"`
+	Aggregation   string            `json:"aggregation,omitempty"`
+	Retention     string            `json:"retention,omitempty"`
+	PartitionSize string            `json:"partition_size,omitempty"`
+
+	// Text analyzer for new text spaces
+	Analyzer string `json:"analyzer,omitempty"`
+
+	// Vector text and hybrid search options
+	Text   string   `json:"text,omitempty"`
+	Fusion string   `json:"fusion,omitempty"`
+	Alpha  *float64 `json:"alpha,omitempty"`
+
+	// JSON object stored with a vector; SEARCH_TOPK and RANGE_SEARCH can
+	// filter on it through Filter. For UPDATE_PAYLOAD, a JSON merge patch
+	Payload string `json:"payload,omitempty"`
+
+	// Parent document of a vector; SEARCH_TOPK with GroupBy returns the top
+	// k distinct parents
+	Parent  string `json:"parent,omitempty"`
+	GroupBy bool   `json:"group_by,omitempty"`
+
+	// Batch vector operations
+	Vectors []VectorRecord `json:"vectors,omitempty"`
+	Queries [][]float32    `json:"queries,omitempty"`
+
+	// Stored vector IDs to search like (and unlike) for SEARCH_BY_ID
+	Positive []int64 `json:"positive,omitempty"`
+	Negative []int64 `json:"negative,omitempty"`
+
+	// FAISS search parameters such as nprobe or efSearch: per query for
+	// searches, space defaults for CREATE_SPACE
+	SearchParams map[string]float64 `json:"search_params,omitempty"`
+
+	// Encoding of a new vector space's data file: float32, float16 or int8
+	VectorEncoding string `json:"vector_encoding,omitempty"`
+
+	// Rerank SEARCH_TOPK results by exact distance over Rerank times k
+	// approximate candidates
+	Rerank int `json:"rerank,omitempty"`
+
+	// Maximal marginal relevance lambda for diverse SEARCH_TOPK results:
+	// 1 ranks by relevance only, 0 by diversity only
+	Diversity *float64 `json:"diversity,omitempty"`
+
+	// IMPORT_VECTORS reads Path on the server in this format (fvecs, bvecs,
+	// npy or jsonl; empty means by extension), numbering rows of files
+	// without IDs from FirstID, in batches of BatchSize vectors
+	Format    string `json:"format,omitempty"`
+	FirstID   int64  `json:"first_id,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
+
+	// EVAL_RECALL search parameter settings to compare; empty means a sweep
+	// suited to the index type. The query set is Queries, or Limit stored
+	// vectors sampled at random when Queries is empty
+	SearchSettings []map[string]float64 `json:"search_settings,omitempty"`
+
+	// LIST_VECTOR_IDS and EXPORT_VECTORS page after Cursor, the next_cursor
+	// of the previous page, with Limit vectors per page and Min and Max as
+	// an optional inclusive ID range
+	Cursor string `json:"cursor,omitempty"`
+
+	// Sparse vector for INSERT_SPARSE and SEARCH_SPARSE
+	Sparse *SparseVector `json:"sparse,omitempty"`
+}
+
+// SparseVector is a sparse embedding given as parallel lists of dimension
+// indices and their weights.
+type SparseVector struct {
+	Indices []uint32  `json:"indices"`
+	Values  []float32 `json:"values"`
+}
+
+// VectorRecord is one vector of an INSERT_VECTORS batch, identified by ID
+// or, when set, by a string Key such as a UUID.
+type VectorRecord struct {
+	ID      int64     `json:"id"`
+	Key     string    `json:"key,omitempty"`
+	Vector  []float32 `json:"vector"`
+	Text    string    `json:"text,omitempty"`
+	Payload string    `json:"payload,omitempty"`
+	Parent  string    `json:"parent,omitempty"`
 }
@@ -1,15 +1,20 @@
 package queryengine
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/shibudb.org/shibudb-server/internal/auth"
+	"github.com/shibudb.org/shibudb-server/internal/filter"
 	"github.com/shibudb.org/shibudb-server/internal/models"
 	"github.com/shibudb.org/shibudb-server/internal/spaces"
 	"github.com/shibudb.org/shibudb-server/internal/storage"
+	"github.com/shibudb.org/shibudb-server/internal/vectorio"
 )
 
 // Add this interface above QueryEngine
@@ -189,7 +194,17 @@ func (qe *QueryEngine) Execute(query models.Query) (string, error) {
 			}
 		}
 
-		_, err = qe.spaceManager.CreateSpaceWithWAL(query.Space, query.EngineType, query.Dimension, indexType, metric, query.EnableWAL)
+		if query.EngineType == "timeseries" {
+			_, err = qe.spaceManager.CreateTimeSeriesSpace(query.Space, query.Retention, query.PartitionSize, query.EnableWAL)
+		} else if query.EngineType == "text" {
+			_, err = qe.spaceManager.CreateTextSpace(query.Space, query.Analyzer, query.EnableWAL)
+		} else if query.EngineType == "binary-vector" {
+			_, err = qe.spaceManager.CreateBinaryVectorSpace(query.Space, query.Dimension, query.IndexType, query.Metric, query.EnableWAL, query.SearchParams)
+		} else if query.EngineType == "vector" {
+			_, err = qe.spaceManager.CreateVectorSpace(query.Space, query.Dimension, indexType, metric, query.EnableWAL, query.SearchParams, query.VectorEncoding)
+		} else {
+			_, err = qe.spaceManager.CreateSpaceWithWAL(query.Space, query.EngineType, query.Dimension, indexType, metric, query.EnableWAL)
+		}
 		if err != nil {
 			return "", err
 		}
@@ -247,11 +262,249 @@ func (qe *QueryEngine) Execute(query models.Query) (string, error) {
 			}
 			return "DELETED", nil
 		}
+	case models.TypeCreateIndex:
+		if query.Space == "" {
+			return "", errors.New("no table selected")
+		}
+		admin, err := qe.authManager.GetUser(query.User)
+		if err != nil || admin.Role != auth.RoleAdmin {
+			return "", errors.New("only admin can create indexes")
+		}
+		if query.Path == "" {
+			return "", errors.New("json path required")
+		}
+		if err := qe.spaceManager.CreateIndex(query.Space, query.Path); err != nil {
+			return "", err
+		}
+		return "INDEX_CREATED", nil
+	case models.TypeMigrateIndex:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		admin, err := qe.authManager.GetUser(query.User)
+		if err != nil || admin.Role != auth.RoleAdmin {
+			return "", errors.New("only admin can migrate indexes")
+		}
+		if query.IndexType == "" && query.Metric == "" {
+			return "", errors.New("index type or metric required")
+		}
+		if err := qe.spaceManager.MigrateIndex(query.Space, query.IndexType, query.Metric); err != nil {
+			return "", err
+		}
+		return "INDEX_MIGRATION_STARTED", nil
+	case models.TypeFind:
+		if query.Space == "" {
+			return "", errors.New("no table selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("table space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "key-value" {
+			return "", errors.New("operation not supported: not a key-value space")
+		}
+		engine, ok := eng.(storage.KeyValueEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not KeyValueEngine")
+		}
+		if query.Path == "" {
+			return "", errors.New("json path required")
+		}
+		var keys []string
+		var err error
+		// Equal selects an equality lookup on Value, which may be the empty
+		// string; a non-empty Value alone is still accepted from older clients
+		if query.Equal || query.Value != "" {
+			keys, err = engine.FindEqual(query.Path, parseIndexLiteral(query.Value))
+		} else {
+			var min, max interface{}
+			if query.Min != "" {
+				min = parseIndexLiteral(query.Min)
+			}
+			if query.Max != "" {
+				max = parseIndexLiteral(query.Max)
+			}
+			keys, err = engine.FindRange(query.Path, min, max)
+		}
+		if err != nil {
+			return "", err
+		}
+		return serializeKeys(keys), nil
+	case models.TypeInsertDocument:
+		engine, err := qe.documentEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if query.Value == "" {
+			return "", errors.New("document required")
+		}
+		return engine.InsertDocument(query.Value)
+	case models.TypeGetDocument:
+		engine, err := qe.documentEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if query.Key == "" {
+			return "", errors.New("document id required")
+		}
+		return engine.GetDocument(query.Key, query.Fields)
+	case models.TypeUpdateDocument:
+		engine, err := qe.documentEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if query.Key == "" {
+			return "", errors.New("document id required")
+		}
+		return engine.UpdateDocument(query.Key, query.Value)
+	case models.TypeDeleteDocument:
+		engine, err := qe.documentEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if query.Key == "" {
+			return "", errors.New("document id required")
+		}
+		if err := engine.DeleteDocument(query.Key); err != nil {
+			return "", err
+		}
+		return "DOCUMENT_DELETED", nil
+	case models.TypeFindDocuments:
+		engine, err := qe.documentEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		expr, err := filter.Parse(query.Filter)
+		if err != nil {
+			return "", err
+		}
+		docs, err := engine.FindDocuments(expr, query.Fields, query.Limit)
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(docs, ",") + "]", nil
+	case models.TypeInsertPoint:
+		engine, err := qe.timeSeriesEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if query.Key == "" {
+			return "", errors.New("series name required")
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(query.Value), 64)
+		if err != nil {
+			return "", errors.New("invalid point value")
+		}
+		if err := engine.AppendPoint(query.Key, query.Timestamp, value, query.Tags); err != nil {
+			return "", err
+		}
+		return "POINT_INSERTED", nil
+	case models.TypeQuerySeries:
+		engine, err := qe.timeSeriesEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if query.Key == "" {
+			return "", errors.New("series name required")
+		}
+		points, err := engine.QueryRange(query.Key, query.Tags, query.Start, query.End, query.Step, query.Aggregation)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(points)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case models.TypeIndexDoc:
+		engine, err := qe.textEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		var id int64
+		if _, err := fmt.Sscanf(query.Key, "%d", &id); err != nil {
+			return "", errors.New("invalid document id")
+		}
+		if err := engine.IndexDoc(id, query.Value); err != nil {
+			return "", err
+		}
+		return "DOC_INDEXED", nil
+	case models.TypeDeleteDoc:
+		engine, err := qe.textEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		var id int64
+		if _, err := fmt.Sscanf(query.Key, "%d", &id); err != nil {
+			return "", errors.New("invalid document id")
+		}
+		if err := engine.DeleteDoc(id); err != nil {
+			return "", err
+		}
+		return "DOC_DELETED", nil
+	case models.TypeSearchText:
+		engine, err := qe.textEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(query.Value) == "" {
+			return "", errors.New("search query required")
+		}
+		k := query.Limit
+		if k <= 0 {
+			k = 10
+		}
+		ids, scores, err := engine.SearchText(query.Value, k)
+		if err != nil {
+			return "", err
+		}
+		return formatTextResults(ids, scores), nil
+	case models.TypeInsertSparse:
+		engine, err := qe.sparseEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		var id int64
+		if _, err := fmt.Sscanf(query.Key, "%d", &id); err != nil {
+			return "", errors.New("invalid vector id")
+		}
+		if query.Sparse == nil || len(query.Sparse.Indices) == 0 {
+			return "", errors.New("sparse vector required")
+		}
+		if err := engine.InsertSparse(id, query.Sparse.Indices, query.Sparse.Values); err != nil {
+			return "", err
+		}
+		return "SPARSE_VECTOR_INSERTED", nil
+	case models.TypeSearchSparse:
+		engine, err := qe.sparseEngine(query.Space)
+		if err != nil {
+			return "", err
+		}
+		if query.Sparse == nil || len(query.Sparse.Indices) == 0 {
+			return "", errors.New("sparse query vector required")
+		}
+		k := query.Limit
+		if k <= 0 {
+			k = 10
+		}
+		ids, scores, err := engine.SearchSparse(query.Sparse.Indices, query.Sparse.Values, k)
+		if err != nil {
+			return "", err
+		}
+		wide := make([]float64, len(scores))
+		for i, s := range scores {
+			wide[i] = float64(s)
+		}
+		return formatTextResults(ids, wide), nil
 	// Vector operations (example, add more as needed)
-	case "INSERT_VECTOR":
+	case "INSERT_VECTOR", models.TypeUpsertVector, models.TypeInsertVectorIfAbsent, models.TypeUpdateVector:
 		if query.Space == "" {
 			return "", errors.New("no space selected")
 		}
+		if engine, ok := qe.binaryEngine(query.Space); ok && query.Type == "INSERT_VECTOR" {
+			return qe.executeBinaryVector(engine, query)
+		}
 		eng, ok := qe.spaceManager.GetSpace(query.Space)
 		if !ok {
 			return "", errors.New("space does not exist")
@@ -264,25 +517,159 @@ func (qe *QueryEngine) Execute(query models.Query) (string, error) {
 		if !ok {
 			return "", errors.New("internal error: engine is not VectorEngine")
 		}
-		// Expect query.Key as string id, query.Value as comma-separated floats
-		var id int64
-		_, err := fmt.Sscanf(query.Key, "%d", &id)
-		if err != nil {
-			return "", errors.New("invalid vector id")
+		// Reject a bad payload before anything is stored
+		if err := storage.ValidatePayload(query.Payload); err != nil {
+			return "", err
 		}
+		// Expect query.Key as a numeric or string id, query.Value as comma-separated floats.
+		// The vector is checked first so that a rejected insert allocates no ID for a
+		// new string key; an update never allocates one
 		vector, err := parseVector(query.Value, meta.Dimension)
 		if err != nil {
 			return "", err
 		}
-		err = engine.InsertVector(id, vector)
+		if err := engine.ValidateVector(vector); err != nil {
+			return "", err
+		}
+		id, err := engine.ResolveKey(query.Key, query.Type != models.TypeUpdateVector)
 		if err != nil {
 			return "", err
 		}
-		return "VECTOR_INSERTED", nil
+		// The text, payload and parent are written with the vector
+		attrs := storage.VectorAttrs{Text: query.Text, Payload: query.Payload, Parent: query.Parent}
+		result := "VECTOR_INSERTED"
+		switch query.Type {
+		case models.TypeUpsertVector:
+			created, err := engine.UpsertVector(id, vector, attrs)
+			if err != nil {
+				return "", err
+			}
+			if !created {
+				result = "VECTOR_UPDATED"
+			}
+		case models.TypeInsertVectorIfAbsent:
+			if err := engine.InsertVectorIfAbsent(id, vector, attrs); err != nil {
+				return "", err
+			}
+		case models.TypeUpdateVector:
+			if err := engine.UpdateVector(id, vector, attrs); err != nil {
+				return "", err
+			}
+			result = "VECTOR_UPDATED"
+		default:
+			if err := engine.InsertVectorWithAttrs(id, vector, attrs); err != nil {
+				return "", err
+			}
+		}
+		return result, nil
+	case models.TypeInsertVectors:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		if len(query.Vectors) == 0 {
+			return "", errors.New("no vectors to insert")
+		}
+		ids := make([]int64, len(query.Vectors))
+		vectors := make([][]float32, len(query.Vectors))
+		for i, rec := range query.Vectors {
+			if len(rec.Vector) != meta.Dimension {
+				return "", fmt.Errorf("vector %d has dimension %d, expected %d", i, len(rec.Vector), meta.Dimension)
+			}
+			if err := engine.ValidateVector(rec.Vector); err != nil {
+				return "", fmt.Errorf("vector %d: %w", i, err)
+			}
+			if err := storage.ValidatePayload(rec.Payload); err != nil {
+				return "", fmt.Errorf("vector %d: %w", i, err)
+			}
+		}
+		for i, rec := range query.Vectors {
+			id := rec.ID
+			if rec.Key != "" {
+				var err error
+				if id, err = engine.ResolveKey(rec.Key, true); err != nil {
+					return "", err
+				}
+			}
+			ids[i], vectors[i] = id, rec.Vector
+		}
+		if err := engine.InsertVectors(ids, vectors); err != nil {
+			return "", err
+		}
+		for i, rec := range query.Vectors {
+			if rec.Text != "" {
+				if err := engine.SetVectorText(ids[i], rec.Text); err != nil {
+					return "", err
+				}
+			}
+			if rec.Payload != "" {
+				if err := engine.SetPayload(ids[i], rec.Payload); err != nil {
+					return "", err
+				}
+			}
+			if rec.Parent != "" {
+				if err := engine.SetParent(ids[i], rec.Parent); err != nil {
+					return "", err
+				}
+			}
+		}
+		return "VECTORS_INSERTED", nil
+	case models.TypeSearchTopKBatch:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		for i, q := range query.Queries {
+			if len(q) != meta.Dimension {
+				return "", fmt.Errorf("query %d has dimension %d, expected %d", i, len(q), meta.Dimension)
+			}
+		}
+		k := query.Dimension
+		if k <= 0 {
+			k = 1
+		}
+		opts, err := vectorSearchOptions(query)
+		if err != nil {
+			return "", err
+		}
+		ids, dists, err := engine.SearchTopKBatch(query.Queries, k, opts)
+		if err != nil {
+			return "", err
+		}
+		results := make([]string, len(ids))
+		for i := range ids {
+			results[i] = formatSearchResults(engine, ids[i], dists[i])
+		}
+		return "[" + strings.Join(results, ", ") + "]", nil
 	case "SEARCH_TOPK":
 		if query.Space == "" {
 			return "", errors.New("no space selected")
 		}
+		if engine, ok := qe.binaryEngine(query.Space); ok {
+			return qe.executeBinaryVector(engine, query)
+		}
 		eng, ok := qe.spaceManager.GetSpace(query.Space)
 		if !ok {
 			return "", errors.New("space does not exist")
@@ -303,15 +690,66 @@ func (qe *QueryEngine) Execute(query models.Query) (string, error) {
 		if k <= 0 {
 			k = 1
 		}
-		ids, dists, err := engine.SearchTopK(vector, k)
+		opts, err := vectorSearchOptions(query)
+		if err != nil {
+			return "", err
+		}
+		if query.GroupBy {
+			groups, err := engine.SearchGrouped(vector, k, opts)
+			if err != nil {
+				return "", err
+			}
+			return formatGroupResults(engine, groups), nil
+		}
+		ids, dists, err := engine.SearchTopKWithOptions(vector, k, opts)
+		if err != nil {
+			return "", err
+		}
+		return formatSearchResults(engine, ids, dists), nil
+	case models.TypeSearchByID:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		positive := query.Positive
+		if query.Key != "" {
+			id, err := engine.ResolveKey(query.Key, false)
+			if err != nil {
+				return "", err
+			}
+			positive = append([]int64{id}, positive...)
+		}
+		k := query.Dimension
+		if k <= 0 {
+			k = 1
+		}
+		opts, err := vectorSearchOptions(query)
+		if err != nil {
+			return "", err
+		}
+		ids, dists, err := engine.SearchByID(positive, query.Negative, k, opts)
 		if err != nil {
 			return "", err
 		}
-		return formatSearchResults(ids, dists), nil
+		return formatSearchResults(engine, ids, dists), nil
 	case "RANGE_SEARCH":
 		if query.Space == "" {
 			return "", errors.New("no space selected")
 		}
+		if engine, ok := qe.binaryEngine(query.Space); ok {
+			return qe.executeBinaryVector(engine, query)
+		}
 		eng, ok := qe.spaceManager.GetSpace(query.Space)
 		if !ok {
 			return "", errors.New("space does not exist")
@@ -332,12 +770,16 @@ func (qe *QueryEngine) Execute(query models.Query) (string, error) {
 		if radius <= 0 {
 			radius = 1.0 // default radius if not set
 		}
-		ids, dists, err := engine.RangeSearch(vector, radius)
+		opts, err := vectorSearchOptions(query)
 		if err != nil {
 			return "", err
 		}
-		return formatSearchResults(ids, dists), nil
-	case "GET_VECTOR":
+		ids, dists, err := engine.RangeSearchWithOptions(vector, radius, opts)
+		if err != nil {
+			return "", err
+		}
+		return formatSearchResults(engine, ids, dists), nil
+	case models.TypeHybridSearch:
 		if query.Space == "" {
 			return "", errors.New("no space selected")
 		}
@@ -353,21 +795,499 @@ func (qe *QueryEngine) Execute(query models.Query) (string, error) {
 		if !ok {
 			return "", errors.New("internal error: engine is not VectorEngine")
 		}
-		var id int64
-		_, err := fmt.Sscanf(query.Key, "%d", &id)
+		vector, err := parseVector(query.Value, meta.Dimension)
 		if err != nil {
-			return "", errors.New("invalid vector id")
+			return "", err
 		}
-		vec, err := engine.GetVectorByID(id)
+		k := query.Dimension
+		if k <= 0 {
+			k = 1
+		}
+		opts := storage.HybridOptions{Fusion: query.Fusion, Alpha: 0.5}
+		if query.Alpha != nil {
+			opts.Alpha = *query.Alpha
+		}
+		ids, scores, err := engine.HybridSearch(vector, query.Text, k, opts)
 		if err != nil {
 			return "", err
 		}
-		return formatVector(vec), nil
+		return formatVectorScores(engine, ids, scores), nil
+	case "GET_VECTOR":
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		if engine, ok := qe.binaryEngine(query.Space); ok {
+			return qe.executeBinaryVector(engine, query)
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		id, err := engine.ResolveKey(query.Key, false)
+		if err != nil {
+			return "", err
+		}
+		vec, err := engine.GetVectorByID(id)
+		if err != nil {
+			return "", err
+		}
+		return formatVector(vec), nil
+	case models.TypeImportVectors:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		admin, err := qe.authManager.GetUser(query.User)
+		if err != nil || admin.Role != auth.RoleAdmin {
+			return "", errors.New("only admin can import vectors from server files")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		if query.Path == "" {
+			return "", errors.New("file path required")
+		}
+		r, err := vectorio.Open(query.Path, query.Format, query.FirstID)
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		progress, err := vectorio.Import(r, meta.Dimension, query.BatchSize, func(rec vectorio.Record) error {
+			return validateRecord(engine, rec)
+		}, func(batch []vectorio.Record) error {
+			return insertRecords(engine, batch)
+		}, func(p vectorio.Progress) {
+			log.Printf("Importing %s into %s: %d imported, %d rejected", query.Path, query.Space, p.Imported, p.Rejected)
+		})
+		if err != nil {
+			return "", fmt.Errorf("import stopped after %d vectors: %w", progress.Imported, err)
+		}
+		data, err := json.Marshal(progress)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case models.TypeDescribeSpace:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, _ := qe.spaceManager.SpaceMeta(query.Space)
+		desc := struct {
+			Space interface{}          `json:"space"`
+			Stats *storage.VectorStats `json:"stats,omitempty"`
+		}{Space: meta}
+		// Only vector spaces report engine statistics
+		if engine, ok := eng.(storage.VectorEngine); ok && meta.EngineType == "vector" {
+			stats, err := engine.Stats()
+			if err != nil {
+				return "", err
+			}
+			desc.Stats = stats
+		}
+		data, err := json.Marshal(desc)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case models.TypeListVectorIDs, models.TypeExportVectors:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		opts, err := vectorListOptions(query)
+		if err != nil {
+			return "", err
+		}
+		var result interface{}
+		if query.Type == models.TypeListVectorIDs {
+			if result, err = engine.ListVectorIDs(opts); err != nil {
+				return "", err
+			}
+		} else {
+			page := struct {
+				Vectors    []storage.ExportedVector `json:"vectors"`
+				NextCursor string                   `json:"next_cursor,omitempty"`
+			}{Vectors: []storage.ExportedVector{}}
+			page.NextCursor, err = engine.ExportVectors(opts, func(rec storage.ExportedVector) error {
+				page.Vectors = append(page.Vectors, rec)
+				return nil
+			})
+			if err != nil {
+				return "", err
+			}
+			result = page
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case models.TypeEvalRecall:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		k := query.Dimension
+		if k <= 0 {
+			k = 10
+		}
+		queries := query.Queries
+		if len(queries) == 0 {
+			sample := query.Limit
+			if sample <= 0 {
+				sample = 100
+			}
+			var err error
+			if queries, err = engine.SampleQueries(sample); err != nil {
+				return "", err
+			}
+			if len(queries) == 0 {
+				return "", errors.New("space has no vectors to sample queries from")
+			}
+		}
+		report, err := engine.EvaluateRecall(queries, k, query.SearchSettings)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(report)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case models.TypeCompactSpace:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		if err := engine.Compact(); err != nil {
+			return "", err
+		}
+		return "SPACE_COMPACTED", nil
+	case models.TypeRebuildIndex:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		// Limit is the number of stored vectors sampled for training
+		if err := engine.RebuildIndex(query.Limit); err != nil {
+			return "", err
+		}
+		return "INDEX_REBUILD_STARTED", nil
+	case models.TypeUpdatePayload:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		id, err := engine.ResolveKey(query.Key, false)
+		if err != nil {
+			return "", err
+		}
+		payload, err := engine.UpdatePayload(id, query.Payload)
+		if err != nil {
+			return "", err
+		}
+		if payload == "" {
+			payload = "{}"
+		}
+		return payload, nil
+	case models.TypeGetPayload:
+		if query.Space == "" {
+			return "", errors.New("no space selected")
+		}
+		eng, ok := qe.spaceManager.GetSpace(query.Space)
+		if !ok {
+			return "", errors.New("space does not exist")
+		}
+		meta, metaOk := qe.spaceManager.SpaceMeta(query.Space)
+		if !metaOk || meta.EngineType != "vector" {
+			return "", errors.New("operation not supported: not a vector space")
+		}
+		engine, ok := eng.(storage.VectorEngine)
+		if !ok {
+			return "", errors.New("internal error: engine is not VectorEngine")
+		}
+		id, err := engine.ResolveKey(query.Key, false)
+		if err != nil {
+			return "", err
+		}
+		return engine.GetPayload(id)
 	}
 
 	return "", errors.New("unsupported query type")
 }
 
+// documentEngine resolves a space that must be backed by the document engine.
+func (qe *QueryEngine) documentEngine(space string) (storage.DocumentEngine, error) {
+	if space == "" {
+		return nil, errors.New("no space selected")
+	}
+	eng, ok := qe.spaceManager.GetSpace(space)
+	if !ok {
+		return nil, errors.New("space does not exist")
+	}
+	meta, metaOk := qe.spaceManager.SpaceMeta(space)
+	if !metaOk || meta.EngineType != "document" {
+		return nil, errors.New("operation not supported: not a document space")
+	}
+	engine, ok := eng.(storage.DocumentEngine)
+	if !ok {
+		return nil, errors.New("internal error: engine is not DocumentEngine")
+	}
+	return engine, nil
+}
+
+// timeSeriesEngine resolves a space that must be backed by the time-series engine.
+func (qe *QueryEngine) timeSeriesEngine(space string) (storage.TimeSeriesEngine, error) {
+	if space == "" {
+		return nil, errors.New("no space selected")
+	}
+	eng, ok := qe.spaceManager.GetSpace(space)
+	if !ok {
+		return nil, errors.New("space does not exist")
+	}
+	meta, metaOk := qe.spaceManager.SpaceMeta(space)
+	if !metaOk || meta.EngineType != "timeseries" {
+		return nil, errors.New("operation not supported: not a time-series space")
+	}
+	engine, ok := eng.(storage.TimeSeriesEngine)
+	if !ok {
+		return nil, errors.New("internal error: engine is not TimeSeriesEngine")
+	}
+	return engine, nil
+}
+
+// textEngine resolves a space that must be backed by the text engine.
+func (qe *QueryEngine) textEngine(space string) (storage.TextEngine, error) {
+	if space == "" {
+		return nil, errors.New("no space selected")
+	}
+	eng, ok := qe.spaceManager.GetSpace(space)
+	if !ok {
+		return nil, errors.New("space does not exist")
+	}
+	meta, metaOk := qe.spaceManager.SpaceMeta(space)
+	if !metaOk || meta.EngineType != "text" {
+		return nil, errors.New("operation not supported: not a text space")
+	}
+	engine, ok := eng.(storage.TextEngine)
+	if !ok {
+		return nil, errors.New("internal error: engine is not TextEngine")
+	}
+	return engine, nil
+}
+
+// validateRecord reports why an imported record would not be inserted, so
+// that the import can reject the row instead of failing its batch.
+func validateRecord(engine storage.VectorEngine, rec vectorio.Record) error {
+	if err := engine.ValidateVector(rec.Vector); err != nil {
+		return err
+	}
+	return storage.ValidatePayload(rec.Payload)
+}
+
+// insertRecords inserts imported vectors with their payloads and parents;
+// string IDs are resolved to (new) vector IDs once the whole batch is valid.
+func insertRecords(engine storage.VectorEngine, batch []vectorio.Record) error {
+	for _, rec := range batch {
+		if err := validateRecord(engine, rec); err != nil {
+			return fmt.Errorf("row %d: %w", rec.Row, err)
+		}
+	}
+	ids := make([]int64, len(batch))
+	vectors := make([][]float32, len(batch))
+	for i, rec := range batch {
+		id := rec.ID
+		if rec.Key != "" {
+			var err error
+			if id, err = engine.ResolveKey(rec.Key, true); err != nil {
+				return err
+			}
+		}
+		ids[i], vectors[i] = id, rec.Vector
+	}
+	if err := engine.InsertVectors(ids, vectors); err != nil {
+		return err
+	}
+	for i, rec := range batch {
+		if rec.Payload != "" {
+			if err := engine.SetPayload(ids[i], rec.Payload); err != nil {
+				return err
+			}
+		}
+		if rec.Parent != "" {
+			if err := engine.SetParent(ids[i], rec.Parent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// binaryEngine returns the engine of a binary vector space; ok is false for
+// any other space.
+func (qe *QueryEngine) binaryEngine(space string) (storage.BinaryVectorEngine, bool) {
+	meta, metaOk := qe.spaceManager.SpaceMeta(space)
+	if !metaOk || meta.EngineType != "binary-vector" {
+		return nil, false
+	}
+	eng, ok := qe.spaceManager.GetSpace(space)
+	if !ok {
+		return nil, false
+	}
+	engine, ok := eng.(storage.BinaryVectorEngine)
+	return engine, ok
+}
+
+// executeBinaryVector runs INSERT_VECTOR, SEARCH_TOPK, RANGE_SEARCH and
+// GET_VECTOR on a binary vector space. Vectors are packed bits given as hex
+// or base64 in query.Value; distances are Hamming distances.
+func (qe *QueryEngine) executeBinaryVector(engine storage.BinaryVectorEngine, query models.Query) (string, error) {
+	var id int64
+	if query.Type == "INSERT_VECTOR" || query.Type == "GET_VECTOR" {
+		if _, err := fmt.Sscanf(query.Key, "%d", &id); err != nil {
+			return "", errors.New("invalid vector id: binary vector spaces use numeric IDs")
+		}
+	}
+	var code []byte
+	if query.Type != "GET_VECTOR" {
+		var err error
+		if code, err = storage.ParseBinaryVector(query.Value, engine.CodeSize()); err != nil {
+			return "", err
+		}
+	}
+
+	switch query.Type {
+	case "INSERT_VECTOR":
+		if err := engine.InsertBinary(id, code); err != nil {
+			return "", err
+		}
+		return "VECTOR_INSERTED", nil
+	case "GET_VECTOR":
+		code, err := engine.GetBinary(id)
+		if err != nil {
+			return "", err
+		}
+		return storage.FormatBinaryVector(code), nil
+	case "SEARCH_TOPK":
+		k := query.Dimension
+		if k <= 0 {
+			k = 1
+		}
+		ids, dists, err := engine.SearchBinary(code, k)
+		if err != nil {
+			return "", err
+		}
+		return formatHammingResults(ids, dists), nil
+	case "RANGE_SEARCH":
+		radius := query.Radius
+		if radius <= 0 {
+			radius = 1.0 // default radius if not set
+		}
+		ids, dists, err := engine.RangeSearchBinary(code, int(math.Ceil(float64(radius))))
+		if err != nil {
+			return "", err
+		}
+		return formatHammingResults(ids, dists), nil
+	}
+	return "", fmt.Errorf("operation %s not supported on binary vector spaces", query.Type)
+}
+
+// sparseEngine resolves a space that must be backed by the sparse vector
+// engine.
+func (qe *QueryEngine) sparseEngine(space string) (storage.SparseVectorEngine, error) {
+	if space == "" {
+		return nil, errors.New("no space selected")
+	}
+	eng, ok := qe.spaceManager.GetSpace(space)
+	if !ok {
+		return nil, errors.New("space does not exist")
+	}
+	meta, metaOk := qe.spaceManager.SpaceMeta(space)
+	if !metaOk || meta.EngineType != "sparse-vector" {
+		return nil, errors.New("operation not supported: not a sparse vector space")
+	}
+	engine, ok := eng.(storage.SparseVectorEngine)
+	if !ok {
+		return nil, errors.New("internal error: engine is not SparseVectorEngine")
+	}
+	return engine, nil
+}
+
 func serializeSpaces(spaces []string) string {
 	json := `{"status":"OK","spaces":[`
 	for i, name := range spaces {
@@ -380,6 +1300,25 @@ func serializeSpaces(spaces []string) string {
 	return json
 }
 
+func serializeKeys(keys []string) string {
+	data, _ := json.Marshal(keys)
+	return string(data)
+}
+
+// parseIndexLiteral interprets a FIND operand as a JSON literal (number,
+// boolean, null or quoted string), falling back to the raw text as a string.
+func parseIndexLiteral(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return s
+	}
+	return v
+}
+
 // Helper to parse vector from string
 func parseVector(s string, dim int) ([]float32, error) {
 	parts := strings.Split(s, ",")
@@ -398,6 +1337,21 @@ func parseVector(s string, dim int) ([]float32, error) {
 	return vec, nil
 }
 
+// vectorSearchOptions builds the vector search options of a query; Filter is
+// a payload filter in the FIND_DOCUMENTS syntax and SearchParams override the
+// space's FAISS search parameters.
+func vectorSearchOptions(query models.Query) (storage.SearchOptions, error) {
+	opts := storage.SearchOptions{Params: query.SearchParams, Rerank: query.Rerank, Diversity: query.Diversity}
+	if query.Filter != "" {
+		expr, err := filter.Parse(query.Filter)
+		if err != nil {
+			return opts, err
+		}
+		opts.Filter = expr
+	}
+	return opts, nil
+}
+
 // Helper to format vector
 func formatVector(vec []float32) string {
 	parts := make([]string, len(vec))
@@ -407,14 +1361,112 @@ func formatVector(vec []float32) string {
 	return strings.Join(parts, ",")
 }
 
-func formatSearchResults(ids []int64, dists []float32) string {
+// vectorListOptions reads the page of a LIST_VECTOR_IDS or EXPORT_VECTORS
+// query; both return DefaultListLimit vectors per page unless Limit is set.
+func vectorListOptions(query models.Query) (storage.ListOptions, error) {
+	opts := storage.ListOptions{Cursor: query.Cursor, Limit: query.Limit}
+	if opts.Limit <= 0 {
+		opts.Limit = storage.DefaultListLimit
+	}
+	if opts.Limit > storage.MaxListLimit {
+		return opts, fmt.Errorf("page size must be between 1 and %d", storage.MaxListLimit)
+	}
+	for _, bound := range []struct {
+		value string
+		dst   **int64
+	}{{query.Min, &opts.Min}, {query.Max, &opts.Max}} {
+		if bound.value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(bound.value, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid vector ID bound '%s'", bound.value)
+		}
+		*bound.dst = &id
+	}
+	return opts, nil
+}
+
+func formatSearchResults(engine storage.VectorEngine, ids []int64, dists []float32) string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := range ids {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(fmt.Sprintf("{\"id\": %s, \"distance\": %f}", vectorIDJSON(engine, ids[i]), dists[i]))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// formatGroupResults formats grouped search results; a vector without a
+// parent document stands for itself.
+func formatGroupResults(engine storage.VectorEngine, groups []storage.GroupResult) string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i, g := range groups {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		id := vectorIDJSON(engine, g.ID)
+		doc := id
+		if g.Parent != "" {
+			b, _ := json.Marshal(g.Parent)
+			doc = string(b)
+		}
+		sb.WriteString(fmt.Sprintf("{\"document\": %s, \"id\": %s, \"distance\": %f}", doc, id, g.Score))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// formatVectorScores formats hybrid search results of a vector space.
+func formatVectorScores(engine storage.VectorEngine, ids []int64, scores []float64) string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := range ids {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(fmt.Sprintf("{\"id\": %s, \"score\": %f}", vectorIDJSON(engine, ids[i]), scores[i]))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// vectorIDJSON returns the key a vector was inserted with as JSON: a string
+// for string keys, a number otherwise.
+func vectorIDJSON(engine storage.VectorEngine, id int64) string {
+	key, isString := engine.VectorKey(id)
+	if !isString {
+		return key
+	}
+	b, _ := json.Marshal(key)
+	return string(b)
+}
+
+func formatHammingResults(ids []int64, dists []int32) string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := range ids {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(fmt.Sprintf("{\"id\": %d, \"distance\": %d}", ids[i], dists[i]))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+func formatTextResults(ids []int64, scores []float64) string {
 	var sb strings.Builder
 	sb.WriteString("[")
 	for i := range ids {
 		if i > 0 {
 			sb.WriteString(", ")
 		}
-		sb.WriteString(fmt.Sprintf("{\"id\": %d, \"distance\": %f}", ids[i], dists[i]))
+		sb.WriteString(fmt.Sprintf("{\"id\": %d, \"score\": %f}", ids[i], scores[i]))
 	}
 	sb.WriteString("]")
 	return sb.String()
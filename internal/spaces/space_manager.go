@@ -8,14 +8,16 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/shibudb.org/shibudb-server/internal/storage"
+	"github.com/shibudb.org/shibudb-server/internal/textindex"
 
 	"github.com/DataIntelligenceCrew/go-faiss"
 )
 
-var allowedIndexTypes = []string{"Flat", "HNSW", "IVF", "PQ"}
-var allowedMetrics = []string{"L2", "InnerProduct", "L1", "Lp", "Canberra", "BrayCurtis", "JensenShannon", "Linf"}
+var allowedIndexTypes = []string{"Flat", "HNSW", "IVF", "PQ", "SQ", "SQfp"}
+var allowedMetrics = []string{"L2", "InnerProduct", "Cosine", "L1", "Lp", "Canberra", "BrayCurtis", "JensenShannon", "Linf"}
 
 func isPowerOf2InRange(n int) bool {
 	if n < 2 || n > 256 {
@@ -74,6 +76,14 @@ func isAllowedIndexType(indexType string) bool {
 		if base == "Flat" && num != -1 {
 			return false
 		}
+
+		// Scalar quantizers: SQ4, SQ8 and half precision SQfp16
+		if base == "SQ" && num != 4 && num != 8 {
+			return false
+		}
+		if base == "SQfp" && num != 16 {
+			return false
+		}
 	}
 	return true
 }
@@ -94,11 +104,29 @@ type spaceMeta struct {
 	IndexType  string `json:"index_type,omitempty"`
 	Metric     string `json:"metric,omitempty"`
 	EnableWAL  bool   `json:"enable_wal,omitempty"`
+
+	// JSON paths with a secondary index (key-value spaces only)
+	Indexes []string `json:"indexes,omitempty"`
+
+	// Time-series settings, as durations such as "30d" or "12h"
+	Retention     string `json:"retention,omitempty"`
+	PartitionSize string `json:"partition_size,omitempty"`
+
+	// Text analyzer (text spaces only)
+	Analyzer string `json:"analyzer,omitempty"`
+
+	// Default FAISS search parameters (vector spaces only), e.g. nprobe
+	SearchParams map[string]float64 `json:"search_params,omitempty"`
+
+	// Encoding of the vector data file: float32 (default), float16 or int8
+	VectorEncoding string `json:"vector_encoding,omitempty"`
 }
 
+const defaultPartitionSize = 24 * time.Hour
+
 type SpaceManager struct {
 	lock         sync.RWMutex
-	spaces       map[string]interface{} // can be KeyValueEngine or VectorEngine
+	spaces       map[string]interface{} // can be KeyValueEngine, DocumentEngine, TimeSeriesEngine, TextEngine or VectorEngine
 	spaceMetas   map[string]spaceMeta
 	baseDir      string
 	metaFilePath string
@@ -135,10 +163,63 @@ func (sm *SpaceManager) loadSpaceMetas() {
 				enableWAL := meta.EnableWAL
 				db, err := storage.OpenDBWithPathsAndWAL(dataFile, walFile, indexFile, enableWAL)
 				if err == nil {
+					for _, path := range meta.Indexes {
+						if err := db.LoadIndex(path); err != nil {
+							fmt.Printf("❌ Failed to build index '%s' on space '%s': %v\n", path, meta.Name, err)
+						}
+					}
 					sm.spaces[meta.Name] = db
 				} else {
 					fmt.Printf("❌ Failed to open key-value space '%s': %v\n", meta.Name, err)
 				}
+			} else if meta.EngineType == "document" {
+				dataFile := filepath.Join(spacePath, "data.db")
+				walFile := filepath.Join(spacePath, "wal.db")
+				indexFile := filepath.Join(spacePath, "index.dat")
+				ds, err := storage.NewDocumentStore(dataFile, walFile, indexFile, meta.EnableWAL)
+				if err == nil {
+					for _, path := range meta.Indexes {
+						if err := ds.LoadIndex(path); err != nil {
+							fmt.Printf("❌ Failed to build index '%s' on space '%s': %v\n", path, meta.Name, err)
+						}
+					}
+					sm.spaces[meta.Name] = ds
+				} else {
+					fmt.Printf("❌ Failed to open document space '%s': %v\n", meta.Name, err)
+				}
+			} else if meta.EngineType == "timeseries" {
+				ts, err := openTimeSeries(spacePath, meta)
+				if err == nil {
+					sm.spaces[meta.Name] = ts
+				} else {
+					fmt.Printf("❌ Failed to open time-series space '%s': %v\n", meta.Name, err)
+				}
+			} else if meta.EngineType == "text" {
+				indexFile := filepath.Join(spacePath, "text_index.dat")
+				walFile := filepath.Join(spacePath, "text_wal.db")
+				te, err := storage.NewTextEngine(indexFile, walFile, meta.Analyzer, meta.EnableWAL)
+				if err == nil {
+					sm.spaces[meta.Name] = te
+				} else {
+					fmt.Printf("❌ Failed to open text space '%s': %v\n", meta.Name, err)
+				}
+			} else if meta.EngineType == "sparse-vector" {
+				indexFile := filepath.Join(spacePath, "sparse_index.dat")
+				walFile := filepath.Join(spacePath, "sparse_wal.db")
+				se, err := storage.NewSparseEngine(indexFile, walFile, meta.EnableWAL)
+				if err == nil {
+					sm.spaces[meta.Name] = se
+				} else {
+					fmt.Printf("❌ Failed to open sparse vector space '%s': %v\n", meta.Name, err)
+				}
+			} else if meta.EngineType == "binary-vector" {
+				dataFile := filepath.Join(spacePath, "binary_data.db")
+				be, err := storage.NewBinaryEngine(dataFile, meta.Dimension, meta.IndexType, int(meta.SearchParams["nprobe"]), meta.EnableWAL)
+				if err == nil {
+					sm.spaces[meta.Name] = be
+				} else {
+					fmt.Printf("❌ Failed to open binary vector space '%s': %v\n", meta.Name, err)
+				}
 			} else if meta.EngineType == "vector" {
 				dataFile := filepath.Join(spacePath, "vector_data.db")
 				indexFile := filepath.Join(spacePath, "vector_index.faiss")
@@ -153,8 +234,11 @@ func (sm *SpaceManager) loadSpaceMetas() {
 				metric := getFAISSMetric(meta.Metric)
 				// Use stored WAL setting, default to false for backward compatibility
 				enableWAL := meta.EnableWAL
-				ve, err := storage.NewVectorEngine(dataFile, indexFile, walFile, meta.Dimension, indexType, metric, enableWAL)
+				ve, err := storage.NewVectorEngineWithEncoding(dataFile, indexFile, walFile, meta.Dimension, indexType, metric, enableWAL, meta.VectorEncoding)
 				if err == nil {
+					if err := ve.SetSearchDefaults(meta.SearchParams); err != nil {
+						fmt.Printf("❌ Failed to apply search parameters to vector space '%s': %v\n", meta.Name, err)
+					}
 					sm.spaces[meta.Name] = ve
 				} else {
 					fmt.Printf("❌ Failed to open vector space '%s': %v\n", meta.Name, err)
@@ -192,15 +276,89 @@ func (sm *SpaceManager) UseSpace(space string) (interface{}, error) {
 }
 
 func (sm *SpaceManager) CreateSpace(space, engineType string, dimension int, indexType string, metric string) (interface{}, error) {
-	// Default to WAL enabled for key-value (backward compatibility) and disabled for vector (performance)
-	enableWAL := engineType == "key-value"
+	// Default to WAL enabled for key-value (backward compatibility), document, text, sparse-vector and timeseries, disabled for vector (performance)
+	enableWAL := engineType == "key-value" || engineType == "document" || engineType == "text" || engineType == "sparse-vector" || engineType == "timeseries"
 	return sm.CreateSpaceWithWAL(space, engineType, dimension, indexType, metric, enableWAL)
 }
 
 func (sm *SpaceManager) CreateSpaceWithWAL(space, engineType string, dimension int, indexType string, metric string, enableWAL bool) (interface{}, error) {
+	meta := spaceMeta{Name: space, EngineType: engineType, Dimension: dimension, IndexType: indexType, Metric: metric, EnableWAL: enableWAL}
+	return sm.createSpace(meta)
+}
+
+// CreateVectorSpace creates a vector space whose searches default to the
+// given FAISS search parameters (see storage.ValidateSearchParams) and whose
+// data file stores vectors with the given encoding (see
+// storage.ValidateVectorEncoding).
+func (sm *SpaceManager) CreateVectorSpace(space string, dimension int, indexType, metric string, enableWAL bool, searchParams map[string]float64, encoding string) (interface{}, error) {
+	if err := storage.ValidateSearchParams(searchParams); err != nil {
+		return nil, err
+	}
+	if err := storage.ValidateVectorEncoding(encoding); err != nil {
+		return nil, err
+	}
+	meta := spaceMeta{Name: space, EngineType: "vector", Dimension: dimension, IndexType: indexType, Metric: metric, EnableWAL: enableWAL, SearchParams: searchParams, VectorEncoding: encoding}
+	return sm.createSpace(meta)
+}
+
+// CreateBinaryVectorSpace creates a space of packed bit vectors searched by
+// Hamming distance. The dimension is in bits and must be a multiple of 8; the
+// index type is a FAISS binary index (see storage.NormalizeBinaryIndexType).
+// The only search parameter is nprobe, for IVF indexes.
+func (sm *SpaceManager) CreateBinaryVectorSpace(space string, dimension int, indexType, metric string, enableWAL bool, searchParams map[string]float64) (interface{}, error) {
+	desc, err := storage.NormalizeBinaryIndexType(indexType)
+	if err != nil {
+		return nil, err
+	}
+	if metric != "" && metric != "Hamming" {
+		return nil, fmt.Errorf("metric '%s' is not allowed for binary vectors (use Hamming)", metric)
+	}
+	for name := range searchParams {
+		if name != "nprobe" {
+			return nil, fmt.Errorf("search parameter '%s' is not allowed for binary vectors (use nprobe)", name)
+		}
+	}
+	if err := storage.ValidateSearchParams(searchParams); err != nil {
+		return nil, err
+	}
+	meta := spaceMeta{Name: space, EngineType: "binary-vector", Dimension: dimension, IndexType: desc, Metric: "Hamming", EnableWAL: enableWAL, SearchParams: searchParams}
+	return sm.createSpace(meta)
+}
+
+// CreateTimeSeriesSpace creates a time-series space. Points older than
+// retention are dropped a partition at a time; an empty retention keeps data
+// forever and an empty partitionSize defaults to one day.
+func (sm *SpaceManager) CreateTimeSeriesSpace(space, retention, partitionSize string, enableWAL bool) (interface{}, error) {
+	if _, err := storage.ParseRetention(retention); err != nil {
+		return nil, fmt.Errorf("invalid retention: %w", err)
+	}
+	if _, err := storage.ParseRetention(partitionSize); err != nil {
+		return nil, fmt.Errorf("invalid partition size: %w", err)
+	}
+	meta := spaceMeta{Name: space, EngineType: "timeseries", EnableWAL: enableWAL, Retention: retention, PartitionSize: partitionSize}
+	return sm.createSpace(meta)
+}
+
+// CreateTextSpace creates a full-text space whose documents are analyzed with
+// the named analyzer (see textindex.Analyzers); empty means "standard".
+func (sm *SpaceManager) CreateTextSpace(space, analyzer string, enableWAL bool) (interface{}, error) {
+	if analyzer == "" {
+		analyzer = textindex.DefaultAnalyzer
+	}
+	if _, err := textindex.NewAnalyzer(analyzer); err != nil {
+		return nil, err
+	}
+	meta := spaceMeta{Name: space, EngineType: "text", EnableWAL: enableWAL, Analyzer: analyzer}
+	return sm.createSpace(meta)
+}
+
+func (sm *SpaceManager) createSpace(meta spaceMeta) (interface{}, error) {
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
 
+	space, engineType := meta.Name, meta.EngineType
+	dimension, indexType, metric, enableWAL := meta.Dimension, meta.IndexType, meta.Metric, meta.EnableWAL
+
 	if _, exists := sm.spaces[space]; exists {
 		return nil, errors.New("space already exists")
 	}
@@ -208,7 +366,6 @@ func (sm *SpaceManager) CreateSpaceWithWAL(space, engineType string, dimension i
 		return nil, errors.New("space already exists")
 	}
 
-	meta := spaceMeta{Name: space, EngineType: engineType, Dimension: dimension, IndexType: indexType, Metric: metric, EnableWAL: enableWAL}
 	spacePath := filepath.Join(sm.baseDir, space)
 	if err := os.MkdirAll(spacePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create space dir: %w", err)
@@ -224,6 +381,45 @@ func (sm *SpaceManager) CreateSpaceWithWAL(space, engineType string, dimension i
 			return nil, err
 		}
 		engine = db
+	} else if engineType == "document" {
+		dataFile := filepath.Join(spacePath, "data.db")
+		walFile := filepath.Join(spacePath, "wal.db")
+		indexFile := filepath.Join(spacePath, "index.dat")
+		ds, err := storage.NewDocumentStore(dataFile, walFile, indexFile, enableWAL)
+		if err != nil {
+			return nil, err
+		}
+		engine = ds
+	} else if engineType == "timeseries" {
+		ts, err := openTimeSeries(spacePath, meta)
+		if err != nil {
+			return nil, err
+		}
+		engine = ts
+	} else if engineType == "text" {
+		indexFile := filepath.Join(spacePath, "text_index.dat")
+		walFile := filepath.Join(spacePath, "text_wal.db")
+		te, err := storage.NewTextEngine(indexFile, walFile, meta.Analyzer, enableWAL)
+		if err != nil {
+			return nil, err
+		}
+		engine = te
+	} else if engineType == "sparse-vector" {
+		indexFile := filepath.Join(spacePath, "sparse_index.dat")
+		walFile := filepath.Join(spacePath, "sparse_wal.db")
+		se, err := storage.NewSparseEngine(indexFile, walFile, enableWAL)
+		if err != nil {
+			return nil, err
+		}
+		engine = se
+	} else if engineType == "binary-vector" {
+		dataFile := filepath.Join(spacePath, "binary_data.db")
+		be, err := storage.NewBinaryEngine(dataFile, dimension, indexType, int(meta.SearchParams["nprobe"]), enableWAL)
+		if err != nil {
+			os.RemoveAll(spacePath)
+			return nil, err
+		}
+		engine = be
 	} else if engineType == "vector" {
 		if !isAllowedIndexType(indexType) {
 			return nil, fmt.Errorf("index type '%s' is not allowed", indexType)
@@ -234,10 +430,15 @@ func (sm *SpaceManager) CreateSpaceWithWAL(space, engineType string, dimension i
 		dataFile := filepath.Join(spacePath, "vector_data.db")
 		indexFile := filepath.Join(spacePath, "vector_index.faiss")
 		walFile := filepath.Join(spacePath, "vector_wal.db")
-		ve, err := storage.NewVectorEngine(dataFile, indexFile, walFile, dimension, indexType, getFAISSMetric(metric), enableWAL)
+		ve, err := storage.NewVectorEngineWithEncoding(dataFile, indexFile, walFile, dimension, indexType, getFAISSMetric(metric), enableWAL, meta.VectorEncoding)
 		if err != nil {
 			return nil, err
 		}
+		if err := ve.SetSearchDefaults(meta.SearchParams); err != nil {
+			ve.Close()
+			os.RemoveAll(spacePath)
+			return nil, err
+		}
 		engine = ve
 	} else {
 		return nil, fmt.Errorf("unknown engine type: %s", engineType)
@@ -249,11 +450,32 @@ func (sm *SpaceManager) CreateSpaceWithWAL(space, engineType string, dimension i
 	return engine, nil
 }
 
+func openTimeSeries(spacePath string, meta spaceMeta) (*storage.TimeSeriesEngineImpl, error) {
+	retention, err := storage.ParseRetention(meta.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention: %w", err)
+	}
+	partitionSize, err := storage.ParseRetention(meta.PartitionSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid partition size: %w", err)
+	}
+	if partitionSize == 0 {
+		partitionSize = defaultPartitionSize
+	}
+	dir := filepath.Join(spacePath, "partitions")
+	walFile := filepath.Join(spacePath, "ts_wal.db")
+	return storage.NewTimeSeriesEngine(dir, walFile, partitionSize, retention, meta.EnableWAL)
+}
+
 func getFAISSMetric(metric string) int {
 	faissMetric := faiss.MetricL2
 	if metric == "InnerProduct" {
 		faissMetric = faiss.MetricInnerProduct
 	}
+	if metric == "Cosine" {
+		// Normalized inner product, see storage.MetricCosine
+		faissMetric = storage.MetricCosine
+	}
 	if metric == "L2" {
 		faissMetric = faiss.MetricL2
 	}
@@ -303,6 +525,99 @@ func (sm *SpaceManager) DeleteSpace(space string) error {
 	return nil
 }
 
+// CreateIndex adds a secondary index on a JSON field path to a key-value or
+// document space and records it in the space metadata so it is reloaded on
+// restart.
+func (sm *SpaceManager) CreateIndex(space, path string) error {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	meta, exists := sm.spaceMetas[space]
+	if !exists {
+		return errors.New("space does not exist")
+	}
+	for _, p := range meta.Indexes {
+		if p == path {
+			return fmt.Errorf("index on '%s' already exists", path)
+		}
+	}
+	var err error
+	switch meta.EngineType {
+	case "key-value":
+		engine, ok := sm.spaces[space].(storage.KeyValueEngine)
+		if !ok {
+			return errors.New("internal error: engine is not KeyValueEngine")
+		}
+		err = engine.CreateIndex(path)
+	case "document":
+		engine, ok := sm.spaces[space].(storage.DocumentEngine)
+		if !ok {
+			return errors.New("internal error: engine is not DocumentEngine")
+		}
+		err = engine.CreateIndex(path)
+	default:
+		return errors.New("operation not supported: not a key-value or document space")
+	}
+	if err != nil {
+		return err
+	}
+
+	meta.Indexes = append(meta.Indexes, path)
+	sm.spaceMetas[space] = meta
+	sm.saveSpaceMetas()
+	return nil
+}
+
+// MigrateIndex rebuilds a vector space into an index of another type and
+// metric (empty keeps the current one). The space stays searchable on its old
+// index while the new one is built in the background; the space metadata is
+// updated once the new index is swapped in.
+func (sm *SpaceManager) MigrateIndex(space, indexType, metric string) error {
+	sm.lock.RLock()
+	meta, exists := sm.spaceMetas[space]
+	eng := sm.spaces[space]
+	sm.lock.RUnlock()
+
+	if !exists {
+		return errors.New("space does not exist")
+	}
+	if meta.EngineType != "vector" {
+		return errors.New("operation not supported: not a vector space")
+	}
+	if indexType == "" {
+		indexType = meta.IndexType
+	}
+	if metric == "" {
+		metric = meta.Metric
+	}
+	if !isAllowedIndexType(indexType) {
+		return fmt.Errorf("index type '%s' is not allowed", indexType)
+	}
+	if !isAllowedMetric(metric) {
+		return fmt.Errorf("metric '%s' is not allowed", metric)
+	}
+	engine, ok := eng.(storage.VectorEngine)
+	if !ok {
+		return errors.New("internal error: engine is not VectorEngine")
+	}
+
+	return engine.MigrateIndex(indexType, getFAISSMetric(metric), func(err error) {
+		if err != nil {
+			fmt.Printf("❌ Failed to migrate index of vector space '%s': %v\n", space, err)
+			return
+		}
+		sm.lock.Lock()
+		defer sm.lock.Unlock()
+		meta, exists := sm.spaceMetas[space]
+		if !exists {
+			return // deleted while migrating
+		}
+		meta.IndexType, meta.Metric = indexType, metric
+		sm.spaceMetas[space] = meta
+		sm.saveSpaceMetas()
+	})
+}
+
 func (sm *SpaceManager) ListSpaces() []string {
 	sm.lock.RLock()
 	defer sm.lock.RUnlock()
@@ -1,6 +1,8 @@
 package spaces
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -84,6 +86,16 @@ func TestIsAllowedIndexType(t *testing.T) {
 		// Invalid index types
 		{"Invalid type", "Invalid", false},
 		{"Unknown type", "Unknown32", false},
+
+		// Scalar quantizers
+		{"SQ8", "SQ8", true},
+		{"SQ4", "SQ4", true},
+		{"SQfp16", "SQfp16", true},
+		{"IVF256 with SQ8", "IVF256,SQ8", true},
+		{"HNSW32 with SQfp16", "HNSW32,SQfp16", true},
+		{"SQ16", "SQ16", false},
+		{"SQ without number", "SQ", false},
+		{"SQfp32", "SQfp32", false},
 	}
 	
 	for _, tt := range tests {
@@ -147,3 +159,18 @@ func TestIsPowerOf2InRange(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateTimeSeriesSpaceRejectsBadDurations(t *testing.T) {
+	base := t.TempDir()
+	sm := NewSpaceManager(base)
+
+	if _, err := sm.CreateTimeSeriesSpace("metrics", "forever", "", true); err == nil {
+		t.Error("expected an invalid retention to be rejected")
+	}
+	if _, err := sm.CreateTimeSeriesSpace("metrics", "30d", "-1h", true); err == nil {
+		t.Error("expected an invalid partition size to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(base, "metrics")); !os.IsNotExist(err) {
+		t.Errorf("expected no space directory to be created, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package sparseindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// snapshotMagic starts every saved index file.
+const snapshotMagic = "SHSP1"
+
+// Vector is a sparse vector: weights at dimension indices, e.g. the term
+// weights of a SPLADE embedding.
+type Vector struct {
+	Indices []uint32
+	Values  []float32
+}
+
+// Validate checks that a vector has matching, distinct indices and finite,
+// non-negative weights. Non-negative weights are what lets searches prune.
+func (v Vector) Validate() error {
+	if len(v.Indices) != len(v.Values) {
+		return fmt.Errorf("sparse vector has %d indices but %d values", len(v.Indices), len(v.Values))
+	}
+	seen := make(map[uint32]struct{}, len(v.Indices))
+	for i, idx := range v.Indices {
+		if _, dup := seen[idx]; dup {
+			return fmt.Errorf("sparse vector repeats index %d", idx)
+		}
+		seen[idx] = struct{}{}
+		w := float64(v.Values[i])
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("sparse vector weight at index %d must be finite and non-negative", idx)
+		}
+	}
+	return nil
+}
+
+// Index is an in-memory inverted index of sparse vectors scored by dot
+// product. It is safe for concurrent use and can be saved to and loaded from
+// a file.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[uint32]map[int64]float32 // dimension -> id -> weight
+	maxW     map[uint32]float32           // upper bound of each dimension's weights
+	vectors  map[int64]Vector
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{
+		postings: make(map[uint32]map[int64]float32),
+		maxW:     make(map[uint32]float32),
+		vectors:  make(map[int64]Vector),
+	}
+}
+
+// Add indexes v under id, replacing any previous vector for id. Zero weights
+// are dropped.
+func (ix *Index) Add(id int64, v Vector) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	var kept Vector
+	for i, idx := range v.Indices {
+		if v.Values[i] != 0 {
+			kept.Indices = append(kept.Indices, idx)
+			kept.Values = append(kept.Values, v.Values[i])
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.removeLocked(id)
+	for i, idx := range kept.Indices {
+		docs, ok := ix.postings[idx]
+		if !ok {
+			docs = make(map[int64]float32)
+			ix.postings[idx] = docs
+		}
+		docs[id] = kept.Values[i]
+		if kept.Values[i] > ix.maxW[idx] {
+			ix.maxW[idx] = kept.Values[i]
+		}
+	}
+	ix.vectors[id] = kept
+	return nil
+}
+
+// Remove deletes id from the index and reports whether it was present.
+func (ix *Index) Remove(id int64) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.removeLocked(id)
+}
+
+// removeLocked leaves the dimension maxima as they are: they stay valid
+// upper bounds and are recomputed on load.
+func (ix *Index) removeLocked(id int64) bool {
+	v, ok := ix.vectors[id]
+	if !ok {
+		return false
+	}
+	for _, idx := range v.Indices {
+		docs := ix.postings[idx]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, idx)
+			delete(ix.maxW, idx)
+		}
+	}
+	delete(ix.vectors, id)
+	return true
+}
+
+// Contains reports whether id is indexed.
+func (ix *Index) Contains(id int64) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	_, ok := ix.vectors[id]
+	return ok
+}
+
+// Get returns the vector stored under id.
+func (ix *Index) Get(id int64) (Vector, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	v, ok := ix.vectors[id]
+	return v, ok
+}
+
+// Len returns the number of indexed vectors.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.vectors)
+}
+
+// Search returns up to k IDs with the highest dot product with query,
+// ordered by descending score (ties by ascending ID).
+//
+// Dimensions are scored term-at-a-time in order of their largest possible
+// contribution (MaxScore). Once the contributions still possible from the
+// remaining dimensions cannot lift a new ID above the current k-th score,
+// only IDs already found are scored further. Results are exact.
+func (ix *Index) Search(query Vector, k int) ([]int64, []float32, error) {
+	if err := query.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if k <= 0 {
+		return nil, nil, errors.New("k must be positive")
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	type term struct {
+		idx    uint32
+		weight float32
+		bound  float32
+	}
+	terms := make([]term, 0, len(query.Indices))
+	for i, idx := range query.Indices {
+		if w := query.Values[i]; w > 0 && len(ix.postings[idx]) > 0 {
+			terms = append(terms, term{idx, w, w * ix.maxW[idx]})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i].bound > terms[j].bound })
+	// remaining[i] bounds what dimensions i.. can add to any score
+	remaining := make([]float32, len(terms)+1)
+	for i := len(terms) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + terms[i].bound
+	}
+
+	scores := make(map[int64]float32)
+	for i, t := range terms {
+		admitNew := len(scores) < k || remaining[i] > kthScore(scores, k)
+		for id, w := range ix.postings[t.idx] {
+			if s, ok := scores[id]; ok {
+				scores[id] = s + t.weight*w
+			} else if admitNew {
+				scores[id] = t.weight * w
+			}
+		}
+	}
+
+	ids := make([]int64, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > k {
+		ids = ids[:k]
+	}
+	out := make([]float32, len(ids))
+	for i, id := range ids {
+		out[i] = scores[id]
+	}
+	return ids, out, nil
+}
+
+// kthScore returns the k-th highest of the partial scores, a lower bound of
+// the final k-th score since weights are non-negative.
+func kthScore(scores map[int64]float32, k int) float32 {
+	all := make([]float32, 0, len(scores))
+	for _, s := range scores {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] > all[j] })
+	return all[k-1]
+}
+
+// Save writes the index to path atomically (write to a temporary file, sync,
+// rename). The layout, with varint integers, is
+//
+//	magic | count | (id, nnz, (index, weight float32)*)*
+func (ix *Index) Save(path string) error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create index file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	putU := func(v uint64) { w.Write(buf[:binary.PutUvarint(buf, v)]) }
+	putI := func(v int64) { w.Write(buf[:binary.PutVarint(buf, v)]) }
+
+	w.WriteString(snapshotMagic)
+	ids := make([]int64, 0, len(ix.vectors))
+	for id := range ix.vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	putU(uint64(len(ids)))
+	for _, id := range ids {
+		v := ix.vectors[id]
+		putI(id)
+		putU(uint64(len(v.Indices)))
+		for i, idx := range v.Indices {
+			putU(uint64(idx))
+			binary.LittleEndian.PutUint32(buf[:4], math.Float32bits(v.Values[i]))
+			w.Write(buf[:4])
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write index file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync index file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads an index saved with Save.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, errors.New("not a sparse index file")
+	}
+
+	var readErr error
+	getU := func() uint64 {
+		v, err := binary.ReadUvarint(r)
+		if err != nil && readErr == nil {
+			readErr = err
+		}
+		return v
+	}
+	getI := func() int64 {
+		v, err := binary.ReadVarint(r)
+		if err != nil && readErr == nil {
+			readErr = err
+		}
+		return v
+	}
+	word := make([]byte, 4)
+
+	ix := New()
+	for n := getU(); n > 0 && readErr == nil; n-- {
+		id := getI()
+		nnz := getU()
+		if nnz > 1<<24 {
+			readErr = errors.New("corrupt sparse vector")
+			break
+		}
+		var v Vector
+		for ; nnz > 0 && readErr == nil; nnz-- {
+			idx := getU()
+			if _, err := io.ReadFull(r, word); err != nil && readErr == nil {
+				readErr = err
+			}
+			v.Indices = append(v.Indices, uint32(idx))
+			v.Values = append(v.Values, math.Float32frombits(binary.LittleEndian.Uint32(word)))
+		}
+		if readErr == nil {
+			if err := ix.Add(id, v); err != nil {
+				readErr = err
+			}
+		}
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("read sparse index: %w", readErr)
+	}
+	return ix, nil
+}
@@ -0,0 +1,146 @@
+package sparseindex
+
+import (
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSearchRanksByDotProduct(t *testing.T) {
+	ix := New()
+	ix.Add(1, Vector{Indices: []uint32{1, 5}, Values: []float32{1, 2}})
+	ix.Add(2, Vector{Indices: []uint32{5, 9}, Values: []float32{0.5, 3}})
+	ix.Add(3, Vector{Indices: []uint32{7}, Values: []float32{4}})
+
+	ids, scores, err := ix.Search(Vector{Indices: []uint32{5, 9}, Values: []float32{1, 1}}, 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{2, 1}) || !reflect.DeepEqual(scores, []float32{3.5, 2}) {
+		t.Fatalf("Search = %v %v, want [2 1] [3.5 2]", ids, scores)
+	}
+
+	// Replacing and removing keep postings in step
+	ix.Add(1, Vector{Indices: []uint32{7}, Values: []float32{1}})
+	ix.Remove(3)
+	if ids, _, _ := ix.Search(Vector{Indices: []uint32{5}, Values: []float32{1}}, 10); !reflect.DeepEqual(ids, []int64{2}) {
+		t.Fatalf("after update got %v, want [2]", ids)
+	}
+	if ids, _, _ := ix.Search(Vector{Indices: []uint32{7}, Values: []float32{1}}, 10); !reflect.DeepEqual(ids, []int64{1}) {
+		t.Fatalf("after remove got %v, want [1]", ids)
+	}
+}
+
+func TestSearchMatchesExhaustiveScoring(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	randomVector := func(nnz int) Vector {
+		seen := map[uint32]bool{}
+		var v Vector
+		for len(v.Indices) < nnz {
+			idx := uint32(rng.Intn(200))
+			if !seen[idx] {
+				seen[idx] = true
+				v.Indices = append(v.Indices, idx)
+				v.Values = append(v.Values, rng.Float32())
+			}
+		}
+		return v
+	}
+
+	ix := New()
+	docs := map[int64]Vector{}
+	for id := int64(0); id < 500; id++ {
+		docs[id] = randomVector(1 + rng.Intn(30))
+		ix.Add(id, docs[id])
+	}
+
+	for q := 0; q < 20; q++ {
+		query := randomVector(1 + rng.Intn(10))
+		k := 1 + rng.Intn(20)
+		ids, _, err := ix.Search(query, k)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+
+		type hit struct {
+			id    int64
+			score float32
+		}
+		var want []hit
+		for id, d := range docs {
+			var s float32
+			for i, qi := range query.Indices {
+				for j, di := range d.Indices {
+					if qi == di {
+						s += query.Values[i] * d.Values[j]
+					}
+				}
+			}
+			if s > 0 {
+				want = append(want, hit{id, s})
+			}
+		}
+		sort.Slice(want, func(i, j int) bool {
+			if want[i].score != want[j].score {
+				return want[i].score > want[j].score
+			}
+			return want[i].id < want[j].id
+		})
+		if len(want) > k {
+			want = want[:k]
+		}
+		if len(ids) != len(want) {
+			t.Fatalf("query %d: got %d results, want %d", q, len(ids), len(want))
+		}
+		for i := range want {
+			if ids[i] != want[i].id {
+				t.Fatalf("query %d: got %v, want %v", q, ids, want)
+			}
+		}
+	}
+}
+
+func TestVectorValidation(t *testing.T) {
+	ix := New()
+	bad := []Vector{
+		{Indices: []uint32{1, 2}, Values: []float32{1}},
+		{Indices: []uint32{1, 1}, Values: []float32{1, 2}},
+		{Indices: []uint32{1}, Values: []float32{-1}},
+	}
+	for _, v := range bad {
+		if err := ix.Add(1, v); err == nil {
+			t.Errorf("Add(%v) succeeded, want an error", v)
+		}
+	}
+	if _, _, err := ix.Search(Vector{Indices: []uint32{1}, Values: []float32{1}}, 0); err == nil {
+		t.Error("expected an error for k = 0")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	ix := New()
+	ix.Add(-3, Vector{Indices: []uint32{0, 1 << 30}, Values: []float32{0.25, 1.5}})
+	ix.Add(8, Vector{Indices: []uint32{1 << 30}, Values: []float32{2}})
+	ix.Add(9, Vector{Indices: []uint32{4}, Values: []float32{0}})
+
+	path := filepath.Join(t.TempDir(), "sparse.dat")
+	if err := ix.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", loaded.Len())
+	}
+	if v, _ := loaded.Get(-3); !reflect.DeepEqual(v, Vector{Indices: []uint32{0, 1 << 30}, Values: []float32{0.25, 1.5}}) {
+		t.Fatalf("Get(-3) = %v", v)
+	}
+	ids, scores, _ := loaded.Search(Vector{Indices: []uint32{1 << 30}, Values: []float32{1}}, 5)
+	if !reflect.DeepEqual(ids, []int64{8, -3}) || !reflect.DeepEqual(scores, []float32{2, 1.5}) {
+		t.Fatalf("Search after load = %v %v", ids, scores)
+	}
+}
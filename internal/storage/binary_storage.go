@@ -0,0 +1,364 @@
+package storage
+
+/*
+#include <stdlib.h>
+#include <faiss/c_api/IndexBinary_c.h>
+#include <faiss/c_api/IndexBinaryIVF_c.h>
+#include <faiss/c_api/index_factory_c.h>
+#include <faiss/c_api/error_c.h>
+#include <faiss/c_api/impl/AuxIndexStructures_c.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"unsafe"
+)
+
+// binaryRebuildMinStale is how many stale index entries (replaced or removed
+// vectors) a binary index holds before it is rebuilt, once they also
+// outnumber the live ones.
+const binaryRebuildMinStale = 1024
+
+// BinaryEngineImpl stores packed bit vectors (perceptual hashes,
+// binary-quantized embeddings) and searches them by Hamming distance with a
+// FAISS binary index (IndexBinaryFlat, IndexBinaryIVF or IndexBinaryHNSW).
+//
+// go-faiss has no binary indexes, so the engine calls the FAISS C API
+// directly. FAISS binary indexes number vectors sequentially and most cannot
+// remove them: the engine maps those numbers (labels) to IDs, and a replaced
+// or removed vector leaves a stale label that searches skip until the index
+// is rebuilt.
+type BinaryEngineImpl struct {
+	index     *C.FaissIndexBinary
+	indexType string
+	dimBits   int
+	codeSize  int
+	trainAt   int
+	trained   bool
+
+	data       *binaryDataFile
+	syncWrites bool
+
+	lock    sync.RWMutex
+	codes   map[int64][]byte // live vectors
+	labels  []int64          // FAISS label -> ID, -1 once stale
+	labelOf map[int64]int64  // ID -> FAISS label
+	stale   int
+
+	closeOnce sync.Once
+}
+
+var _ BinaryVectorEngine = (*BinaryEngineImpl)(nil)
+
+// NewBinaryEngine opens (or creates) a binary vector space of dimBits bits, a
+// multiple of 8, indexed with a FAISS binary index description (see
+// NormalizeBinaryIndexType). nprobe applies to IVF indexes; 0 keeps the FAISS
+// default. With syncWrites every change is synced to disk before it returns.
+func NewBinaryEngine(dataPath string, dimBits int, indexType string, nprobe int, syncWrites bool) (*BinaryEngineImpl, error) {
+	if dimBits <= 0 || dimBits%8 != 0 {
+		return nil, errors.New("binary vector dimension must be a positive multiple of 8 bits")
+	}
+	desc, err := NormalizeBinaryIndexType(indexType)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := newBinaryIndex(dimBits, desc, nprobe)
+	if err != nil {
+		return nil, err
+	}
+	data, codes, err := openBinaryDataFile(dataPath, dimBits/8)
+	if err != nil {
+		C.faiss_IndexBinary_free(idx)
+		return nil, err
+	}
+
+	be := &BinaryEngineImpl{
+		index:      idx,
+		indexType:  desc,
+		dimBits:    dimBits,
+		codeSize:   dimBits / 8,
+		trainAt:    binaryTrainCount(desc),
+		trained:    C.faiss_IndexBinary_is_trained(idx) != 0,
+		data:       data,
+		syncWrites: syncWrites,
+		codes:      codes,
+		labelOf:    make(map[int64]int64),
+	}
+	if err := be.rebuildLocked(); err != nil {
+		be.Close()
+		return nil, err
+	}
+	return be, nil
+}
+
+func newBinaryIndex(dimBits int, desc string, nprobe int) (*C.FaissIndexBinary, error) {
+	cdesc := C.CString(desc)
+	defer C.free(unsafe.Pointer(cdesc))
+	var idx *C.FaissIndexBinary
+	if c := C.faiss_index_binary_factory(&idx, C.int(dimBits), cdesc); c != 0 {
+		return nil, fmt.Errorf("create FAISS binary index: %w", lastFaissError())
+	}
+	if nprobe > 0 {
+		if ivf := C.faiss_IndexBinaryIVF_cast(idx); ivf != nil {
+			C.faiss_IndexBinaryIVF_set_nprobe(ivf, C.size_t(nprobe))
+		}
+	}
+	return idx, nil
+}
+
+// CodeSize returns the size of a packed vector in bytes.
+func (be *BinaryEngineImpl) CodeSize() int {
+	return be.codeSize
+}
+
+// InsertBinary adds or replaces the vector of an ID.
+func (be *BinaryEngineImpl) InsertBinary(id int64, code []byte) error {
+	if len(code) != be.codeSize {
+		return fmt.Errorf("binary vector has %d bytes, expected %d", len(code), be.codeSize)
+	}
+	code = append([]byte(nil), code...)
+
+	be.lock.Lock()
+	defer be.lock.Unlock()
+
+	if err := be.data.append(id, code, be.syncWrites); err != nil {
+		return fmt.Errorf("write binary vector: %w", err)
+	}
+	be.codes[id] = code
+	be.markStaleLocked(id)
+	if !be.trained {
+		return be.trainLocked()
+	}
+	if err := be.addLocked([]int64{id}, code); err != nil {
+		return err
+	}
+	return be.maybeRebuildLocked()
+}
+
+// RemoveBinary removes the vector of an ID.
+func (be *BinaryEngineImpl) RemoveBinary(id int64) error {
+	be.lock.Lock()
+	defer be.lock.Unlock()
+
+	if _, ok := be.codes[id]; !ok {
+		return fmt.Errorf("ID %d not found", id)
+	}
+	if err := be.data.append(id, nil, be.syncWrites); err != nil {
+		return fmt.Errorf("write binary vector: %w", err)
+	}
+	delete(be.codes, id)
+	be.markStaleLocked(id)
+	return be.maybeRebuildLocked()
+}
+
+// GetBinary returns the stored vector of an ID.
+func (be *BinaryEngineImpl) GetBinary(id int64) ([]byte, error) {
+	be.lock.RLock()
+	defer be.lock.RUnlock()
+	code, ok := be.codes[id]
+	if !ok {
+		return nil, fmt.Errorf("ID %d not found", id)
+	}
+	return append([]byte(nil), code...), nil
+}
+
+// SearchBinary returns the k vectors nearest to query by Hamming distance,
+// nearest first.
+func (be *BinaryEngineImpl) SearchBinary(query []byte, k int) ([]int64, []int32, error) {
+	if len(query) != be.codeSize {
+		return nil, nil, fmt.Errorf("binary query has %d bytes, expected %d", len(query), be.codeSize)
+	}
+	if k <= 0 {
+		return nil, nil, errors.New("k must be positive")
+	}
+
+	be.lock.RLock()
+	defer be.lock.RUnlock()
+
+	if !be.trained {
+		return nil, nil, fmt.Errorf("index type %s needs at least %d vectors to train, space has %d", be.indexType, be.trainAt, len(be.codes))
+	}
+	// Stale labels can take up to be.stale of the nearest slots
+	n := int64(len(be.labels))
+	searchK := int64(k + be.stale)
+	if searchK > n {
+		searchK = n
+	}
+	if searchK == 0 {
+		return nil, nil, nil
+	}
+	dists := make([]int32, searchK)
+	labels := make([]int64, searchK)
+	if c := C.faiss_IndexBinary_search(
+		be.index,
+		1,
+		(*C.uint8_t)(&query[0]),
+		C.idx_t(searchK),
+		(*C.int32_t)(&dists[0]),
+		(*C.idx_t)(unsafe.Pointer(&labels[0])),
+	); c != 0 {
+		return nil, nil, lastFaissError()
+	}
+
+	var ids []int64
+	var out []int32
+	for i, label := range labels {
+		if label < 0 || label >= n || be.labels[label] < 0 {
+			continue
+		}
+		ids = append(ids, be.labels[label])
+		out = append(out, dists[i])
+		if len(ids) == k {
+			break
+		}
+	}
+	return ids, out, nil
+}
+
+// RangeSearchBinary returns the vectors whose Hamming distance to query is
+// below radius, nearest first. Index types without range search in FAISS
+// (HNSW) fall back to an exact scan.
+func (be *BinaryEngineImpl) RangeSearchBinary(query []byte, radius int) ([]int64, []int32, error) {
+	if len(query) != be.codeSize {
+		return nil, nil, fmt.Errorf("binary query has %d bytes, expected %d", len(query), be.codeSize)
+	}
+
+	be.lock.RLock()
+	defer be.lock.RUnlock()
+
+	if !be.trained {
+		ids, dists := hammingRange(be.codes, query, radius)
+		return ids, dists, nil
+	}
+
+	var res *C.FaissRangeSearchResult
+	if c := C.faiss_RangeSearchResult_new(&res, 1); c != 0 {
+		return nil, nil, lastFaissError()
+	}
+	defer C.faiss_RangeSearchResult_free(res)
+	if c := C.faiss_IndexBinary_range_search(be.index, 1, (*C.uint8_t)(&query[0]), C.int(radius), res); c != 0 {
+		log.Printf("Binary range search falls back to an exact scan for index type %s: %v", be.indexType, lastFaissError())
+		ids, dists := hammingRange(be.codes, query, radius)
+		return ids, dists, nil
+	}
+
+	var lims *C.size_t
+	C.faiss_RangeSearchResult_lims(res, &lims)
+	var cLabels *C.idx_t
+	var cDists *C.float
+	C.faiss_RangeSearchResult_labels(res, &cLabels, &cDists)
+	count := int(unsafe.Slice(lims, 2)[1])
+	if count == 0 {
+		return nil, nil, nil
+	}
+	labels := unsafe.Slice((*int64)(unsafe.Pointer(cLabels)), count)
+	dists := unsafe.Slice((*float32)(unsafe.Pointer(cDists)), count)
+
+	n := int64(len(be.labels))
+	var ids []int64
+	var out []int32
+	for i, label := range labels {
+		if label < 0 || label >= n || be.labels[label] < 0 {
+			continue
+		}
+		ids = append(ids, be.labels[label])
+		out = append(out, int32(dists[i]))
+	}
+	sortByDistance(ids, out)
+	return ids, out, nil
+}
+
+func (be *BinaryEngineImpl) Close() error {
+	be.closeOnce.Do(func() {
+		be.lock.Lock()
+		defer be.lock.Unlock()
+		if err := be.data.close(); err != nil {
+			log.Printf("Closing binary vector data file failed: %v", err)
+		}
+		C.faiss_IndexBinary_free(be.index)
+		be.index = nil
+	})
+	return nil
+}
+
+// === Internals ===
+
+// markStaleLocked retires the index label of an ID that was replaced or
+// removed; the caller must hold be.lock.
+func (be *BinaryEngineImpl) markStaleLocked(id int64) {
+	if label, ok := be.labelOf[id]; ok {
+		be.labels[label] = -1
+		delete(be.labelOf, id)
+		be.stale++
+	}
+}
+
+// addLocked adds packed vectors to a trained index; the caller must hold
+// be.lock.
+func (be *BinaryEngineImpl) addLocked(ids []int64, data []byte) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if c := C.faiss_IndexBinary_add(be.index, C.idx_t(len(ids)), (*C.uint8_t)(&data[0])); c != 0 {
+		return fmt.Errorf("add binary vectors: %w", lastFaissError())
+	}
+	for _, id := range ids {
+		be.labelOf[id] = int64(len(be.labels))
+		be.labels = append(be.labels, id)
+	}
+	return nil
+}
+
+// trainLocked trains the index once enough vectors are stored and adds them
+// all; the caller must hold be.lock.
+func (be *BinaryEngineImpl) trainLocked() error {
+	if len(be.codes) == 0 || len(be.codes) < be.trainAt {
+		return nil
+	}
+	ids, data := be.flattenLocked()
+	if c := C.faiss_IndexBinary_train(be.index, C.idx_t(len(ids)), (*C.uint8_t)(&data[0])); c != 0 {
+		return fmt.Errorf("binary index training failed: %w", lastFaissError())
+	}
+	be.trained = true
+	return be.addLocked(ids, data)
+}
+
+// maybeRebuildLocked rebuilds the index when stale labels dominate; the
+// caller must hold be.lock.
+func (be *BinaryEngineImpl) maybeRebuildLocked() error {
+	if be.stale < binaryRebuildMinStale || be.stale < len(be.codes) {
+		return nil
+	}
+	return be.rebuildLocked()
+}
+
+// rebuildLocked empties the index and adds every live vector, training it
+// first when it needs training and enough vectors are stored; the caller
+// must hold be.lock (or own the engine, during open).
+func (be *BinaryEngineImpl) rebuildLocked() error {
+	if c := C.faiss_IndexBinary_reset(be.index); c != 0 {
+		return fmt.Errorf("reset binary index: %w", lastFaissError())
+	}
+	be.labels = nil
+	be.labelOf = make(map[int64]int64)
+	be.stale = 0
+	if !be.trained {
+		return be.trainLocked()
+	}
+	ids, data := be.flattenLocked()
+	return be.addLocked(ids, data)
+}
+
+func (be *BinaryEngineImpl) flattenLocked() ([]int64, []byte) {
+	ids := make([]int64, 0, len(be.codes))
+	data := make([]byte, 0, len(be.codes)*be.codeSize)
+	for id, code := range be.codes {
+		ids = append(ids, id)
+		data = append(data, code...)
+	}
+	return ids, data
+}
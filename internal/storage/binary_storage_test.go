@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBinaryEngine(t *testing.T) {
+	for _, indexType := range []string{"BFlat", "BHNSW16", "BIVF2"} {
+		t.Run(indexType, func(t *testing.T) {
+			path := t.TempDir() + "/binary_data.db"
+			be, err := NewBinaryEngine(path, 16, indexType, 2, true)
+			if err != nil {
+				t.Fatalf("NewBinaryEngine failed: %v", err)
+			}
+
+			codes := map[int64][]byte{
+				1: {0x00, 0x00},
+				2: {0x01, 0x00},
+				3: {0xff, 0xff},
+				4: {0x0f, 0x00},
+			}
+			for id := int64(1); id <= 4; id++ {
+				if err := be.InsertBinary(id, codes[id]); err != nil {
+					t.Fatalf("InsertBinary(%d) failed: %v", id, err)
+				}
+			}
+			if err := be.InsertBinary(5, []byte{0x00}); err == nil {
+				t.Fatal("expected an error for a short vector")
+			}
+
+			ids, dists, err := be.SearchBinary([]byte{0x00, 0x00}, 2)
+			if err != nil {
+				t.Fatalf("SearchBinary failed: %v", err)
+			}
+			if !reflect.DeepEqual(ids, []int64{1, 2}) || !reflect.DeepEqual(dists, []int32{0, 1}) {
+				t.Fatalf("SearchBinary = %v %v", ids, dists)
+			}
+
+			// Replaced and removed vectors leave stale labels that searches skip
+			if err := be.InsertBinary(1, []byte{0xff, 0xfe}); err != nil {
+				t.Fatalf("InsertBinary failed: %v", err)
+			}
+			if err := be.RemoveBinary(2); err != nil {
+				t.Fatalf("RemoveBinary failed: %v", err)
+			}
+			ids, _, _ = be.SearchBinary([]byte{0x00, 0x00}, 2)
+			if !reflect.DeepEqual(ids, []int64{4, 1}) {
+				t.Fatalf("SearchBinary after update = %v, want [4 1]", ids)
+			}
+			ids, dists, err = be.RangeSearchBinary([]byte{0xff, 0xff}, 2)
+			if err != nil {
+				t.Fatalf("RangeSearchBinary failed: %v", err)
+			}
+			if !reflect.DeepEqual(ids, []int64{3, 1}) || !reflect.DeepEqual(dists, []int32{0, 1}) {
+				t.Fatalf("RangeSearchBinary = %v %v", ids, dists)
+			}
+			be.Close()
+
+			reopened, err := NewBinaryEngine(path, 16, indexType, 2, true)
+			if err != nil {
+				t.Fatalf("reopen failed: %v", err)
+			}
+			defer reopened.Close()
+			if code, err := reopened.GetBinary(1); err != nil || !reflect.DeepEqual(code, []byte{0xff, 0xfe}) {
+				t.Fatalf("GetBinary after reopen = %x, %v", code, err)
+			}
+			if _, err := reopened.GetBinary(2); err == nil {
+				t.Fatal("removed vector came back after reopen")
+			}
+		})
+	}
+}
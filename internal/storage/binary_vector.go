@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The binary vector data file starts with a header
+//
+//	"SHBV" | version uint16 | reserved uint16
+//
+// followed by records:
+//
+//	'V' | id int64 | packed bits   vector (the last record of an ID wins)
+//	'X' | id int64                 tombstone: the ID was removed
+//
+// It is the source of truth of a binary space: the FAISS index is rebuilt
+// from it when the space is opened.
+var binaryDataMagic = []byte("SHBV")
+
+const (
+	binaryDataVersion    = 1
+	binaryDataHeaderSize = 8
+)
+
+// binaryIndexPattern matches the FAISS binary index descriptions a binary
+// space can use: BFlat, BIVF{nlist}, BHNSW{M} and BIVF{nlist}_HNSW{M}.
+var binaryIndexPattern = regexp.MustCompile(`^B(Flat|IVF[1-9][0-9]*(_HNSW[1-9][0-9]*)?|HNSW[1-9][0-9]*)$`)
+
+// NormalizeBinaryIndexType returns the FAISS binary index description for an
+// index type, accepting the dense names (Flat, IVF32, HNSW32) as well as the
+// binary ones (BFlat, BIVF32, BHNSW32). Empty means BFlat.
+func NormalizeBinaryIndexType(indexType string) (string, error) {
+	if indexType == "" {
+		return "BFlat", nil
+	}
+	desc := indexType
+	if !strings.HasPrefix(desc, "B") {
+		desc = "B" + desc
+	}
+	if !binaryIndexPattern.MatchString(desc) {
+		return "", fmt.Errorf("index type '%s' is not allowed for binary vectors (use BFlat, BIVF{n}, BHNSW{M} or BIVF{n}_HNSW{M})", indexType)
+	}
+	return desc, nil
+}
+
+// binaryTrainCount returns how many vectors a binary index needs before it can
+// be trained: the number of IVF lists, none for flat and HNSW indexes.
+func binaryTrainCount(desc string) int {
+	nlist := 0
+	fmt.Sscanf(desc, "BIVF%d", &nlist)
+	return nlist
+}
+
+// ParseBinaryVector decodes a packed bit vector of size bytes written as hex
+// or base64 (standard or URL alphabet, padded or not).
+func ParseBinaryVector(s string, size int) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 2*size {
+		if b, err := hex.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			if len(b) != size {
+				return nil, fmt.Errorf("binary vector has %d bytes, expected %d", len(b), size)
+			}
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("binary vector must be %d bytes written as hex or base64", size)
+}
+
+// FormatBinaryVector writes a packed bit vector as hex.
+func FormatBinaryVector(code []byte) string {
+	return hex.EncodeToString(code)
+}
+
+// hammingDistance counts the differing bits of two codes of equal length.
+func hammingDistance(a, b []byte) int32 {
+	d := 0
+	for len(a) >= 8 {
+		d += bits.OnesCount64(binary.LittleEndian.Uint64(a) ^ binary.LittleEndian.Uint64(b))
+		a, b = a[8:], b[8:]
+	}
+	for i := range a {
+		d += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return int32(d)
+}
+
+// hammingRange returns the IDs of codes within Hamming distance below radius
+// of query, nearest first.
+func hammingRange(codes map[int64][]byte, query []byte, radius int) ([]int64, []int32) {
+	var ids []int64
+	var dists []int32
+	for id, code := range codes {
+		if d := hammingDistance(query, code); int(d) < radius {
+			ids = append(ids, id)
+			dists = append(dists, d)
+		}
+	}
+	sortByDistance(ids, dists)
+	return ids, dists
+}
+
+// sortByDistance orders results by ascending distance, then ID.
+func sortByDistance(ids []int64, dists []int32) {
+	sort.Sort(binaryResults{ids, dists})
+}
+
+type binaryResults struct {
+	ids   []int64
+	dists []int32
+}
+
+func (r binaryResults) Len() int { return len(r.ids) }
+func (r binaryResults) Less(i, j int) bool {
+	if r.dists[i] != r.dists[j] {
+		return r.dists[i] < r.dists[j]
+	}
+	return r.ids[i] < r.ids[j]
+}
+func (r binaryResults) Swap(i, j int) {
+	r.ids[i], r.ids[j] = r.ids[j], r.ids[i]
+	r.dists[i], r.dists[j] = r.dists[j], r.dists[i]
+}
+
+// binaryDataFile is the append-only record log of a binary space.
+type binaryDataFile struct {
+	file     *os.File
+	path     string
+	codeSize int
+	records  int64
+}
+
+// openBinaryDataFile opens (or creates) a data file and returns the latest
+// code of every live ID. A partially written tail is truncated; a file with
+// mostly dead records is rewritten.
+func openBinaryDataFile(path string, codeSize int) (*binaryDataFile, map[int64][]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+	df := &binaryDataFile{file: f, path: path, codeSize: codeSize}
+	codes, err := df.load()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if dead := df.records - int64(len(codes)); dead >= compactMinDead && float64(dead) >= compactDeadRatio*float64(df.records) {
+		if err := df.rewrite(codes); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("compact binary vector data: %w", err)
+		}
+	}
+	return df, codes, nil
+}
+
+func (df *binaryDataFile) load() (map[int64][]byte, error) {
+	info, err := df.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	codes := make(map[int64][]byte)
+	if size == 0 {
+		return codes, writeBinaryDataHeader(df.file)
+	}
+	header := make([]byte, binaryDataHeaderSize)
+	if _, err := df.file.ReadAt(header, 0); err != nil || !bytes.Equal(header[:4], binaryDataMagic) {
+		return nil, errors.New("not a binary vector data file")
+	}
+	if v := binary.LittleEndian.Uint16(header[4:6]); v != binaryDataVersion {
+		return nil, fmt.Errorf("unsupported binary vector data file version %d", v)
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(df.file, binaryDataHeaderSize, size-binaryDataHeaderSize))
+	offset := int64(binaryDataHeaderSize)
+	rec := make([]byte, 9+df.codeSize)
+scan:
+	for {
+		if _, err := io.ReadFull(br, rec[:9]); err != nil {
+			break
+		}
+		id := int64(binary.LittleEndian.Uint64(rec[1:9]))
+		switch rec[0] {
+		case recordTombstone:
+			delete(codes, id)
+			offset += 9
+		case recordVector:
+			if _, err := io.ReadFull(br, rec[9:]); err != nil {
+				break scan
+			}
+			codes[id] = append([]byte(nil), rec[9:]...)
+			offset += int64(len(rec))
+		default:
+			log.Printf("Unknown binary vector record type %q at offset %d", rec[0], offset)
+			break scan
+		}
+		df.records++
+	}
+	if offset < size {
+		log.Printf("Truncating partial binary vector record at offset %d", offset)
+		if err := df.file.Truncate(offset); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// append writes a vector record, or a tombstone when code is nil.
+func (df *binaryDataFile) append(id int64, code []byte, sync bool) error {
+	if _, err := df.file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := df.file.Write(encodeBinaryRecord(id, code)); err != nil {
+		return err
+	}
+	df.records++
+	if sync {
+		return df.file.Sync()
+	}
+	return nil
+}
+
+// rewrite replaces the file with one record per live ID.
+func (df *binaryDataFile) rewrite(codes map[int64][]byte) error {
+	tmpPath := df.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := writeBinaryDataHeader(tmp); err != nil {
+		return fail(err)
+	}
+	if _, err := tmp.Seek(binaryDataHeaderSize, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	ids := make([]int64, 0, len(codes))
+	for id := range codes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	w := bufio.NewWriter(tmp)
+	for _, id := range ids {
+		if _, err := w.Write(encodeBinaryRecord(id, codes[id])); err != nil {
+			return fail(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpPath, df.path); err != nil {
+		return fail(err)
+	}
+	syncDir(filepath.Dir(df.path))
+	df.file.Close()
+	df.file = tmp
+	df.records = int64(len(ids))
+	return nil
+}
+
+func (df *binaryDataFile) close() error {
+	return df.file.Close()
+}
+
+func writeBinaryDataHeader(f *os.File) error {
+	header := make([]byte, binaryDataHeaderSize)
+	copy(header, binaryDataMagic)
+	binary.LittleEndian.PutUint16(header[4:6], binaryDataVersion)
+	if _, err := f.WriteAt(header, 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func encodeBinaryRecord(id int64, code []byte) []byte {
+	buf := make([]byte, 9+len(code))
+	buf[0] = recordVector
+	if code == nil {
+		buf[0] = recordTombstone
+	}
+	binary.LittleEndian.PutUint64(buf[1:9], uint64(id))
+	copy(buf[9:], code)
+	return buf
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseBinaryVector(t *testing.T) {
+	want := []byte{0xde, 0xad, 0xbe, 0xef}
+	for _, s := range []string{"deadbeef", "DEADBEEF", "3q2+7w==", "3q2-7w", "3q2+7w"} {
+		got, err := ParseBinaryVector(s, 4)
+		if err != nil {
+			t.Fatalf("ParseBinaryVector(%q) failed: %v", s, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ParseBinaryVector(%q) = %x, want %x", s, got, want)
+		}
+	}
+	for _, s := range []string{"deadbeef00", "3q2+7w8A", "not a vector"} {
+		if _, err := ParseBinaryVector(s, 4); err == nil {
+			t.Errorf("ParseBinaryVector(%q) succeeded, want an error", s)
+		}
+	}
+	if got := FormatBinaryVector(want); got != "deadbeef" {
+		t.Fatalf("FormatBinaryVector = %q", got)
+	}
+}
+
+func TestNormalizeBinaryIndexType(t *testing.T) {
+	cases := map[string]string{
+		"":             "BFlat",
+		"Flat":         "BFlat",
+		"BHNSW32":      "BHNSW32",
+		"IVF64":        "BIVF64",
+		"BIVF8_HNSW16": "BIVF8_HNSW16",
+	}
+	for in, want := range cases {
+		got, err := NormalizeBinaryIndexType(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeBinaryIndexType(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"PQ8", "BIVF", "HNSW0", "IVF32,Flat"} {
+		if _, err := NormalizeBinaryIndexType(bad); err == nil {
+			t.Errorf("NormalizeBinaryIndexType(%q) succeeded, want an error", bad)
+		}
+	}
+	if n := binaryTrainCount("BIVF64_HNSW8"); n != 64 {
+		t.Errorf("binaryTrainCount = %d, want 64", n)
+	}
+}
+
+func TestHammingRange(t *testing.T) {
+	codes := map[int64][]byte{
+		1: {0x00, 0x00},
+		2: {0x01, 0x00},
+		3: {0xff, 0x0f},
+		4: {0x03, 0x00},
+	}
+	if d := hammingDistance(codes[1], codes[3]); d != 12 {
+		t.Fatalf("hammingDistance = %d, want 12", d)
+	}
+	ids, dists := hammingRange(codes, []byte{0x00, 0x00}, 3)
+	if !reflect.DeepEqual(ids, []int64{1, 2, 4}) || !reflect.DeepEqual(dists, []int32{0, 1, 2}) {
+		t.Fatalf("hammingRange = %v %v", ids, dists)
+	}
+}
+
+func TestBinaryDataFile(t *testing.T) {
+	path := t.TempDir() + "/binary_data.db"
+	df, codes, err := openBinaryDataFile(path, 2)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if len(codes) != 0 {
+		t.Fatalf("new file has %d codes", len(codes))
+	}
+	df.append(1, []byte{0x01, 0x02}, true)
+	df.append(2, []byte{0x03, 0x04}, true)
+	df.append(1, []byte{0x05, 0x06}, true)
+	df.append(2, nil, true)
+	df.close()
+
+	// A torn write at the tail is dropped
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	f.Write([]byte{'V', 9, 0, 0})
+	f.Close()
+
+	df, codes, err = openBinaryDataFile(path, 2)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer df.close()
+	if !reflect.DeepEqual(codes, map[int64][]byte{1: {0x05, 0x06}}) {
+		t.Fatalf("codes = %v", codes)
+	}
+	if info, _ := os.Stat(path); info.Size() != binaryDataHeaderSize+3*11+9 {
+		t.Fatalf("file size %d after truncating the torn record", info.Size())
+	}
+}
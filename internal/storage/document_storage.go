@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shibudb.org/shibudb-server/internal/filter"
+	"github.com/shibudb.org/shibudb-server/internal/jsonpath"
+)
+
+// DocumentIDField holds the server-generated ID inside every stored document.
+const DocumentIDField = "_id"
+
+var ErrDocumentNotFound = errors.New("document not found")
+
+// DocumentStore keeps JSON documents on top of the key-value storage engine,
+// so documents share its data file, B-tree index, secondary indexes and WAL.
+type DocumentStore struct {
+	db *ShibuDB
+	// mu serializes the read-modify-write of updates and deletes
+	mu sync.Mutex
+}
+
+var _ DocumentEngine = (*DocumentStore)(nil)
+
+// NewDocumentStore opens (or creates) a document store at the given paths.
+func NewDocumentStore(dataPath, walPath, indexPath string, enableWAL bool) (*DocumentStore, error) {
+	db, err := OpenDBWithPathsAndWAL(dataPath, walPath, indexPath, enableWAL)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentStore{db: db}, nil
+}
+
+// InsertDocument stores a JSON object under a new ID and returns the ID.
+func (ds *DocumentStore) InsertDocument(doc string) (string, error) {
+	obj, err := decodeDocument(doc)
+	if err != nil {
+		return "", err
+	}
+	if _, exists := obj[DocumentIDField]; exists {
+		return "", fmt.Errorf("field '%s' is reserved", DocumentIDField)
+	}
+
+	id, err := newDocumentID()
+	if err != nil {
+		return "", err
+	}
+	obj[DocumentIDField] = id
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	if err := ds.db.Put(id, string(data)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// GetDocument returns the document with the given ID, limited to fields when
+// any are given.
+func (ds *DocumentStore) GetDocument(id string, fields []string) (string, error) {
+	obj, err := ds.load(id)
+	if err != nil {
+		return "", err
+	}
+	return encodeDocument(project(obj, fields))
+}
+
+// CreateIndex adds a secondary index on a JSON field path, which
+// FindDocuments uses to narrow the documents it evaluates.
+func (ds *DocumentStore) CreateIndex(path string) error {
+	return ds.db.CreateIndex(path)
+}
+
+// LoadIndex attaches an index created by a previous run.
+func (ds *DocumentStore) LoadIndex(path string) error {
+	return ds.db.LoadIndex(path)
+}
+
+// UpdateDocument applies a JSON merge patch (RFC 7396) to a document and
+// returns the updated document.
+func (ds *DocumentStore) UpdateDocument(id, patch string) (string, error) {
+	var p interface{}
+	dec := json.NewDecoder(strings.NewReader(patch))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return "", fmt.Errorf("invalid merge patch: %w", err)
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return "", errors.New("invalid merge patch: expected a JSON object")
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	obj, err := ds.load(id)
+	if err != nil {
+		return "", err
+	}
+	merged, _ := mergePatch(obj, p).(map[string]interface{})
+	merged[DocumentIDField] = id
+
+	data, err := encodeDocument(merged)
+	if err != nil {
+		return "", err
+	}
+	if err := ds.db.Put(id, data); err != nil {
+		return "", err
+	}
+	return data, nil
+}
+
+// DeleteDocument removes the document with the given ID.
+func (ds *DocumentStore) DeleteDocument(id string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, err := ds.load(id); err != nil {
+		return err
+	}
+	// Deletes bypass the write batch, so flush pending writes of this ID first
+	if err := ds.db.FlushBatch(); err != nil {
+		return err
+	}
+	return ds.db.Delete(id)
+}
+
+// FindDocuments returns up to limit documents matching expr (all documents
+// when expr is nil), projected to fields. A limit <= 0 means no limit. When
+// the filter constrains an indexed field, only the documents the index
+// returns are evaluated; otherwise every document is scanned.
+func (ds *DocumentStore) FindDocuments(expr filter.Expr, fields []string, limit int) ([]string, error) {
+	results := []string{}
+	var decodeErr error
+	visit := func(key, value string) bool {
+		obj, err := decodeDocument(value)
+		if err != nil {
+			decodeErr = fmt.Errorf("document %s: %w", key, err)
+			return false
+		}
+		if expr != nil && !expr.Match(obj) {
+			return true
+		}
+		data, err := encodeDocument(project(obj, fields))
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		results = append(results, data)
+		return limit <= 0 || len(results) < limit
+	}
+
+	keys, indexed, err := ds.candidateKeys(expr)
+	if err != nil {
+		return nil, err
+	}
+	if indexed {
+		for _, key := range keys {
+			value, err := ds.db.Get(key)
+			if err != nil || value == "" {
+				continue
+			}
+			if !visit(key, value) {
+				break
+			}
+		}
+	} else if err := ds.db.Scan(visit); err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return results, nil
+}
+
+// candidateKeys returns, in key order, a superset of the IDs of documents
+// matching expr, taken from the secondary indexes. It reports false when expr
+// cannot be answered from an index.
+func (ds *DocumentStore) candidateKeys(expr filter.Expr) ([]string, bool, error) {
+	keys, ok, err := ds.indexedKeys(expr)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	sort.Strings(keys)
+	return keys, true, nil
+}
+
+func (ds *DocumentStore) indexedKeys(expr filter.Expr) ([]string, bool, error) {
+	switch e := expr.(type) {
+	case filter.Compare:
+		if _, ok := encodeIndexValue(e.Value); !ok {
+			return nil, false, nil
+		}
+		var keys []string
+		var err error
+		switch e.Op {
+		case "eq":
+			keys, err = ds.db.FindEqual(e.Path, e.Value)
+		case "gt", "gte":
+			keys, err = ds.db.FindRange(e.Path, e.Value, nil)
+		case "lt", "lte":
+			keys, err = ds.db.FindRange(e.Path, nil, e.Value)
+		default:
+			return nil, false, nil
+		}
+		if errors.Is(err, ErrIndexNotFound) {
+			return nil, false, nil
+		}
+		return keys, err == nil, err
+	case filter.In:
+		var parts []filter.Expr
+		for _, v := range e.Values {
+			parts = append(parts, filter.Compare{Op: "eq", Path: e.Path, Value: v})
+		}
+		return ds.indexedKeys(filter.Or(parts))
+	case filter.And:
+		// Any indexed conjunct bounds the result
+		for _, child := range e {
+			keys, ok, err := ds.indexedKeys(child)
+			if err != nil || ok {
+				return keys, ok, err
+			}
+		}
+	case filter.Or:
+		// Every disjunct must be indexed
+		seen := make(map[string]struct{})
+		union := []string{}
+		for _, child := range e {
+			keys, ok, err := ds.indexedKeys(child)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			for _, key := range keys {
+				if _, dup := seen[key]; !dup {
+					seen[key] = struct{}{}
+					union = append(union, key)
+				}
+			}
+		}
+		return union, true, nil
+	}
+	return nil, false, nil
+}
+
+func (ds *DocumentStore) Close() error {
+	return ds.db.Close()
+}
+
+func (ds *DocumentStore) load(id string) (map[string]interface{}, error) {
+	value, err := ds.db.Get(id)
+	if err != nil || value == "" {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, id)
+	}
+	return decodeDocument(value)
+}
+
+func decodeDocument(doc string) (map[string]interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid document: expected a JSON object")
+	}
+	return obj, nil
+}
+
+func encodeDocument(obj map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(obj); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// mergePatch implements RFC 7396: object members are merged recursively,
+// null members are removed and any other patch value replaces the target.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// project keeps only the given dotted field paths (plus the ID) of a document.
+func project(obj map[string]interface{}, fields []string) map[string]interface{} {
+	if len(fields) == 0 {
+		return obj
+	}
+	out := map[string]interface{}{DocumentIDField: obj[DocumentIDField]}
+	for _, path := range fields {
+		value, found := jsonpath.Lookup(obj, path)
+		if !found {
+			continue
+		}
+		segs := strings.Split(path, ".")
+		node := out
+		for _, seg := range segs[:len(segs)-1] {
+			child, ok := node[seg].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[seg] = child
+			}
+			node = child
+		}
+		node[segs[len(segs)-1]] = value
+	}
+	return out
+}
+
+// newDocumentID returns a 24-character hex ID: a 4-byte timestamp followed by
+// 8 random bytes, so IDs sort roughly by creation time.
+func newDocumentID() (string, error) {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(time.Now().Unix()))
+	if _, err := rand.Read(buf[4:]); err != nil {
+		return "", fmt.Errorf("generate document id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
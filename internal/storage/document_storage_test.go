@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/shibudb.org/shibudb-server/internal/filter"
+)
+
+func TestDocumentStore(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/doc_data.db"
+	walPath := "testdata/doc_wal.db"
+	indexPath := "testdata/doc_index.dat"
+	os.Remove(dataPath)
+	os.Remove(walPath)
+	os.Remove(indexPath)
+	t.Cleanup(func() {
+		os.Remove(dataPath)
+		os.Remove(walPath)
+		os.Remove(indexPath)
+		os.Remove("testdata/sidx_616765.dat")
+		os.Remove("testdata/sidx_616765.dat.clean")
+	})
+
+	ds, err := NewDocumentStore(dataPath, walPath, indexPath, true)
+	if err != nil {
+		t.Fatalf("Failed to open document store: %v", err)
+	}
+	defer ds.Close()
+
+	decode := func(doc string) map[string]interface{} {
+		t.Helper()
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(doc), &obj); err != nil {
+			t.Fatalf("invalid JSON %q: %v", doc, err)
+		}
+		return obj
+	}
+
+	id, err := ds.InsertDocument(`{"name":"ana","age":30,"address":{"city":"Pune","zip":"411001"}}`)
+	if err != nil {
+		t.Fatalf("InsertDocument failed: %v", err)
+	}
+	if len(id) != 24 {
+		t.Errorf("Expected 24-character id, got %q", id)
+	}
+	if _, err := ds.InsertDocument(`{"name":"bo","age":22}`); err != nil {
+		t.Fatalf("InsertDocument failed: %v", err)
+	}
+
+	t.Run("RejectsInvalid", func(t *testing.T) {
+		if _, err := ds.InsertDocument(`[1,2]`); err == nil {
+			t.Errorf("Expected error for non-object document")
+		}
+		if _, err := ds.InsertDocument(`{"_id":"x"}`); err == nil {
+			t.Errorf("Expected error for reserved _id field")
+		}
+	})
+
+	t.Run("GetWithProjection", func(t *testing.T) {
+		doc, err := ds.GetDocument(id, []string{"address.city"})
+		if err != nil {
+			t.Fatalf("GetDocument failed: %v", err)
+		}
+		obj := decode(doc)
+		if obj["_id"] != id || obj["name"] != nil {
+			t.Errorf("Unexpected projection: %s", doc)
+		}
+		if addr, _ := obj["address"].(map[string]interface{}); addr["city"] != "Pune" || addr["zip"] != nil {
+			t.Errorf("Unexpected projection: %s", doc)
+		}
+	})
+
+	t.Run("MergePatch", func(t *testing.T) {
+		doc, err := ds.UpdateDocument(id, `{"age":31,"address":{"zip":null},"_id":"other"}`)
+		if err != nil {
+			t.Fatalf("UpdateDocument failed: %v", err)
+		}
+		obj := decode(doc)
+		if obj["age"] != float64(31) || obj["name"] != "ana" || obj["_id"] != id {
+			t.Errorf("Unexpected merge result: %s", doc)
+		}
+		if addr, _ := obj["address"].(map[string]interface{}); addr["city"] != "Pune" || len(addr) != 1 {
+			t.Errorf("Unexpected merge result: %s", doc)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		expr, err := filter.Parse(`{"gt":{"age":25}}`)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		docs, err := ds.FindDocuments(expr, []string{"name"}, 0)
+		if err != nil {
+			t.Fatalf("FindDocuments failed: %v", err)
+		}
+		if len(docs) != 1 || decode(docs[0])["name"] != "ana" {
+			t.Errorf("Unexpected results: %v", docs)
+		}
+
+		all, err := ds.FindDocuments(nil, nil, 1)
+		if err != nil || len(all) != 1 {
+			t.Errorf("Expected limit of 1 document, got %v (err %v)", all, err)
+		}
+	})
+
+	t.Run("IndexedFind", func(t *testing.T) {
+		if err := ds.CreateIndex("age"); err != nil {
+			t.Fatalf("CreateIndex failed: %v", err)
+		}
+		for _, tc := range []struct {
+			filter string
+			want   int
+		}{
+			{`{"gt":{"age":25}}`, 1},
+			{`{"lte":{"age":31}}`, 2},
+			{`{"in":{"age":[22,40]}}`, 1},
+			{`{"and":[{"eq":{"age":31}},{"eq":{"name":"bo"}}]}`, 0},
+			{`{"or":[{"eq":{"age":22}},{"eq":{"name":"ana"}}]}`, 2},
+		} {
+			expr, err := filter.Parse(tc.filter)
+			if err != nil {
+				t.Fatalf("Parse(%s) failed: %v", tc.filter, err)
+			}
+			docs, err := ds.FindDocuments(expr, nil, 0)
+			if err != nil {
+				t.Fatalf("FindDocuments(%s) failed: %v", tc.filter, err)
+			}
+			if len(docs) != tc.want {
+				t.Errorf("FindDocuments(%s) = %v, want %d documents", tc.filter, docs, tc.want)
+			}
+		}
+	})
+
+	t.Run("ConcurrentUpdates", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := ds.UpdateDocument(id, fmt.Sprintf(`{"f%d":%d}`, i, i)); err != nil {
+					t.Errorf("UpdateDocument failed: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		doc, err := ds.GetDocument(id, nil)
+		if err != nil {
+			t.Fatalf("GetDocument failed: %v", err)
+		}
+		obj := decode(doc)
+		for i := 0; i < 20; i++ {
+			if obj[fmt.Sprintf("f%d", i)] != float64(i) {
+				t.Errorf("Lost update f%d: %s", i, doc)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := ds.DeleteDocument(id); err != nil {
+			t.Fatalf("DeleteDocument failed: %v", err)
+		}
+		if _, err := ds.GetDocument(id, nil); err == nil {
+			t.Errorf("Expected error for deleted document")
+		}
+		if err := ds.DeleteDocument(id); err == nil {
+			t.Errorf("Expected error deleting a missing document")
+		}
+	})
+}
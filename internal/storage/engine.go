@@ -1,17 +1,92 @@
 package storage
 
+import "github.com/shibudb.org/shibudb-server/internal/filter"
+
 type KeyValueEngine interface {
 	Close() error
 	Put(key, value string) error
 	Get(key string) (string, error)
 	Delete(key string) error
+	CreateIndex(path string) error
+	Indexes() []string
+	FindEqual(path string, value interface{}) ([]string, error)
+	FindRange(path string, min, max interface{}) ([]string, error)
 }
 
 type VectorEngine interface {
 	InsertVector(id int64, vector []float32) error
+	InsertVectors(ids []int64, vectors [][]float32) error
+	ValidateVector(vector []float32) error
 	RemoveVector(id int64) error
 	SearchTopK(query []float32, k int) ([]int64, []float32, error)
 	RangeSearch(query []float32, radius float32) ([]int64, []float32, error)
+	SearchTopKWithOptions(query []float32, k int, opts SearchOptions) ([]int64, []float32, error)
+	SearchTopKBatch(queries [][]float32, k int, opts SearchOptions) ([][]int64, [][]float32, error)
+	RangeSearchWithOptions(query []float32, radius float32, opts SearchOptions) ([]int64, []float32, error)
 	GetVectorByID(id int64) ([]float32, error)
+	SetPayload(id int64, payload string) error
+	SetSearchDefaults(params map[string]float64) error
+	Compact() error
+	ResolveKey(key string, create bool) (int64, error)
+	VectorKey(id int64) (key string, isString bool)
+	SetParent(id int64, parent string) error
+	SearchGrouped(query []float32, k int, opts SearchOptions) ([]GroupResult, error)
+	SearchByID(positive, negative []int64, k int, opts SearchOptions) ([]int64, []float32, error)
+	RebuildIndex(sampleSize int) error
+	MigrateIndex(indexType string, metric int, done func(error)) error
+	IsRebuilding() bool
+	SampleQueries(n int) ([][]float32, error)
+	EvaluateRecall(queries [][]float32, k int, settings []map[string]float64) (*RecallReport, error)
+	Stats() (*VectorStats, error)
+	ListVectorIDs(opts ListOptions) (*VectorIDPage, error)
+	ExportVectors(opts ListOptions, fn func(ExportedVector) error) (string, error)
+	InsertVectorWithAttrs(id int64, vector []float32, attrs VectorAttrs) error
+	UpsertVector(id int64, vector []float32, attrs VectorAttrs) (bool, error)
+	InsertVectorIfAbsent(id int64, vector []float32, attrs VectorAttrs) error
+	UpdateVector(id int64, vector []float32, attrs VectorAttrs) error
+	UpdatePayload(id int64, patch string) (string, error)
+	GetPayload(id int64) (string, error)
+	SetVectorText(id int64, text string) error
+	HybridSearch(query []float32, text string, k int, opts HybridOptions) ([]int64, []float64, error)
+	Close() error
+}
+
+type DocumentEngine interface {
+	InsertDocument(doc string) (string, error)
+	GetDocument(id string, fields []string) (string, error)
+	UpdateDocument(id, patch string) (string, error)
+	DeleteDocument(id string) error
+	FindDocuments(expr filter.Expr, fields []string, limit int) ([]string, error)
+	CreateIndex(path string) error
+	Close() error
+}
+
+type TimeSeriesEngine interface {
+	AppendPoint(series string, timestamp int64, value float64, tags map[string]string) error
+	QueryRange(series string, tags map[string]string, start, end, step int64, agg string) ([]TimePoint, error)
+	Close() error
+}
+
+type TextEngine interface {
+	IndexDoc(id int64, text string) error
+	DeleteDoc(id int64) error
+	SearchText(query string, k int) ([]int64, []float64, error)
+	Close() error
+}
+
+type BinaryVectorEngine interface {
+	CodeSize() int
+	InsertBinary(id int64, code []byte) error
+	RemoveBinary(id int64) error
+	GetBinary(id int64) ([]byte, error)
+	SearchBinary(query []byte, k int) ([]int64, []int32, error)
+	RangeSearchBinary(query []byte, radius int) ([]int64, []int32, error)
+	Close() error
+}
+
+type SparseVectorEngine interface {
+	InsertSparse(id int64, indices []uint32, values []float32) error
+	DeleteSparse(id int64) error
+	SearchSparse(indices []uint32, values []float32, k int) ([]int64, []float32, error)
 	Close() error
 }
@@ -0,0 +1,199 @@
+package storage
+
+/*
+#include <stdlib.h>
+#include <faiss/c_api/Index_c.h>
+#include <faiss/c_api/AutoTune_c.h>
+#include <faiss/c_api/index_factory_c.h>
+#include <faiss/c_api/index_io_c.h>
+#include <faiss/c_api/impl/AuxIndexStructures_c.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// faissIndex is a FAISS index held through the C API. go-faiss keeps its
+// index handle unexported, and searches with parameters (ID selectors,
+// nprobe) need the handle, so the vector engine creates and loads its
+// indexes here; the methods mirror go-faiss.
+type faissIndex struct {
+	ptr *C.FaissIndex
+}
+
+// newFaissIndex builds an index from a FAISS factory description.
+func newFaissIndex(d int, description string, metric int) (*faissIndex, error) {
+	cdesc := C.CString(description)
+	defer C.free(unsafe.Pointer(cdesc))
+	idx := &faissIndex{}
+	if c := C.faiss_index_factory(&idx.ptr, C.int(d), cdesc, C.FaissMetricType(metric)); c != 0 {
+		return nil, lastFaissError()
+	}
+	return idx, nil
+}
+
+// readFaissIndex loads an index written by write.
+func readFaissIndex(path string) (*faissIndex, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	idx := &faissIndex{}
+	if c := C.faiss_read_index_fname(cpath, 0, &idx.ptr); c != 0 {
+		return nil, lastFaissError()
+	}
+	return idx, nil
+}
+
+func (idx *faissIndex) write(path string) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	if c := C.faiss_write_index_fname(idx.ptr, cpath); c != 0 {
+		return lastFaissError()
+	}
+	return nil
+}
+
+func (idx *faissIndex) D() int {
+	return int(C.faiss_Index_d(idx.ptr))
+}
+
+func (idx *faissIndex) IsTrained() bool {
+	return C.faiss_Index_is_trained(idx.ptr) != 0
+}
+
+func (idx *faissIndex) Ntotal() int64 {
+	return int64(C.faiss_Index_ntotal(idx.ptr))
+}
+
+func (idx *faissIndex) Train(x []float32) error {
+	n := len(x) / idx.D()
+	if n == 0 {
+		return nil
+	}
+	if c := C.faiss_Index_train(idx.ptr, C.idx_t(n), (*C.float)(&x[0])); c != 0 {
+		return lastFaissError()
+	}
+	return nil
+}
+
+func (idx *faissIndex) AddWithIDs(x []float32, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if c := C.faiss_Index_add_with_ids(
+		idx.ptr,
+		C.idx_t(len(ids)),
+		(*C.float)(&x[0]),
+		(*C.idx_t)(unsafe.Pointer(&ids[0])),
+	); c != 0 {
+		return lastFaissError()
+	}
+	return nil
+}
+
+// Search returns the distances and labels of the k nearest neighbours of
+// each query in x; unfilled slots have label -1.
+func (idx *faissIndex) Search(x []float32, k int64) ([]float32, []int64, error) {
+	return idx.searchWithParams(x, k, nil)
+}
+
+// searchWithParams is Search with FAISS search parameters; nil params use
+// the index settings.
+func (idx *faissIndex) searchWithParams(x []float32, k int64, params *C.FaissSearchParameters) ([]float32, []int64, error) {
+	nq := len(x) / idx.D()
+	if nq == 0 || k <= 0 {
+		return nil, nil, nil
+	}
+	dists := make([]float32, int64(nq)*k)
+	labels := make([]int64, int64(nq)*k)
+	var c C.int
+	if params == nil {
+		c = C.faiss_Index_search(
+			idx.ptr,
+			C.idx_t(nq),
+			(*C.float)(&x[0]),
+			C.idx_t(k),
+			(*C.float)(&dists[0]),
+			(*C.idx_t)(unsafe.Pointer(&labels[0])),
+		)
+	} else {
+		c = C.faiss_Index_search_with_params(
+			idx.ptr,
+			C.idx_t(nq),
+			(*C.float)(&x[0]),
+			C.idx_t(k),
+			params,
+			(*C.float)(&dists[0]),
+			(*C.idx_t)(unsafe.Pointer(&labels[0])),
+		)
+	}
+	if c != 0 {
+		return nil, nil, lastFaissError()
+	}
+	return dists, labels, nil
+}
+
+// RangeSearch returns the labels and distances of the vectors within radius
+// of a single query.
+func (idx *faissIndex) RangeSearch(x []float32, radius float32) ([]int64, []float32, error) {
+	var res *C.FaissRangeSearchResult
+	if c := C.faiss_RangeSearchResult_new(&res, 1); c != 0 {
+		return nil, nil, lastFaissError()
+	}
+	defer C.faiss_RangeSearchResult_free(res)
+	if c := C.faiss_Index_range_search(idx.ptr, 1, (*C.float)(&x[0]), C.float(radius), res); c != 0 {
+		return nil, nil, lastFaissError()
+	}
+
+	var lims *C.size_t
+	C.faiss_RangeSearchResult_lims(res, &lims)
+	n := int(unsafe.Slice(lims, 2)[1])
+	if n == 0 {
+		return nil, nil, nil
+	}
+	var clabels *C.idx_t
+	var cdists *C.float
+	C.faiss_RangeSearchResult_labels(res, &clabels, &cdists)
+	labels := make([]int64, n)
+	dists := make([]float32, n)
+	copy(labels, unsafe.Slice((*int64)(unsafe.Pointer(clabels)), n))
+	copy(dists, unsafe.Slice((*float32)(unsafe.Pointer(cdists)), n))
+	return labels, dists, nil
+}
+
+// RemoveIDs removes the vectors of the IDs and returns how many it removed.
+func (idx *faissIndex) RemoveIDs(ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var sel *C.FaissIDSelectorBatch
+	if c := C.faiss_IDSelectorBatch_new(&sel, C.size_t(len(ids)), (*C.idx_t)(unsafe.Pointer(&ids[0]))); c != 0 {
+		return 0, lastFaissError()
+	}
+	defer C.faiss_IDSelector_free((*C.FaissIDSelector)(unsafe.Pointer(sel)))
+	var removed C.size_t
+	if c := C.faiss_Index_remove_ids(idx.ptr, (*C.FaissIDSelector)(unsafe.Pointer(sel)), &removed); c != 0 {
+		return 0, lastFaissError()
+	}
+	return int(removed), nil
+}
+
+// setParameter sets an index parameter such as nprobe or efSearch through a
+// FAISS ParameterSpace, which also reaches nested indexes.
+func (idx *faissIndex) setParameter(name string, val float64) error {
+	var ps *C.FaissParameterSpace
+	if c := C.faiss_ParameterSpace_new(&ps); c != 0 {
+		return lastFaissError()
+	}
+	defer C.faiss_ParameterSpace_free(ps)
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	if c := C.faiss_ParameterSpace_set_index_parameter(ps, idx.ptr, cname, C.double(val)); c != 0 {
+		return lastFaissError()
+	}
+	return nil
+}
+
+func (idx *faissIndex) Delete() {
+	C.faiss_Index_free(idx.ptr)
+}
@@ -0,0 +1,95 @@
+package storage
+
+/*
+#include <stdlib.h>
+#include <faiss/c_api/Index_c.h>
+#include <faiss/c_api/IndexIVF_c.h>
+#include <faiss/c_api/MetaIndexes_c.h>
+#include <faiss/c_api/error_c.h>
+#include <faiss/c_api/impl/AuxIndexStructures_c.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// go-faiss does not expose search parameters (ID selectors, nprobe), so the
+// searches below pass them to the FAISS C API with the handle of a
+// faissIndex.
+
+func lastFaissError() error {
+	return errors.New(C.GoString(C.faiss_get_last_error()))
+}
+
+// searchIndexLocked runs a k-NN search on the engine's index, passing nprobe
+// as an IVF search parameter when positive; the caller must hold ve.lock.
+func (ve *VectorEngineImpl) searchIndexLocked(x []float32, k int64, nprobe int) ([]float32, []int64, error) {
+	if nprobe <= 0 {
+		return ve.idMapIndex.Search(x, k)
+	}
+	params, err := newSearchParams(ve.idMapIndex.ptr, nil, nprobe)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer C.faiss_SearchParameters_free(params)
+	return ve.idMapIndex.searchWithParams(x, k, params)
+}
+
+// searchWithSelector runs a k-NN search restricted to the given IDs, with
+// nprobe when positive. Labels of unfilled result slots are -1.
+func searchWithSelector(idx *faissIndex, x []float32, k int64, ids []int64, nprobe int) ([]float32, []int64, error) {
+	if len(ids) == 0 || k <= 0 {
+		return nil, nil, nil
+	}
+
+	var sel *C.FaissIDSelectorBatch
+	if c := C.faiss_IDSelectorBatch_new(&sel, C.size_t(len(ids)), (*C.idx_t)(unsafe.Pointer(&ids[0]))); c != 0 {
+		return nil, nil, lastFaissError()
+	}
+	defer C.faiss_IDSelector_free((*C.FaissIDSelector)(unsafe.Pointer(sel)))
+
+	params, err := newSearchParams(idx.ptr, (*C.FaissIDSelector)(unsafe.Pointer(sel)), nprobe)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer C.faiss_SearchParameters_free(params)
+	return idx.searchWithParams(x, k, params)
+}
+
+// isIVF reports whether the index, under its ID map, is an IVF index, which
+// takes nprobe as a search parameter.
+func (idx *faissIndex) isIVF() bool {
+	return ivfOf(idx.ptr) != nil
+}
+
+func ivfOf(index *C.FaissIndex) *C.FaissIndexIVF {
+	sub := index
+	if idmap := C.faiss_IndexIDMap_cast(index); idmap != nil {
+		sub = C.faiss_IndexIDMap_sub_index(idmap)
+	}
+	return C.faiss_IndexIVF_cast(sub)
+}
+
+// newSearchParams builds search parameters with the given selector, which
+// may be nil. IVF indexes require IVF parameters, which carry nprobe, or the
+// index's current nprobe when it is not positive.
+func newSearchParams(index *C.FaissIndex, sel *C.FaissIDSelector, nprobe int) (*C.FaissSearchParameters, error) {
+	if ivf := ivfOf(index); ivf != nil {
+		n := C.faiss_IndexIVF_nprobe(ivf)
+		if nprobe > 0 {
+			n = C.size_t(nprobe)
+		}
+		var p *C.FaissSearchParametersIVF
+		if c := C.faiss_SearchParametersIVF_new_with(&p, sel, n, 0); c != 0 {
+			return nil, lastFaissError()
+		}
+		return (*C.FaissSearchParameters)(unsafe.Pointer(p)), nil
+	}
+	var p *C.FaissSearchParameters
+	if c := C.faiss_SearchParameters_new(&p, sel); c != 0 {
+		return nil, lastFaissError()
+	}
+	return p, nil
+}
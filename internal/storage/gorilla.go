@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// Gorilla-style compression for time-series blocks (Pelkonen et al., VLDB 2015).
+// Timestamps are stored as delta-of-delta with variable-width buckets and
+// values as the XOR with the previous value, reusing the previous window of
+// meaningful bits when it fits.
+
+var errGorillaTruncated = errors.New("gorilla: truncated block")
+
+type bitWriter struct {
+	buf   []byte
+	nbits uint8 // bits used in the last byte
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.nbits == 0 || w.nbits == 8 {
+		w.buf = append(w.buf, 0)
+		w.nbits = 0
+	}
+	if bit {
+		w.buf[len(w.buf)-1] |= 1 << (7 - w.nbits)
+	}
+	w.nbits++
+}
+
+// writeBits writes the low n bits of v, most significant first.
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit(v&(1<<uint(i)) != 0)
+	}
+}
+
+type bitReader struct {
+	buf []byte
+	pos int // bit position
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= len(r.buf)*8 {
+		return false, errGorillaTruncated
+	}
+	b := r.buf[r.pos/8]&(1<<(7-uint(r.pos%8))) != 0
+	r.pos++
+	return b, nil
+}
+
+func (r *bitReader) readBits(n int) (uint64, error) {
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v <<= 1
+		if bit {
+			v |= 1
+		}
+	}
+	return v, nil
+}
+
+// delta-of-delta buckets: control prefix length, payload bits
+var dodBuckets = []struct {
+	prefix uint64
+	plen   int
+	nbits  int
+}{
+	{0b10, 2, 7},
+	{0b110, 3, 9},
+	{0b1110, 4, 12},
+}
+
+// encodeGorilla compresses points, which should be sorted by timestamp.
+func encodeGorilla(points []TimePoint) []byte {
+	w := &bitWriter{}
+	var prevT, prevDelta int64
+	var prevV uint64
+	prevLeading, prevTrailing := -1, 0
+
+	for i, p := range points {
+		vbits := math.Float64bits(p.Value)
+		if i == 0 {
+			w.writeBits(uint64(p.Timestamp), 64)
+			w.writeBits(vbits, 64)
+			prevT, prevV = p.Timestamp, vbits
+			continue
+		}
+
+		// Timestamp
+		delta := p.Timestamp - prevT
+		dod := delta - prevDelta
+		prevT, prevDelta = p.Timestamp, delta
+		if dod == 0 {
+			w.writeBit(false)
+		} else {
+			encoded := false
+			for _, b := range dodBuckets {
+				lo := -(int64(1) << uint(b.nbits-1)) + 1
+				hi := int64(1) << uint(b.nbits-1)
+				if dod >= lo && dod <= hi {
+					w.writeBits(b.prefix, b.plen)
+					w.writeBits(uint64(dod-lo), b.nbits)
+					encoded = true
+					break
+				}
+			}
+			if !encoded {
+				w.writeBits(0b1111, 4)
+				w.writeBits(uint64(dod), 64)
+			}
+		}
+
+		// Value
+		xor := vbits ^ prevV
+		prevV = vbits
+		if xor == 0 {
+			w.writeBit(false)
+			continue
+		}
+		w.writeBit(true)
+		leading := bits.LeadingZeros64(xor)
+		trailing := bits.TrailingZeros64(xor)
+		if leading > 31 {
+			leading = 31
+		}
+		if prevLeading >= 0 && leading >= prevLeading && trailing >= prevTrailing {
+			w.writeBit(false)
+			meaningful := 64 - prevLeading - prevTrailing
+			w.writeBits(xor>>uint(prevTrailing), meaningful)
+			continue
+		}
+		w.writeBit(true)
+		meaningful := 64 - leading - trailing
+		w.writeBits(uint64(leading), 5)
+		// 64 meaningful bits does not fit in 6 bits; it is stored as 0
+		w.writeBits(uint64(meaningful&63), 6)
+		w.writeBits(xor>>uint(trailing), meaningful)
+		prevLeading, prevTrailing = leading, trailing
+	}
+	return w.buf
+}
+
+// decodeGorilla decompresses count points from data.
+func decodeGorilla(data []byte, count int) ([]TimePoint, error) {
+	points := make([]TimePoint, 0, count)
+	r := &bitReader{buf: data}
+	var prevT, prevDelta int64
+	var prevV uint64
+	prevLeading, prevTrailing := 0, 0
+
+	for i := 0; i < count; i++ {
+		if i == 0 {
+			t, err := r.readBits(64)
+			if err != nil {
+				return nil, err
+			}
+			v, err := r.readBits(64)
+			if err != nil {
+				return nil, err
+			}
+			prevT, prevV = int64(t), v
+			points = append(points, TimePoint{Timestamp: prevT, Value: math.Float64frombits(v)})
+			continue
+		}
+
+		// Timestamp: count leading 1 bits of the control prefix (max 4)
+		ones := 0
+		for ones < 4 {
+			bit, err := r.readBit()
+			if err != nil {
+				return nil, err
+			}
+			if !bit {
+				break
+			}
+			ones++
+		}
+		var dod int64
+		switch ones {
+		case 0:
+		case 4:
+			v, err := r.readBits(64)
+			if err != nil {
+				return nil, err
+			}
+			dod = int64(v)
+		default:
+			b := dodBuckets[ones-1]
+			v, err := r.readBits(b.nbits)
+			if err != nil {
+				return nil, err
+			}
+			dod = int64(v) - (int64(1) << uint(b.nbits-1)) + 1
+		}
+		prevDelta += dod
+		prevT += prevDelta
+
+		// Value
+		bit, err := r.readBit()
+		if err != nil {
+			return nil, err
+		}
+		if bit {
+			newWindow, err := r.readBit()
+			if err != nil {
+				return nil, err
+			}
+			if newWindow {
+				l, err := r.readBits(5)
+				if err != nil {
+					return nil, err
+				}
+				m, err := r.readBits(6)
+				if err != nil {
+					return nil, err
+				}
+				if m == 0 {
+					m = 64
+				}
+				prevLeading = int(l)
+				prevTrailing = 64 - int(l) - int(m)
+			}
+			meaningful := 64 - prevLeading - prevTrailing
+			xor, err := r.readBits(meaningful)
+			if err != nil {
+				return nil, err
+			}
+			prevV ^= xor << uint(prevTrailing)
+		}
+		points = append(points, TimePoint{Timestamp: prevT, Value: math.Float64frombits(prevV)})
+	}
+	return points, nil
+}
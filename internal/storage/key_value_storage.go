@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -25,8 +26,14 @@ type ShibuDB struct {
 	quitChan     chan struct{}
 	flushRunning int32
 	closeOnce    sync.Once
+
+	// Secondary indexes on JSON value fields, keyed by JSON path.
+	indexDir  string
+	secondary map[string]*index.BTreeIndex
 }
 
+var _ KeyValueEngine = (*ShibuDB)(nil)
+
 func OpenDBWithPathsAndWAL(dataPath, walPath, indexPath string, enableWAL bool) (*ShibuDB, error) {
 	file, err := os.OpenFile(dataPath, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
@@ -47,11 +54,13 @@ func OpenDBWithPathsAndWAL(dataPath, walPath, indexPath string, enableWAL bool)
 	}
 
 	db := &ShibuDB{
-		file:     file,
-		index:    dbIndex,
-		wal:      dbWAL,
-		quitChan: make(chan struct{}),
-		batch:    make(map[string]string),
+		file:      file,
+		index:     dbIndex,
+		wal:       dbWAL,
+		quitChan:  make(chan struct{}),
+		batch:     make(map[string]string),
+		indexDir:  filepath.Dir(dataPath),
+		secondary: make(map[string]*index.BTreeIndex),
 	}
 
 	db.index.BatchLoadFromMmap()
@@ -85,11 +94,13 @@ func OpenDBWithWAL(filename string, walFilename string, enableWAL bool) (*ShibuD
 		}
 	}
 	db := &ShibuDB{
-		file:     file,
-		index:    dbIndex,
-		wal:      dbWAL,
-		quitChan: make(chan struct{}),
-		batch:    make(map[string]string),
+		file:      file,
+		index:     dbIndex,
+		wal:       dbWAL,
+		quitChan:  make(chan struct{}),
+		batch:     make(map[string]string),
+		indexDir:  filepath.Dir(filename),
+		secondary: make(map[string]*index.BTreeIndex),
 	}
 	db.index.BatchLoadFromMmap()
 	if enableWAL {
@@ -176,6 +187,9 @@ func (db *ShibuDB) FlushBatch() error {
 	}
 
 	for key, value := range batchCopy {
+		// Capture the previous value so secondary index entries can be moved
+		oldValue, hadOld := db.currentValue(key)
+
 		keyBytes := []byte(key)
 		valBytes := []byte(value)
 
@@ -208,6 +222,10 @@ func (db *ShibuDB) FlushBatch() error {
 		if err != nil {
 			return err
 		}
+
+		if err := db.updateSecondaryIndexes(key, oldValue, hadOld, value, true); err != nil {
+			return err
+		}
 	}
 
 	// Sync to flush data to disk
@@ -219,6 +237,9 @@ func (db *ShibuDB) FlushBatch() error {
 	if db.wal != nil {
 		db.wal.MarkCommitted()
 		if db.wal.ShouldCheckpoint() {
+			if err := db.compactSecondaryIndexes(); err != nil {
+				return err
+			}
 			db.wal.Clear()
 		}
 	}
@@ -294,6 +315,11 @@ func (db *ShibuDB) Delete(key string) error {
 		return errors.New("key not found")
 	}
 
+	oldValue, hadOld := db.currentValue(key)
+	if err := db.updateSecondaryIndexes(key, oldValue, hadOld, "", false); err != nil {
+		return err
+	}
+
 	db.index.Remove(key)
 	if db.wal != nil {
 		err := db.wal.WriteDelete(key)
@@ -328,6 +354,7 @@ func (db *ShibuDB) Close() error {
 			db.wal.Clear()
 			db.wal.Close()
 		}
+		db.closeSecondaryIndexes()
 		db.file.Close()
 	})
 	return nil
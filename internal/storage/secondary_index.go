@@ -0,0 +1,444 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shibudb.org/shibudb-server/internal/index"
+	"github.com/shibudb.org/shibudb-server/internal/jsonpath"
+)
+
+// Secondary index entries are stored in a BTreeIndex as
+//
+//	<type tag><sortable value> 0x00 <primary key>
+//
+// so that all keys sharing a field value are adjacent and numeric values sort
+// numerically. Values of different JSON types never compare equal. String
+// values are escaped so that the encoded value never contains 0x00 and the
+// first 0x00 always ends it.
+const (
+	indexTagNull   = '0'
+	indexTagBool   = '1'
+	indexTagNumber = '2'
+	indexTagString = '3'
+)
+
+var ErrIndexNotFound = errors.New("index not found")
+
+// CreateIndex builds a secondary index over the JSON field at path for every
+// stored value. Creating an index that already exists is a no-op.
+func (db *ShibuDB) CreateIndex(path string) error {
+	if !jsonpath.Valid(path) {
+		return fmt.Errorf("invalid json path: %q", path)
+	}
+	if err := db.FlushBatch(); err != nil {
+		return err
+	}
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, exists := db.secondary[path]; exists {
+		return nil
+	}
+	return db.buildIndexLocked(path)
+}
+
+// LoadIndex attaches the secondary index over path that a previous run left
+// on disk. The file is only trusted when the store was closed cleanly and
+// every entry decodes; otherwise the index is rebuilt from the data file.
+func (db *ShibuDB) LoadIndex(path string) error {
+	if !jsonpath.Valid(path) {
+		return fmt.Errorf("invalid json path: %q", path)
+	}
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, exists := db.secondary[path]; exists {
+		return nil
+	}
+
+	indexFile := db.secondaryIndexFile(path)
+	cleanFile := indexFile + ".clean"
+	if _, err := os.Stat(cleanFile); err == nil {
+		// The marker is written on Close; drop it so a crash before the next
+		// Close forces a rebuild.
+		if err := os.Remove(cleanFile); err != nil {
+			return fmt.Errorf("remove index marker: %w", err)
+		}
+		tree, err := index.NewBTreeIndex(indexFile)
+		if err == nil && validIndexEntries(tree) {
+			db.secondary[path] = tree
+			return nil
+		}
+		if err == nil {
+			tree.Close()
+		}
+	}
+	return db.buildIndexLocked(path)
+}
+
+// buildIndexLocked recreates the index file for path from the primary data;
+// the caller must hold db.lock.
+func (db *ShibuDB) buildIndexLocked(path string) error {
+	indexFile := db.secondaryIndexFile(path)
+	if err := os.Remove(indexFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reset index file: %w", err)
+	}
+	tree, err := index.NewBTreeIndex(indexFile)
+	if err != nil {
+		return fmt.Errorf("open index file: %w", err)
+	}
+
+	var items []index.Item
+	err = db.scanLocked(func(key, value string) bool {
+		for _, term := range indexTerms(value, path) {
+			items = append(items, index.Item{Key: term + "\x00" + key})
+		}
+		return true
+	})
+	if err != nil {
+		tree.Close()
+		return err
+	}
+	if err := tree.AddBatch(items); err != nil {
+		tree.Close()
+		return fmt.Errorf("build index: %w", err)
+	}
+
+	db.secondary[path] = tree
+	return nil
+}
+
+// validIndexEntries reports whether every entry in tree has the
+// <term> 0x00 <key> layout. Empty keys come from the zeroed tail of the
+// index file and are ignored.
+func validIndexEntries(tree *index.BTreeIndex) bool {
+	valid := true
+	tree.Ascend(func(entry string, _ int64) bool {
+		if entry == "" {
+			return true
+		}
+		sep := strings.IndexByte(entry, 0)
+		if sep < 1 || entry[0] < indexTagNull || entry[0] > indexTagString {
+			valid = false
+		}
+		return valid
+	})
+	return valid
+}
+
+// Indexes returns the JSON paths that currently have a secondary index.
+func (db *ShibuDB) Indexes() []string {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	paths := make([]string, 0, len(db.secondary))
+	for path := range db.secondary {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// FindEqual returns the keys whose value has the given field value at path.
+func (db *ShibuDB) FindEqual(path string, value interface{}) ([]string, error) {
+	term, ok := encodeIndexValue(value)
+	if !ok {
+		return nil, fmt.Errorf("value of type %T cannot be indexed", value)
+	}
+	return db.findRange(path, term+"\x00", term+"\x01")
+}
+
+// FindRange returns the keys whose field value at path lies in [min, max].
+// Either bound may be nil for an open range, but not both. Both bounds must
+// be of the same JSON type.
+func (db *ShibuDB) FindRange(path string, min, max interface{}) ([]string, error) {
+	if min == nil && max == nil {
+		return nil, errors.New("range requires at least one bound")
+	}
+
+	var start, end string
+	if min != nil {
+		term, ok := encodeIndexValue(min)
+		if !ok {
+			return nil, fmt.Errorf("value of type %T cannot be indexed", min)
+		}
+		start = term + "\x00"
+	}
+	if max != nil {
+		term, ok := encodeIndexValue(max)
+		if !ok {
+			return nil, fmt.Errorf("value of type %T cannot be indexed", max)
+		}
+		end = term + "\x01"
+	}
+
+	switch {
+	case min == nil:
+		start = end[:1]
+	case max == nil:
+		end = string(start[0] + 1)
+	case start[0] != end[0]:
+		return nil, errors.New("range bounds must have the same type")
+	}
+	return db.findRange(path, start, end)
+}
+
+func (db *ShibuDB) findRange(path, start, end string) ([]string, error) {
+	// Make pending writes visible to the index before reading it
+	if err := db.FlushBatch(); err != nil {
+		return nil, err
+	}
+
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	tree, ok := db.secondary[path]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, path)
+	}
+
+	keys := []string{}
+	seen := make(map[string]struct{})
+	tree.AscendRange(start, end, func(entry string, _ int64) bool {
+		for i := 0; i < len(entry); i++ {
+			if entry[i] == 0 {
+				key := entry[i+1:]
+				if _, dup := seen[key]; !dup {
+					seen[key] = struct{}{}
+					keys = append(keys, key)
+				}
+				break
+			}
+		}
+		return true
+	})
+	return keys, nil
+}
+
+// Scan calls fn for every live key/value pair in key order until fn returns false.
+func (db *ShibuDB) Scan(fn func(key, value string) bool) error {
+	if err := db.FlushBatch(); err != nil {
+		return err
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	return db.scanLocked(fn)
+}
+
+// scanLocked walks the primary index; the caller must hold db.lock.
+func (db *ShibuDB) scanLocked(fn func(key, value string) bool) error {
+	var scanErr error
+	db.index.Ascend(func(key string, pos int64) bool {
+		recKey, value, err := db.readRecordAt(pos)
+		if err != nil {
+			scanErr = fmt.Errorf("read record for key %q: %w", key, err)
+			return false
+		}
+		// Skip stale index slots and deleted values
+		if recKey != key || value == "" || value == "__deleted__" {
+			return true
+		}
+		return fn(key, value)
+	})
+	return scanErr
+}
+
+// currentValue returns the stored value for key when secondary indexes need
+// it; the caller must hold db.lock.
+func (db *ShibuDB) currentValue(key string) (string, bool) {
+	if len(db.secondary) == 0 {
+		return "", false
+	}
+	pos, exists := db.index.Get(key)
+	if !exists {
+		return "", false
+	}
+	recKey, value, err := db.readRecordAt(pos)
+	if err != nil || recKey != key || value == "__deleted__" {
+		return "", false
+	}
+	return value, true
+}
+
+// updateSecondaryIndexes moves key from the entries of oldValue to those of
+// newValue in every secondary index; the caller must hold db.lock. Old
+// entries are only dropped from memory; compactSecondaryIndexes rewrites
+// the files at checkpoint and close.
+func (db *ShibuDB) updateSecondaryIndexes(key, oldValue string, hadOld bool, newValue string, hasNew bool) error {
+	for path, tree := range db.secondary {
+		var oldTerms, newTerms []string
+		if hadOld {
+			oldTerms = indexTerms(oldValue, path)
+		}
+		if hasNew {
+			newTerms = indexTerms(newValue, path)
+		}
+
+		keep := make(map[string]struct{}, len(newTerms))
+		for _, term := range newTerms {
+			keep[term] = struct{}{}
+		}
+		for _, term := range oldTerms {
+			if _, ok := keep[term]; ok {
+				continue
+			}
+			tree.Discard(term + "\x00" + key)
+		}
+		for _, term := range newTerms {
+			if _, ok := tree.Get(term + "\x00" + key); ok {
+				continue
+			}
+			if err := tree.Add(term+"\x00"+key, 0); err != nil {
+				return fmt.Errorf("update index %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compactSecondaryIndexes drops the entries discarded by updates from the
+// index files; the caller must hold db.lock.
+func (db *ShibuDB) compactSecondaryIndexes() error {
+	for path, tree := range db.secondary {
+		if err := tree.Compact(); err != nil {
+			return fmt.Errorf("compact index %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// closeSecondaryIndexes closes the index files, marking those that were
+// compacted and closed cleanly as trustworthy for LoadIndex.
+func (db *ShibuDB) closeSecondaryIndexes() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	for path, tree := range db.secondary {
+		indexFile := db.secondaryIndexFile(path)
+		compactErr := tree.Compact()
+		if err := tree.Close(); err == nil && compactErr == nil {
+			os.WriteFile(indexFile+".clean", nil, 0644)
+		}
+		delete(db.secondary, path)
+	}
+}
+
+func (db *ShibuDB) secondaryIndexFile(path string) string {
+	return filepath.Join(db.indexDir, "sidx_"+hex.EncodeToString([]byte(path))+".dat")
+}
+
+// readRecordAt decodes the key/value record stored at pos in the data file.
+func (db *ShibuDB) readRecordAt(pos int64) (string, string, error) {
+	header := make([]byte, 8)
+	if _, err := db.file.ReadAt(header, pos); err != nil {
+		return "", "", err
+	}
+	keySize := binary.LittleEndian.Uint32(header[0:4])
+	valSize := binary.LittleEndian.Uint32(header[4:8])
+
+	buf := make([]byte, int(keySize)+int(valSize))
+	if _, err := db.file.ReadAt(buf, pos+8); err != nil {
+		return "", "", err
+	}
+	return string(buf[:keySize]), string(buf[keySize:]), nil
+}
+
+// indexTerms returns the encoded index terms for the field at path in a JSON
+// value. Arrays contribute one term per scalar element; values that are not
+// JSON, or lack the field, produce no terms.
+func indexTerms(value, path string) []string {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		return nil
+	}
+	field, ok := jsonpath.Lookup(doc, path)
+	if !ok {
+		return nil
+	}
+
+	if arr, isArr := field.([]interface{}); isArr {
+		terms := make([]string, 0, len(arr))
+		seen := make(map[string]struct{}, len(arr))
+		for _, elem := range arr {
+			term, ok := encodeIndexValue(elem)
+			if !ok {
+				continue
+			}
+			if _, dup := seen[term]; !dup {
+				seen[term] = struct{}{}
+				terms = append(terms, term)
+			}
+		}
+		return terms
+	}
+
+	if term, ok := encodeIndexValue(field); ok {
+		return []string{term}
+	}
+	return nil
+}
+
+// encodeIndexValue encodes a scalar JSON value so that byte order matches
+// value order within each type. Objects and arrays are not indexable.
+func encodeIndexValue(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return string(indexTagNull), true
+	case bool:
+		if val {
+			return string(indexTagBool) + "1", true
+		}
+		return string(indexTagBool) + "0", true
+	case float64:
+		return string(indexTagNumber) + sortableFloat(val), true
+	case int:
+		return string(indexTagNumber) + sortableFloat(float64(val)), true
+	case int64:
+		return string(indexTagNumber) + sortableFloat(float64(val)), true
+	case string:
+		return string(indexTagString) + escapeIndexString(val), true
+	}
+	return "", false
+}
+
+// escapeIndexString rewrites 0x00 as 0x01 0x01 and 0x01 as 0x01 0x02, which
+// keeps byte order while freeing 0x00 for the key separator.
+func escapeIndexString(s string) string {
+	if strings.IndexByte(s, 0) < 0 && strings.IndexByte(s, 1) < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case 0:
+			b.WriteString("\x01\x01")
+		case 1:
+			b.WriteString("\x01\x02")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// sortableFloat maps a float64 to a fixed-width hex string whose
+// lexicographic order matches numeric order.
+func sortableFloat(f float64) string {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) == 0 {
+		bits |= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return fmt.Sprintf("%016x", bits)
+}
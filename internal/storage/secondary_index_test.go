@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSecondaryIndex(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/sidx_data.db"
+	walPath := "testdata/sidx_wal.db"
+	indexPath := "testdata/sidx_index.dat"
+	os.Remove(dataPath)
+	os.Remove(walPath)
+	os.Remove(indexPath)
+	t.Cleanup(func() {
+		os.Remove(dataPath)
+		os.Remove(walPath)
+		os.Remove(indexPath)
+		os.Remove("testdata/sidx_737461747573.dat")
+		os.Remove("testdata/sidx_737461747573.dat.clean")
+		os.Remove("testdata/sidx_616765.dat")
+		os.Remove("testdata/sidx_616765.dat.clean")
+		os.Remove("testdata/sidx_74616773.dat")
+		os.Remove("testdata/sidx_74616773.dat.clean")
+	})
+
+	db, err := OpenDBWithPathsAndWAL(dataPath, walPath, indexPath, true)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	db.Put("u1", `{"status":"active","age":31,"tags":["a","b"]}`)
+	db.Put("u2", `{"status":"inactive","age":25,"tags":["b"]}`)
+	db.Put("u3", `{"status":"active","age":47}`)
+	db.Put("u4", `not json`)
+	if err := db.FlushBatch(); err != nil {
+		t.Fatalf("FlushBatch failed: %v", err)
+	}
+
+	for _, path := range []string{"status", "age", "tags"} {
+		if err := db.CreateIndex(path); err != nil {
+			t.Fatalf("CreateIndex(%s) failed: %v", path, err)
+		}
+	}
+
+	check := func(name string, got []string, err error, want ...string) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		sort.Strings(got)
+		if want == nil {
+			want = []string{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+
+	t.Run("Equality", func(t *testing.T) {
+		keys, err := db.FindEqual("status", "active")
+		check("status=active", keys, err, "u1", "u3")
+		keys, err = db.FindEqual("tags", "b")
+		check("tags=b", keys, err, "u1", "u2")
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		keys, err := db.FindRange("age", float64(25), float64(31))
+		check("25<=age<=31", keys, err, "u1", "u2")
+		keys, err = db.FindRange("age", float64(30), nil)
+		check("age>=30", keys, err, "u1", "u3")
+		keys, err = db.FindRange("age", nil, float64(-1))
+		check("age<=-1", keys, err)
+	})
+
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		db.Put("u2", `{"status":"active","age":26}`)
+		keys, err := db.FindEqual("status", "active")
+		check("status=active after update", keys, err, "u1", "u2", "u3")
+		keys, err = db.FindEqual("status", "inactive")
+		check("status=inactive after update", keys, err)
+
+		if err := db.Delete("u1"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		keys, err = db.FindEqual("status", "active")
+		check("status=active after delete", keys, err, "u2", "u3")
+	})
+
+	t.Run("ControlCharacters", func(t *testing.T) {
+		db.Put("c1", `{"status":"a\u0000b"}`)
+		db.Put("c2", `{"status":"a"}`)
+		db.Put("c3", `{"status":"a\u0001"}`)
+		keys, err := db.FindEqual("status", "a")
+		check("status=a", keys, err, "c2")
+		keys, err = db.FindEqual("status", "a\x00b")
+		check("status=a\\x00b", keys, err, "c1")
+		keys, err = db.FindRange("status", "a", "a\x01")
+		check("a<=status<=a\\x01", keys, err, "c1", "c2", "c3")
+	})
+
+	t.Run("EmptyString", func(t *testing.T) {
+		db.Put("e1", `{"status":""}`)
+		keys, err := db.FindEqual("status", "")
+		check("status=''", keys, err, "e1")
+	})
+
+	t.Run("MissingIndex", func(t *testing.T) {
+		if _, err := db.FindEqual("missing", "x"); err == nil {
+			t.Errorf("Expected error for missing index")
+		}
+	})
+}
+
+func TestSortableFloatOrder(t *testing.T) {
+	values := []float64{-1e9, -2.5, -1, 0, 0.5, 1, 3, 1e12}
+	for i := 1; i < len(values); i++ {
+		if sortableFloat(values[i-1]) >= sortableFloat(values[i]) {
+			t.Errorf("sortableFloat(%v) should sort before sortableFloat(%v)", values[i-1], values[i])
+		}
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/sidx_load_data.db"
+	walPath := "testdata/sidx_load_wal.db"
+	indexPath := "testdata/sidx_load_index.dat"
+	sidxPath := "testdata/sidx_737461747573.dat"
+	cleanup := func() {
+		for _, p := range []string{dataPath, walPath, indexPath, sidxPath, sidxPath + ".clean"} {
+			os.Remove(p)
+		}
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	db, err := OpenDBWithPathsAndWAL(dataPath, walPath, indexPath, true)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.Put("u1", `{"status":"active"}`)
+	db.Put("u2", `{"status":"inactive"}`)
+	if err := db.CreateIndex("status"); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	// The replaced entry stays in the file until Close compacts it
+	db.Put("u3", `{"status":"active"}`)
+	db.FlushBatch()
+	db.Put("u3", `{"status":"inactive"}`)
+	db.Close()
+
+	if _, err := os.Stat(sidxPath + ".clean"); err != nil {
+		t.Fatalf("Expected clean marker after Close: %v", err)
+	}
+	info, err := os.Stat(sidxPath)
+	if err != nil {
+		t.Fatalf("Stat index file failed: %v", err)
+	}
+
+	db, err = OpenDBWithPathsAndWAL(dataPath, walPath, indexPath, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	if err := db.LoadIndex("status"); err != nil {
+		t.Fatalf("LoadIndex failed: %v", err)
+	}
+	if _, err := os.Stat(sidxPath + ".clean"); !os.IsNotExist(err) {
+		t.Errorf("Expected clean marker to be removed while open")
+	}
+	if reloaded, err := os.Stat(sidxPath); err != nil || !reloaded.ModTime().Equal(info.ModTime()) {
+		t.Errorf("Expected index file to be reused, not rebuilt")
+	}
+	keys, err := db.FindEqual("status", "active")
+	if err != nil || !reflect.DeepEqual(keys, []string{"u1"}) {
+		t.Errorf("FindEqual after load = %v, %v; want [u1]", keys, err)
+	}
+	keys, err = db.FindEqual("status", "inactive")
+	if err != nil || !reflect.DeepEqual(keys, []string{"u2", "u3"}) {
+		t.Errorf("FindEqual after load = %v, %v; want [u2 u3]", keys, err)
+	}
+	db.Close()
+
+	// Without the marker the index is rebuilt from the data file
+	os.Remove(sidxPath + ".clean")
+	os.WriteFile(sidxPath, []byte("garbage"), 0644)
+	db, err = OpenDBWithPathsAndWAL(dataPath, walPath, indexPath, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	if err := db.LoadIndex("status"); err != nil {
+		t.Fatalf("LoadIndex failed: %v", err)
+	}
+	keys, err = db.FindEqual("status", "inactive")
+	if err != nil || !reflect.DeepEqual(keys, []string{"u2", "u3"}) {
+		t.Errorf("FindEqual after rebuild = %v, %v; want [u2 u3]", keys, err)
+	}
+}
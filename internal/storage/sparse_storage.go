@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shibudb.org/shibudb-server/internal/sparseindex"
+	"github.com/shibudb.org/shibudb-server/internal/wal"
+)
+
+// SparseEngineImpl stores sparse vectors (learned sparse embeddings such as
+// SPLADE) as (index, weight) pairs in an inverted index and ranks them by dot
+// product. Like the text engine, the index is checkpointed to indexPath and
+// changes since the last checkpoint are recovered from the WAL when it is
+// enabled.
+type SparseEngineImpl struct {
+	index     *sparseindex.Index
+	indexFile string
+	wal       *wal.WAL
+
+	// lock orders index updates with checkpoints; the index has its own lock for reads
+	lock  sync.Mutex
+	dirty int32
+
+	quitChan  chan struct{}
+	closeOnce sync.Once
+}
+
+var _ SparseVectorEngine = (*SparseEngineImpl)(nil)
+
+// NewSparseEngine loads (or creates) a sparse vector index.
+func NewSparseEngine(indexPath, walPath string, enableWAL bool) (*SparseEngineImpl, error) {
+	ix := sparseindex.New()
+	if _, err := os.Stat(indexPath); err == nil {
+		ix, err = sparseindex.Load(indexPath)
+		if err != nil {
+			return nil, fmt.Errorf("load sparse index: %w", err)
+		}
+	}
+
+	var w *wal.WAL
+	if enableWAL {
+		var err error
+		w, err = wal.OpenWAL(walPath)
+		if err != nil {
+			return nil, fmt.Errorf("open WAL: %w", err)
+		}
+	}
+
+	se := &SparseEngineImpl{
+		index:     ix,
+		indexFile: indexPath,
+		wal:       w,
+		quitChan:  make(chan struct{}),
+	}
+
+	if err := se.replayWAL(); err != nil {
+		return nil, fmt.Errorf("WAL replay failed: %w", err)
+	}
+
+	go se.autoCheckpoint()
+	return se, nil
+}
+
+// InsertSparse adds or replaces the sparse vector of an ID. Weights must be
+// finite and non-negative, and at least one must be non-zero.
+func (se *SparseEngineImpl) InsertSparse(id int64, indices []uint32, values []float32) error {
+	v := sparseindex.Vector{Indices: indices, Values: values}
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	nonZero := false
+	for _, w := range values {
+		if w != 0 {
+			nonZero = true
+			break
+		}
+	}
+	if !nonZero {
+		return errors.New("sparse vector needs at least one non-zero weight")
+	}
+
+	se.lock.Lock()
+	defer se.lock.Unlock()
+
+	if se.wal != nil {
+		if err := se.wal.WriteEntry(sparseWALKey(id), encodeSparseVector(v)); err != nil {
+			return err
+		}
+	}
+	if err := se.index.Add(id, v); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&se.dirty, 1)
+	return nil
+}
+
+// DeleteSparse removes the sparse vector of an ID.
+func (se *SparseEngineImpl) DeleteSparse(id int64) error {
+	se.lock.Lock()
+	defer se.lock.Unlock()
+
+	if !se.index.Contains(id) {
+		return fmt.Errorf("ID %d not found", id)
+	}
+	if se.wal != nil {
+		// An empty value marks a deletion
+		if err := se.wal.WriteEntry(sparseWALKey(id), ""); err != nil {
+			return err
+		}
+	}
+	se.index.Remove(id)
+	atomic.StoreInt32(&se.dirty, 1)
+	return nil
+}
+
+// SearchSparse returns up to k IDs with the highest dot product with the
+// query, ordered by descending score.
+func (se *SparseEngineImpl) SearchSparse(indices []uint32, values []float32, k int) ([]int64, []float32, error) {
+	return se.index.Search(sparseindex.Vector{Indices: indices, Values: values}, k)
+}
+
+func (se *SparseEngineImpl) Close() error {
+	se.closeOnce.Do(func() {
+		close(se.quitChan)
+		if err := se.checkpoint(); err != nil {
+			log.Printf("Final sparse index checkpoint failed: %v", err)
+		}
+		if se.wal != nil {
+			se.wal.Close()
+		}
+	})
+	return nil
+}
+
+// === Internals ===
+
+func (se *SparseEngineImpl) autoCheckpoint() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := se.checkpoint(); err != nil {
+				log.Printf("Sparse index checkpoint failed: %v", err)
+			}
+		case <-se.quitChan:
+			return
+		}
+	}
+}
+
+// checkpoint saves the index if it changed and then clears the WAL.
+func (se *SparseEngineImpl) checkpoint() error {
+	se.lock.Lock()
+	defer se.lock.Unlock()
+
+	if atomic.LoadInt32(&se.dirty) == 0 {
+		return nil
+	}
+	if err := se.index.Save(se.indexFile); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&se.dirty, 0)
+	if se.wal != nil {
+		return se.wal.Clear()
+	}
+	return nil
+}
+
+func (se *SparseEngineImpl) replayWAL() error {
+	if se.wal == nil {
+		return nil
+	}
+	records, err := se.wal.Replay()
+	if err != nil {
+		return err
+	}
+	for _, entry := range records {
+		if len(entry[0]) != 8 {
+			continue
+		}
+		id := int64(binary.LittleEndian.Uint64([]byte(entry[0])))
+		if entry[1] == "" {
+			se.index.Remove(id)
+		} else if v, ok := decodeSparseVector(entry[1]); ok {
+			if err := se.index.Add(id, v); err != nil {
+				log.Printf("Skipping invalid sparse vector for ID %d: %v", id, err)
+			}
+		}
+		se.dirty = 1
+	}
+	return se.checkpoint()
+}
+
+func sparseWALKey(id int64) string {
+	key := make([]byte, 8)
+	binary.LittleEndian.PutUint64(key, uint64(id))
+	return string(key)
+}
+
+// encodeSparseVector packs a vector for the WAL as (index uint32, weight
+// float32) pairs.
+func encodeSparseVector(v sparseindex.Vector) string {
+	buf := make([]byte, 8*len(v.Indices))
+	for i, idx := range v.Indices {
+		binary.LittleEndian.PutUint32(buf[8*i:], idx)
+		binary.LittleEndian.PutUint32(buf[8*i+4:], math.Float32bits(v.Values[i]))
+	}
+	return string(buf)
+}
+
+func decodeSparseVector(s string) (sparseindex.Vector, bool) {
+	if len(s)%8 != 0 {
+		return sparseindex.Vector{}, false
+	}
+	buf := []byte(s)
+	n := len(buf) / 8
+	v := sparseindex.Vector{Indices: make([]uint32, n), Values: make([]float32, n)}
+	for i := 0; i < n; i++ {
+		v.Indices[i] = binary.LittleEndian.Uint32(buf[8*i:])
+		v.Values[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[8*i+4:]))
+	}
+	return v, true
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSparseEngineRecovery(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	indexPath := "testdata/sparse_index.dat"
+	walPath := "testdata/sparse_wal.db"
+	os.Remove(indexPath)
+	os.Remove(walPath)
+	t.Cleanup(func() {
+		os.Remove(indexPath)
+		os.Remove(walPath)
+	})
+
+	se, err := NewSparseEngine(indexPath, walPath, true)
+	if err != nil {
+		t.Fatalf("Failed to open sparse engine: %v", err)
+	}
+	if err := se.InsertSparse(1, []uint32{10, 20}, []float32{1, 0.5}); err != nil {
+		t.Fatalf("InsertSparse failed: %v", err)
+	}
+	if err := se.InsertSparse(2, []uint32{20, 30}, []float32{2, 1}); err != nil {
+		t.Fatalf("InsertSparse failed: %v", err)
+	}
+	if err := se.InsertSparse(3, []uint32{10}, []float32{0}); err == nil {
+		t.Fatal("expected an error for an all-zero vector")
+	}
+	if err := se.checkpoint(); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+
+	// Changes after the checkpoint live only in the WAL
+	if err := se.InsertSparse(3, []uint32{10, 30}, []float32{3, 1}); err != nil {
+		t.Fatalf("InsertSparse failed: %v", err)
+	}
+	if err := se.DeleteSparse(1); err != nil {
+		t.Fatalf("DeleteSparse failed: %v", err)
+	}
+	if err := se.DeleteSparse(42); err == nil {
+		t.Fatal("expected an error deleting a missing vector")
+	}
+
+	// Simulate a crash: stop without the final checkpoint
+	se.closeOnce.Do(func() {
+		close(se.quitChan)
+		se.wal.Close()
+	})
+
+	reopened, err := NewSparseEngine(indexPath, walPath, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen sparse engine: %v", err)
+	}
+	defer reopened.Close()
+
+	ids, scores, err := reopened.SearchSparse([]uint32{10, 20}, []float32{1, 1}, 10)
+	if err != nil {
+		t.Fatalf("SearchSparse failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{3, 2}) || !reflect.DeepEqual(scores, []float32{3, 2}) {
+		t.Fatalf("unexpected results %v %v", ids, scores)
+	}
+}
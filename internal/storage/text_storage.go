@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shibudb.org/shibudb-server/internal/textindex"
+	"github.com/shibudb.org/shibudb-server/internal/wal"
+)
+
+// TextEngineImpl is a full-text engine: documents are int64 IDs with a text
+// body, kept in a positional inverted index and ranked with BM25. The index
+// is checkpointed to indexPath; changes since the last checkpoint are
+// recovered from the WAL when it is enabled.
+type TextEngineImpl struct {
+	index     *textindex.Index
+	indexFile string
+	wal       *wal.WAL
+
+	// lock orders index updates with checkpoints; the index has its own lock for reads
+	lock  sync.Mutex
+	dirty int32
+
+	quitChan  chan struct{}
+	closeOnce sync.Once
+}
+
+var _ TextEngine = (*TextEngineImpl)(nil)
+
+// NewTextEngine loads (or creates) a text index. The analyzer only applies to
+// a new index; an existing index keeps the analyzer it was built with.
+func NewTextEngine(indexPath, walPath, analyzer string, enableWAL bool) (*TextEngineImpl, error) {
+	var ix *textindex.Index
+	if _, err := os.Stat(indexPath); err == nil {
+		ix, err = textindex.Load(indexPath)
+		if err != nil {
+			return nil, fmt.Errorf("load text index: %w", err)
+		}
+	} else {
+		a, err := textindex.NewAnalyzer(analyzer)
+		if err != nil {
+			return nil, err
+		}
+		ix = textindex.New(a)
+	}
+
+	var w *wal.WAL
+	if enableWAL {
+		var err error
+		w, err = wal.OpenWAL(walPath)
+		if err != nil {
+			return nil, fmt.Errorf("open WAL: %w", err)
+		}
+	}
+
+	te := &TextEngineImpl{
+		index:     ix,
+		indexFile: indexPath,
+		wal:       w,
+		quitChan:  make(chan struct{}),
+	}
+
+	if err := te.replayWAL(); err != nil {
+		return nil, fmt.Errorf("WAL replay failed: %w", err)
+	}
+
+	go te.autoCheckpoint()
+	return te, nil
+}
+
+// IndexDoc adds or replaces the text of a document.
+func (te *TextEngineImpl) IndexDoc(id int64, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return errors.New("document text required")
+	}
+
+	te.lock.Lock()
+	defer te.lock.Unlock()
+
+	if te.wal != nil {
+		if err := te.wal.WriteEntry(textWALKey(id), text); err != nil {
+			return err
+		}
+	}
+	te.index.Add(id, text)
+	atomic.StoreInt32(&te.dirty, 1)
+	return nil
+}
+
+// DeleteDoc removes a document from the index.
+func (te *TextEngineImpl) DeleteDoc(id int64) error {
+	te.lock.Lock()
+	defer te.lock.Unlock()
+
+	if !te.index.Contains(id) {
+		return fmt.Errorf("ID %d not found", id)
+	}
+	if te.wal != nil {
+		// An empty value marks a deletion
+		if err := te.wal.WriteEntry(textWALKey(id), ""); err != nil {
+			return err
+		}
+	}
+	te.index.Remove(id)
+	atomic.StoreInt32(&te.dirty, 1)
+	return nil
+}
+
+// SearchText returns the IDs of up to k documents matching query, ranked by
+// BM25 score.
+func (te *TextEngineImpl) SearchText(query string, k int) ([]int64, []float64, error) {
+	return te.index.Search(query, k)
+}
+
+func (te *TextEngineImpl) Close() error {
+	te.closeOnce.Do(func() {
+		close(te.quitChan)
+		if err := te.checkpoint(); err != nil {
+			log.Printf("Final text index checkpoint failed: %v", err)
+		}
+		if te.wal != nil {
+			te.wal.Close()
+		}
+	})
+	return nil
+}
+
+// === Internals ===
+
+func (te *TextEngineImpl) autoCheckpoint() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := te.checkpoint(); err != nil {
+				log.Printf("Text index checkpoint failed: %v", err)
+			}
+		case <-te.quitChan:
+			return
+		}
+	}
+}
+
+// checkpoint saves the index if it changed and then clears the WAL.
+func (te *TextEngineImpl) checkpoint() error {
+	te.lock.Lock()
+	defer te.lock.Unlock()
+
+	if atomic.LoadInt32(&te.dirty) == 0 {
+		return nil
+	}
+	if err := te.index.Save(te.indexFile); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&te.dirty, 0)
+	if te.wal != nil {
+		return te.wal.Clear()
+	}
+	return nil
+}
+
+func (te *TextEngineImpl) replayWAL() error {
+	if te.wal == nil {
+		return nil
+	}
+	records, err := te.wal.Replay()
+	if err != nil {
+		return err
+	}
+	for _, entry := range records {
+		if len(entry[0]) != 8 {
+			continue
+		}
+		id := int64(binary.LittleEndian.Uint64([]byte(entry[0])))
+		if entry[1] == "" {
+			te.index.Remove(id)
+		} else {
+			te.index.Add(id, entry[1])
+		}
+		te.dirty = 1
+	}
+	return te.checkpoint()
+}
+
+func textWALKey(id int64) string {
+	key := make([]byte, 8)
+	binary.LittleEndian.PutUint64(key, uint64(id))
+	return string(key)
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTextEngineRecovery(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	indexPath := "testdata/text_index.dat"
+	walPath := "testdata/text_wal.db"
+	os.Remove(indexPath)
+	os.Remove(walPath)
+	t.Cleanup(func() {
+		os.Remove(indexPath)
+		os.Remove(walPath)
+	})
+
+	te, err := NewTextEngine(indexPath, walPath, "english", true)
+	if err != nil {
+		t.Fatalf("Failed to open text engine: %v", err)
+	}
+	if err := te.IndexDoc(1, "Red running shoes"); err != nil {
+		t.Fatalf("IndexDoc failed: %v", err)
+	}
+	if err := te.IndexDoc(2, "Blue running shorts"); err != nil {
+		t.Fatalf("IndexDoc failed: %v", err)
+	}
+	if err := te.IndexDoc(3, "   "); err == nil {
+		t.Fatal("expected an error for empty text")
+	}
+	if err := te.checkpoint(); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+
+	// Changes after the checkpoint live only in the WAL
+	if err := te.IndexDoc(3, "Red shorts"); err != nil {
+		t.Fatalf("IndexDoc failed: %v", err)
+	}
+	if err := te.DeleteDoc(1); err != nil {
+		t.Fatalf("DeleteDoc failed: %v", err)
+	}
+	if err := te.DeleteDoc(42); err == nil {
+		t.Fatal("expected an error deleting a missing document")
+	}
+
+	// Simulate a crash: stop without the final checkpoint
+	te.closeOnce.Do(func() {
+		close(te.quitChan)
+		te.wal.Close()
+	})
+
+	reopened, err := NewTextEngine(indexPath, walPath, "standard", true)
+	if err != nil {
+		t.Fatalf("Failed to reopen text engine: %v", err)
+	}
+	defer reopened.Close()
+
+	ids, scores, err := reopened.SearchText("red shorts", 10)
+	if err != nil {
+		t.Fatalf("SearchText failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{3, 2}) || scores[0] <= scores[1] {
+		t.Fatalf("unexpected results %v %v", ids, scores)
+	}
+	// The english analyzer recorded in the index is kept ("shoes" -> "shoe")
+	if ids, _, _ := reopened.SearchText("shoe", 10); len(ids) != 0 {
+		t.Fatalf("expected deleted document to stay deleted, got %v", ids)
+	}
+	if ids, _, _ := reopened.SearchText("short", 10); len(ids) != 2 {
+		t.Fatalf("expected stemmed match on both documents, got %v", ids)
+	}
+}
@@ -0,0 +1,789 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shibudb.org/shibudb-server/internal/wal"
+)
+
+// TimeSeriesEngineImpl stores (series, timestamp, value, tags) points in
+// time-partitioned files. Each partition file is a sequence of Gorilla
+// compressed blocks, one per series per flush:
+//
+//	keyLen uint16 | seriesKey | count uint32 | minT int64 | maxT int64 | dataLen uint32 | data
+//
+// Incoming points are buffered in memory (and in the WAL when enabled) until
+// maxHead points are buffered or the oldest is maxHeadAge old. Without the
+// WAL, a crash loses the buffered points. Partitions whose series have piled
+// up several blocks are compacted to one block per series in the background.
+// Timestamps are Unix milliseconds.
+type TimeSeriesEngineImpl struct {
+	dir           string
+	wal           *wal.WAL
+	partitionSize int64 // ms
+	retention     int64 // ms, 0 keeps data forever
+
+	lock       sync.RWMutex
+	partitions map[int64]*tsPartition // partition start -> partition
+	series     map[string]tsSeries    // series key -> identity
+	head       map[string][]TimePoint // series key -> unflushed points
+	headCount  int
+	headSince  time.Time // when the oldest buffered point arrived
+	maxHead    int
+	maxHeadAge time.Duration
+
+	quitChan  chan struct{}
+	closeOnce sync.Once
+}
+
+// TimePoint is a single sample, or one aggregated bucket keyed by its start.
+type TimePoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type tsSeries struct {
+	name string
+	tags map[string]string
+}
+
+type tsPartition struct {
+	start  int64
+	file   *os.File
+	blocks []tsBlock
+}
+
+type tsBlock struct {
+	series string
+	count  int
+	minT   int64
+	maxT   int64
+	offset int64 // offset of the compressed data
+	length int
+}
+
+const tsBlockHeaderSize = 4 + 8 + 8 + 4 // after the series key
+
+// tsCompactBlocks is the number of blocks a series may have in a partition
+// before the partition is compacted; partitions that have ended are
+// compacted as soon as any series has more than one block.
+const tsCompactBlocks = 16
+
+var _ TimeSeriesEngine = (*TimeSeriesEngineImpl)(nil)
+
+var allowedAggregations = []string{"min", "max", "avg", "sum", "count"}
+
+// NewTimeSeriesEngine opens the partitions under dir and replays the WAL.
+// partitionSize and retention are durations; a zero retention disables expiry.
+func NewTimeSeriesEngine(dir, walPath string, partitionSize, retention time.Duration, enableWAL bool) (*TimeSeriesEngineImpl, error) {
+	if partitionSize <= 0 {
+		return nil, errors.New("partition size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create partition dir: %w", err)
+	}
+
+	var w *wal.WAL
+	if enableWAL {
+		var err error
+		w, err = wal.OpenWAL(walPath)
+		if err != nil {
+			return nil, fmt.Errorf("open WAL: %w", err)
+		}
+	}
+
+	ts := &TimeSeriesEngineImpl{
+		dir:           dir,
+		wal:           w,
+		partitionSize: partitionSize.Milliseconds(),
+		retention:     retention.Milliseconds(),
+		partitions:    make(map[int64]*tsPartition),
+		series:        make(map[string]tsSeries),
+		head:          make(map[string][]TimePoint),
+		maxHead:       8192,
+		maxHeadAge:    30 * time.Second,
+		quitChan:      make(chan struct{}),
+	}
+
+	if err := ts.loadPartitions(); err != nil {
+		return nil, err
+	}
+	if err := ts.replayWAL(); err != nil {
+		return nil, fmt.Errorf("WAL replay failed: %w", err)
+	}
+	ts.dropExpired()
+
+	go ts.autoFlush()
+	go ts.autoRetention()
+
+	return ts, nil
+}
+
+// AppendPoint adds one point. A zero timestamp means "now".
+func (ts *TimeSeriesEngineImpl) AppendPoint(series string, timestamp int64, value float64, tags map[string]string) error {
+	if err := validateSeries(series, tags); err != nil {
+		return err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return errors.New("value must be a finite number")
+	}
+	if timestamp == 0 {
+		timestamp = time.Now().UnixMilli()
+	}
+	if ts.retention > 0 && timestamp < time.Now().UnixMilli()-ts.retention {
+		return errors.New("timestamp is outside the retention period")
+	}
+
+	key := seriesKey(series, tags)
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	if ts.wal != nil {
+		buf := make([]byte, 16)
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(timestamp))
+		binary.LittleEndian.PutUint64(buf[8:16], math.Float64bits(value))
+		if err := ts.wal.WriteEntry(key, string(buf)); err != nil {
+			return err
+		}
+	}
+
+	ts.appendLocked(key, TimePoint{Timestamp: timestamp, Value: value})
+	if ts.headCount >= ts.maxHead {
+		return ts.flushLocked()
+	}
+	return nil
+}
+
+// QueryRange returns the points of every series named series whose tags
+// include all of tags, with start <= timestamp < end (end <= 0 means no upper
+// bound). With step > 0, points are grouped into step-wide buckets aligned to
+// the epoch and reduced with agg (min, max, avg, sum or count); otherwise the
+// raw points are returned in timestamp order.
+func (ts *TimeSeriesEngineImpl) QueryRange(series string, tags map[string]string, start, end, step int64, agg string) ([]TimePoint, error) {
+	if end <= 0 {
+		end = math.MaxInt64
+	}
+	if end <= start {
+		return nil, errors.New("end must be after start")
+	}
+	if step > 0 {
+		if agg == "" {
+			agg = "avg"
+		}
+		if !isAllowedAggregation(agg) {
+			return nil, fmt.Errorf("aggregation '%s' is not allowed", agg)
+		}
+	}
+
+	ts.lock.RLock()
+	points, err := ts.collectLocked(series, tags, start, end)
+	ts.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	if step <= 0 {
+		return points, nil
+	}
+	return aggregatePoints(points, step, agg), nil
+}
+
+func (ts *TimeSeriesEngineImpl) Close() error {
+	ts.closeOnce.Do(func() {
+		close(ts.quitChan)
+
+		ts.lock.Lock()
+		defer ts.lock.Unlock()
+		if err := ts.flushLocked(); err != nil {
+			log.Printf("Final time-series flush failed: %v", err)
+		}
+		for _, p := range ts.partitions {
+			p.file.Close()
+		}
+		if ts.wal != nil {
+			ts.wal.Close()
+		}
+	})
+	return nil
+}
+
+// === Internals ===
+
+func (ts *TimeSeriesEngineImpl) appendLocked(key string, p TimePoint) {
+	if _, ok := ts.series[key]; !ok {
+		name, tags := parseSeriesKey(key)
+		ts.series[key] = tsSeries{name: name, tags: tags}
+	}
+	if ts.headCount == 0 {
+		ts.headSince = time.Now()
+	}
+	ts.head[key] = append(ts.head[key], p)
+	ts.headCount++
+}
+
+func (ts *TimeSeriesEngineImpl) collectLocked(series string, tags map[string]string, start, end int64) ([]TimePoint, error) {
+	matched := make(map[string]bool)
+	for key, s := range ts.series {
+		if s.name == series && tagsMatch(s.tags, tags) {
+			matched[key] = true
+		}
+	}
+
+	points := []TimePoint{}
+	inRange := func(pts []TimePoint) {
+		for _, p := range pts {
+			if p.Timestamp >= start && p.Timestamp < end {
+				points = append(points, p)
+			}
+		}
+	}
+
+	for _, part := range ts.partitions {
+		if part.start+ts.partitionSize <= start || part.start >= end {
+			continue
+		}
+		for _, b := range part.blocks {
+			if !matched[b.series] || b.maxT < start || b.minT >= end {
+				continue
+			}
+			data := make([]byte, b.length)
+			if _, err := part.file.ReadAt(data, b.offset); err != nil {
+				return nil, fmt.Errorf("read block: %w", err)
+			}
+			pts, err := decodeGorilla(data, b.count)
+			if err != nil {
+				return nil, err
+			}
+			inRange(pts)
+		}
+	}
+	for key := range matched {
+		inRange(ts.head[key])
+	}
+	return points, nil
+}
+
+func aggregatePoints(points []TimePoint, step int64, agg string) []TimePoint {
+	out := []TimePoint{}
+	var bucket int64
+	var count int
+	var min, max, sum float64
+
+	emit := func() {
+		if count == 0 {
+			return
+		}
+		var v float64
+		switch agg {
+		case "min":
+			v = min
+		case "max":
+			v = max
+		case "sum":
+			v = sum
+		case "count":
+			v = float64(count)
+		default:
+			v = sum / float64(count)
+		}
+		out = append(out, TimePoint{Timestamp: bucket, Value: v})
+	}
+
+	for _, p := range points {
+		b := floorDiv(p.Timestamp, step) * step
+		if count == 0 || b != bucket {
+			emit()
+			bucket, count = b, 0
+			min, max, sum = p.Value, p.Value, 0
+		}
+		count++
+		sum += p.Value
+		if p.Value < min {
+			min = p.Value
+		}
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	emit()
+	return out
+}
+
+// flushLocked compresses the buffered points into their partitions and
+// clears the WAL. The caller must hold ts.lock.
+func (ts *TimeSeriesEngineImpl) flushLocked() error {
+	if ts.headCount == 0 {
+		return nil
+	}
+
+	touched := make(map[int64]*tsPartition)
+	for key, pts := range ts.head {
+		sort.SliceStable(pts, func(i, j int) bool { return pts[i].Timestamp < pts[j].Timestamp })
+
+		// Split the sorted points at partition boundaries
+		for len(pts) > 0 {
+			start := floorDiv(pts[0].Timestamp, ts.partitionSize) * ts.partitionSize
+			n := sort.Search(len(pts), func(i int) bool { return pts[i].Timestamp >= start+ts.partitionSize })
+			part, err := ts.partition(start)
+			if err != nil {
+				return err
+			}
+			if err := ts.appendBlock(part, key, pts[:n]); err != nil {
+				return err
+			}
+			touched[start] = part
+			pts = pts[n:]
+		}
+	}
+
+	for _, part := range touched {
+		if err := part.file.Sync(); err != nil {
+			return fmt.Errorf("sync partition: %w", err)
+		}
+	}
+
+	ts.head = make(map[string][]TimePoint)
+	ts.headCount = 0
+	if ts.wal != nil {
+		return ts.wal.Clear()
+	}
+	return nil
+}
+
+func (ts *TimeSeriesEngineImpl) appendBlock(part *tsPartition, key string, pts []TimePoint) error {
+	pos, err := part.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	b, err := writeBlock(part.file, pos, key, pts)
+	if err != nil {
+		return err
+	}
+	part.blocks = append(part.blocks, b)
+	return nil
+}
+
+// writeBlock encodes sorted points as one block at pos in f.
+func writeBlock(f *os.File, pos int64, key string, pts []TimePoint) (tsBlock, error) {
+	data := encodeGorilla(pts)
+
+	buf := make([]byte, 2+len(key)+tsBlockHeaderSize+len(data))
+	binary.LittleEndian.PutUint16(buf[0:2], uint16(len(key)))
+	off := 2 + copy(buf[2:], key)
+	binary.LittleEndian.PutUint32(buf[off:], uint32(len(pts)))
+	binary.LittleEndian.PutUint64(buf[off+4:], uint64(pts[0].Timestamp))
+	binary.LittleEndian.PutUint64(buf[off+12:], uint64(pts[len(pts)-1].Timestamp))
+	binary.LittleEndian.PutUint32(buf[off+20:], uint32(len(data)))
+	copy(buf[off+tsBlockHeaderSize:], data)
+
+	if _, err := f.WriteAt(buf, pos); err != nil {
+		return tsBlock{}, fmt.Errorf("write block: %w", err)
+	}
+	return tsBlock{
+		series: key,
+		count:  len(pts),
+		minT:   pts[0].Timestamp,
+		maxT:   pts[len(pts)-1].Timestamp,
+		offset: pos + int64(off+tsBlockHeaderSize),
+		length: len(data),
+	}, nil
+}
+
+// compactPartitions rewrites the partitions that need it (see
+// tsCompactBlocks) with one block per series.
+func (ts *TimeSeriesEngineImpl) compactPartitions() {
+	now := time.Now().UnixMilli()
+
+	ts.lock.RLock()
+	var due []*tsPartition
+	for _, part := range ts.partitions {
+		limit := tsCompactBlocks
+		if part.start+ts.partitionSize <= now {
+			limit = 2
+		}
+		perSeries := make(map[string]int)
+		for _, b := range part.blocks {
+			perSeries[b.series]++
+			if perSeries[b.series] >= limit {
+				due = append(due, part)
+				break
+			}
+		}
+	}
+	ts.lock.RUnlock()
+
+	for _, part := range due {
+		if err := ts.compactPartition(part); err != nil {
+			log.Printf("Time-series compaction of partition %d failed: %v", part.start, err)
+		}
+	}
+}
+
+// compactPartition merges the blocks of each series in part into one. The
+// existing blocks are read and rewritten into a new file under the read lock;
+// the write lock is only taken to copy blocks flushed in the meantime and
+// swap the files.
+func (ts *TimeSeriesEngineImpl) compactPartition(part *tsPartition) error {
+	ts.lock.RLock()
+	snapshot := append([]tsBlock(nil), part.blocks...)
+	merged := make(map[string][]TimePoint)
+	var keys []string
+	for _, b := range snapshot {
+		data := make([]byte, b.length)
+		if _, err := part.file.ReadAt(data, b.offset); err != nil {
+			ts.lock.RUnlock()
+			return fmt.Errorf("read block: %w", err)
+		}
+		pts, err := decodeGorilla(data, b.count)
+		if err != nil {
+			ts.lock.RUnlock()
+			return err
+		}
+		if _, ok := merged[b.series]; !ok {
+			keys = append(keys, b.series)
+		}
+		merged[b.series] = append(merged[b.series], pts...)
+	}
+	ts.lock.RUnlock()
+
+	path := part.file.Name()
+	tmp, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("create compacted partition: %w", err)
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	var blocks []tsBlock
+	pos := int64(0)
+	for _, key := range keys {
+		pts := merged[key]
+		sort.SliceStable(pts, func(i, j int) bool { return pts[i].Timestamp < pts[j].Timestamp })
+		b, err := writeBlock(tmp, pos, key, pts)
+		if err != nil {
+			return fail(err)
+		}
+		blocks = append(blocks, b)
+		pos = b.offset + int64(b.length)
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	select {
+	case <-ts.quitChan:
+		return fail(nil)
+	default:
+	}
+	if ts.partitions[part.start] != part {
+		// Dropped by retention while compacting
+		return fail(nil)
+	}
+	for _, b := range part.blocks[len(snapshot):] {
+		data := make([]byte, b.length)
+		if _, err := part.file.ReadAt(data, b.offset); err != nil {
+			return fail(fmt.Errorf("read block: %w", err))
+		}
+		pts, err := decodeGorilla(data, b.count)
+		if err != nil {
+			return fail(err)
+		}
+		nb, err := writeBlock(tmp, pos, b.series, pts)
+		if err != nil {
+			return fail(err)
+		}
+		blocks = append(blocks, nb)
+		pos = nb.offset + int64(nb.length)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(fmt.Errorf("sync compacted partition: %w", err))
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fail(fmt.Errorf("replace partition: %w", err))
+	}
+	part.file.Close()
+	part.file = tmp
+	part.blocks = blocks
+	return nil
+}
+
+// partition returns the partition starting at start, creating its file if needed.
+func (ts *TimeSeriesEngineImpl) partition(start int64) (*tsPartition, error) {
+	if part, ok := ts.partitions[start]; ok {
+		return part, nil
+	}
+	path := filepath.Join(ts.dir, fmt.Sprintf("partition_%d.tsp", start))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("open partition: %w", err)
+	}
+	part := &tsPartition{start: start, file: f}
+	ts.partitions[start] = part
+	return part, nil
+}
+
+func (ts *TimeSeriesEngineImpl) loadPartitions() error {
+	entries, err := os.ReadDir(ts.dir)
+	if err != nil {
+		return fmt.Errorf("read partition dir: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tsp.tmp") {
+			// Left behind by an interrupted compaction
+			os.Remove(filepath.Join(ts.dir, name))
+			continue
+		}
+		if !strings.HasPrefix(name, "partition_") || !strings.HasSuffix(name, ".tsp") {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, "partition_"), ".tsp"), 10, 64)
+		if err != nil {
+			continue
+		}
+		part, err := ts.partition(start)
+		if err != nil {
+			return err
+		}
+		if err := ts.loadBlocks(part); err != nil {
+			return fmt.Errorf("load %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// loadBlocks indexes the blocks of a partition file, truncating a partially
+// written tail block.
+func (ts *TimeSeriesEngineImpl) loadBlocks(part *tsPartition) error {
+	info, err := part.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	offset := int64(0)
+
+	for offset < size {
+		lenBuf := make([]byte, 2)
+		if _, err := part.file.ReadAt(lenBuf, offset); err != nil {
+			break
+		}
+		keyLen := int64(binary.LittleEndian.Uint16(lenBuf))
+		header := make([]byte, keyLen+tsBlockHeaderSize)
+		if _, err := part.file.ReadAt(header, offset+2); err != nil {
+			break
+		}
+		key := string(header[:keyLen])
+		h := header[keyLen:]
+		b := tsBlock{
+			series: key,
+			count:  int(binary.LittleEndian.Uint32(h[0:4])),
+			minT:   int64(binary.LittleEndian.Uint64(h[4:12])),
+			maxT:   int64(binary.LittleEndian.Uint64(h[12:20])),
+			length: int(binary.LittleEndian.Uint32(h[20:24])),
+			offset: offset + 2 + keyLen + tsBlockHeaderSize,
+		}
+		if b.offset+int64(b.length) > size {
+			break
+		}
+		part.blocks = append(part.blocks, b)
+		if _, ok := ts.series[key]; !ok {
+			name, tags := parseSeriesKey(key)
+			ts.series[key] = tsSeries{name: name, tags: tags}
+		}
+		offset = b.offset + int64(b.length)
+	}
+
+	if offset < size {
+		log.Printf("Truncating partial block in partition %d at offset %d", part.start, offset)
+		return part.file.Truncate(offset)
+	}
+	return nil
+}
+
+func (ts *TimeSeriesEngineImpl) replayWAL() error {
+	if ts.wal == nil {
+		return nil
+	}
+	records, err := ts.wal.Replay()
+	if err != nil {
+		return err
+	}
+	for _, entry := range records {
+		if len(entry[1]) != 16 {
+			continue
+		}
+		val := []byte(entry[1])
+		ts.appendLocked(entry[0], TimePoint{
+			Timestamp: int64(binary.LittleEndian.Uint64(val[0:8])),
+			Value:     math.Float64frombits(binary.LittleEndian.Uint64(val[8:16])),
+		})
+	}
+	return ts.flushLocked()
+}
+
+func (ts *TimeSeriesEngineImpl) autoFlush() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ts.lock.Lock()
+			if ts.headCount > 0 && time.Since(ts.headSince) >= ts.maxHeadAge {
+				if err := ts.flushLocked(); err != nil {
+					log.Printf("Time-series flush failed: %v", err)
+				}
+			}
+			ts.lock.Unlock()
+		case <-ts.quitChan:
+			return
+		}
+	}
+}
+
+func (ts *TimeSeriesEngineImpl) autoRetention() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ts.dropExpired()
+			ts.compactPartitions()
+		case <-ts.quitChan:
+			return
+		}
+	}
+}
+
+// dropExpired deletes whole partitions that end before the retention cutoff.
+func (ts *TimeSeriesEngineImpl) dropExpired() {
+	if ts.retention <= 0 {
+		return
+	}
+	cutoff := time.Now().UnixMilli() - ts.retention
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	for start, part := range ts.partitions {
+		if start+ts.partitionSize > cutoff {
+			continue
+		}
+		part.file.Close()
+		if err := os.Remove(part.file.Name()); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to drop partition %d: %v", start, err)
+			continue
+		}
+		delete(ts.partitions, start)
+		log.Printf("Dropped expired time-series partition %d", start)
+	}
+}
+
+// seriesKey builds the canonical identity "name{k1=v1,k2=v2}" with tags sorted by key.
+func seriesKey(name string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + tags[k]
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func parseSeriesKey(key string) (string, map[string]string) {
+	tags := make(map[string]string)
+	i := strings.IndexByte(key, '{')
+	if i < 0 || !strings.HasSuffix(key, "}") {
+		return key, tags
+	}
+	for _, pair := range strings.Split(key[i+1:len(key)-1], ",") {
+		if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
+			tags[kv[0]] = kv[1]
+		}
+	}
+	return key[:i], tags
+}
+
+func validateSeries(name string, tags map[string]string) error {
+	const reserved = "{}=,"
+	if name == "" || strings.ContainsAny(name, reserved) {
+		return fmt.Errorf("invalid series name %q", name)
+	}
+	if len(name) > 1024 {
+		return errors.New("series name too long")
+	}
+	for k, v := range tags {
+		if k == "" || strings.ContainsAny(k, reserved) || strings.ContainsAny(v, reserved) {
+			return fmt.Errorf("invalid tag %q=%q", k, v)
+		}
+	}
+	return nil
+}
+
+func tagsMatch(have, want map[string]string) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func isAllowedAggregation(agg string) bool {
+	for _, a := range allowedAggregations {
+		if a == agg {
+			return true
+		}
+	}
+	return false
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// ParseRetention parses a Go duration that may also use a "d" (day) suffix,
+// e.g. "30d" or "12h". An empty string yields zero.
+func ParseRetention(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGorillaRoundTrip(t *testing.T) {
+	points := []TimePoint{
+		{Timestamp: 1700000000000, Value: 12.5},
+		{Timestamp: 1700000001000, Value: 12.5},
+		{Timestamp: 1700000002000, Value: 13.25},
+		{Timestamp: 1700000003000, Value: -4},
+		{Timestamp: 1700000003001, Value: 0},
+		{Timestamp: 1700000010000, Value: math.MaxFloat64},
+		{Timestamp: 1700100000000, Value: math.SmallestNonzeroFloat64},
+		{Timestamp: 1700100000060, Value: 1e-300},
+		{Timestamp: 1600000000000, Value: 42},
+	}
+	data := encodeGorilla(points)
+	decoded, err := decodeGorilla(data, len(points))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	for i := range points {
+		if decoded[i] != points[i] {
+			t.Fatalf("point %d: expected %+v, got %+v", i, points[i], decoded[i])
+		}
+	}
+
+	if _, err := decodeGorilla(data[:len(data)/2], len(points)); err == nil {
+		t.Fatal("expected an error for a truncated block")
+	}
+}
+
+func TestTimeSeriesEngine(t *testing.T) {
+	dir := "testdata/ts_partitions"
+	walPath := "testdata/ts_wal.db"
+	os.RemoveAll(dir)
+	os.Remove(walPath)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		os.Remove(walPath)
+	})
+
+	ts, err := NewTimeSeriesEngine(dir, walPath, time.Hour, 0, true)
+	if err != nil {
+		t.Fatalf("Failed to open time-series engine: %v", err)
+	}
+
+	// Two hours of points at one-minute intervals, on two hosts
+	base := int64(1700000000000) / 3600000 * 3600000
+	for i := int64(0); i < 120; i++ {
+		if err := ts.AppendPoint("cpu", base+i*60000, float64(i), map[string]string{"host": "a"}); err != nil {
+			t.Fatalf("AppendPoint failed: %v", err)
+		}
+		if err := ts.AppendPoint("cpu", base+i*60000, 100, map[string]string{"host": "b"}); err != nil {
+			t.Fatalf("AppendPoint failed: %v", err)
+		}
+	}
+	if err := ts.AppendPoint("bad{name", 0, 1, nil); err == nil {
+		t.Fatal("expected an error for an invalid series name")
+	}
+
+	raw, err := ts.QueryRange("cpu", map[string]string{"host": "a"}, base, base+10*60000, 0, "")
+	if err != nil {
+		t.Fatalf("QueryRange failed: %v", err)
+	}
+	if len(raw) != 10 || raw[9].Value != 9 {
+		t.Fatalf("unexpected raw points: %+v", raw)
+	}
+
+	ts.lock.Lock()
+	if err := ts.flushLocked(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	ts.lock.Unlock()
+	if len(ts.partitions) != 2 {
+		t.Fatalf("expected 2 partitions, got %d", len(ts.partitions))
+	}
+
+	check := func(eng *TimeSeriesEngineImpl) {
+		t.Helper()
+		buckets, err := eng.QueryRange("cpu", map[string]string{"host": "a"}, base, 0, 3600000, "avg")
+		if err != nil {
+			t.Fatalf("QueryRange failed: %v", err)
+		}
+		if len(buckets) != 2 || buckets[0].Timestamp != base || buckets[0].Value != 29.5 || buckets[1].Value != 89.5 {
+			t.Fatalf("unexpected avg buckets: %+v", buckets)
+		}
+
+		counts, err := eng.QueryRange("cpu", nil, base, base+3600000, 1800000, "count")
+		if err != nil {
+			t.Fatalf("QueryRange failed: %v", err)
+		}
+		if len(counts) != 2 || counts[0].Value != 60 || counts[1].Value != 60 {
+			t.Fatalf("unexpected count buckets: %+v", counts)
+		}
+
+		for agg, want := range map[string]float64{"min": 0, "max": 119, "sum": 7140} {
+			res, err := eng.QueryRange("cpu", map[string]string{"host": "a"}, base, 0, 7200000, agg)
+			if err != nil || len(res) != 1 || res[0].Value != want {
+				t.Fatalf("%s: expected %v, got %+v (err %v)", agg, want, res, err)
+			}
+		}
+	}
+	check(ts)
+
+	if _, err := ts.QueryRange("cpu", nil, base, 0, 60000, "median"); err == nil {
+		t.Fatal("expected an error for an unknown aggregation")
+	}
+
+	// Unflushed points must survive a restart through the WAL
+	if err := ts.AppendPoint("mem", base, 7, nil); err != nil {
+		t.Fatalf("AppendPoint failed: %v", err)
+	}
+	ts.wal.Close()
+	ts.closeOnce.Do(func() {
+		close(ts.quitChan)
+		for _, p := range ts.partitions {
+			p.file.Close()
+		}
+	})
+
+	reopened, err := NewTimeSeriesEngine(dir, walPath, time.Hour, 0, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen time-series engine: %v", err)
+	}
+	defer reopened.Close()
+	check(reopened)
+	mem, err := reopened.QueryRange("mem", nil, base, 0, 0, "")
+	if err != nil || len(mem) != 1 || mem[0].Value != 7 {
+		t.Fatalf("expected WAL point after restart, got %+v (err %v)", mem, err)
+	}
+
+	// A short retention drops both partitions, which are long in the past
+	reopened.retention = time.Hour.Milliseconds()
+	reopened.dropExpired()
+	if len(reopened.partitions) != 0 {
+		t.Fatalf("expected expired partitions to be dropped, %d left", len(reopened.partitions))
+	}
+	res, err := reopened.QueryRange("cpu", nil, base, 0, 0, "")
+	if err != nil || len(res) != 0 {
+		t.Fatalf("expected no points after retention, got %d (err %v)", len(res), err)
+	}
+}
+
+func TestTimeSeriesCompaction(t *testing.T) {
+	dir := "testdata/ts_compact"
+	walPath := "testdata/ts_compact_wal.db"
+	os.RemoveAll(dir)
+	os.Remove(walPath)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		os.Remove(walPath)
+	})
+
+	ts, err := NewTimeSeriesEngine(dir, walPath, time.Hour, 0, true)
+	if err != nil {
+		t.Fatalf("Failed to open time-series engine: %v", err)
+	}
+
+	// Points within the age threshold stay buffered
+	base := int64(1700000000000) / 3600000 * 3600000
+	if err := ts.AppendPoint("cpu", base, 0, nil); err != nil {
+		t.Fatalf("AppendPoint failed: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	ts.lock.RLock()
+	buffered := ts.headCount
+	ts.lock.RUnlock()
+	if buffered != 1 {
+		t.Fatalf("expected the point to stay buffered, head has %d", buffered)
+	}
+
+	// One block per flush, then one block per series after compaction
+	for i := int64(1); i < 10; i++ {
+		ts.lock.Lock()
+		ts.appendLocked("cpu", TimePoint{Timestamp: base + i*1000, Value: float64(i)})
+		ts.appendLocked("mem", TimePoint{Timestamp: base + i*1000, Value: float64(-i)})
+		if err := ts.flushLocked(); err != nil {
+			t.Fatalf("flush failed: %v", err)
+		}
+		ts.lock.Unlock()
+	}
+	part := ts.partitions[base]
+	if len(part.blocks) != 18 {
+		t.Fatalf("expected 18 blocks before compaction, got %d", len(part.blocks))
+	}
+	ts.compactPartitions()
+	if len(part.blocks) != 2 {
+		t.Fatalf("expected 2 blocks after compaction, got %d", len(part.blocks))
+	}
+
+	check := func(eng *TimeSeriesEngineImpl) {
+		t.Helper()
+		pts, err := eng.QueryRange("cpu", nil, base, 0, 0, "")
+		if err != nil {
+			t.Fatalf("QueryRange failed: %v", err)
+		}
+		if len(pts) != 10 {
+			t.Fatalf("expected 10 points, got %+v", pts)
+		}
+		for i, p := range pts {
+			if p.Timestamp != base+int64(i)*1000 || p.Value != float64(i) {
+				t.Fatalf("unexpected point %d: %+v", i, p)
+			}
+		}
+	}
+	check(ts)
+	ts.Close()
+
+	reopened, err := NewTimeSeriesEngine(dir, walPath, time.Hour, 0, true)
+	if err != nil {
+		t.Fatalf("Failed to reopen time-series engine: %v", err)
+	}
+	defer reopened.Close()
+	if n := len(reopened.partitions[base].blocks); n != 2 {
+		t.Fatalf("expected 2 blocks after reopening, got %d", n)
+	}
+	check(reopened)
+}
+
+func TestParseRetention(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    0,
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range cases {
+		got, err := ParseRetention(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseRetention(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseRetention("-1d"); err == nil {
+		t.Fatal("expected an error for a negative duration")
+	}
+}
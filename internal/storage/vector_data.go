@@ -0,0 +1,409 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// The vector data file starts with a header
+//
+//	"SHVD" | version uint16 | encoding byte | reserved byte
+//
+// followed by records:
+//
+//	'V' | id int64 | encoded vector   vector (the last record of an ID wins)
+//	'X' | id int64                    tombstone: the ID was removed
+//
+// Files written before the header existed hold bare id|vector records; they
+// are rewritten in this format when opened.
+var vectorDataMagic = []byte("SHVD")
+
+const (
+	vectorDataVersion    = 2
+	vectorDataHeaderSize = 8
+
+	recordVector    = 'V'
+	recordTombstone = 'X'
+)
+
+// Compaction runs once dead records (overwritten vectors and tombstones) are
+// both numerous and the larger part of the data file.
+const (
+	compactMinDead   = 1024
+	compactDeadRatio = 0.5
+)
+
+func (ve *VectorEngineImpl) vectorRecordSize() int64 {
+	return int64(1 + 8 + encodedVectorSize(ve.encoding, ve.maxVectorSize))
+}
+
+// appendToDataFile appends a vector record, or a tombstone when vector is
+// nil; the caller must hold ve.lock.
+func (ve *VectorEngineImpl) appendToDataFile(id int64, vector []float32) error {
+	// Seek end, remember offset for GetVectorByID
+	pos, err := ve.dataFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := ve.dataFile.Write(ve.encodeVectorRecord(id, vector)); err != nil {
+		return err
+	}
+	ve.dataRecords++
+	if vector == nil {
+		delete(ve.fileOffsets, id)
+	} else {
+		ve.fileOffsets[id] = pos
+	}
+	return nil
+}
+
+func (ve *VectorEngineImpl) encodeVectorRecord(id int64, vector []float32) []byte {
+	if vector == nil {
+		buf := make([]byte, 9)
+		buf[0] = recordTombstone
+		binary.LittleEndian.PutUint64(buf[1:9], uint64(id))
+		return buf
+	}
+	buf := make([]byte, ve.vectorRecordSize())
+	buf[0] = recordVector
+	binary.LittleEndian.PutUint64(buf[1:9], uint64(id))
+	encodeVector(ve.encoding, buf[9:], vector)
+	return buf
+}
+
+// readVectorAt reads the vector record at offset; the caller must hold ve.lock.
+func (ve *VectorEngineImpl) readVectorAt(offset int64) ([]float32, error) {
+	return ve.readVectorFrom(ve.dataFile, offset)
+}
+
+func (ve *VectorEngineImpl) readVectorFrom(f *os.File, offset int64) ([]float32, error) {
+	buf := make([]byte, ve.vectorRecordSize())
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return nil, fmt.Errorf("read vector at offset %d: %w", offset, err)
+	}
+	if buf[0] != recordVector {
+		return nil, fmt.Errorf("no vector record at offset %d", offset)
+	}
+	return decodeVector(ve.encoding, buf[9:], ve.maxVectorSize), nil
+}
+
+// rebuildOffsetsFromDataFile walks the data file and records the offset of
+// the latest vector of each live ID. A partially written tail is truncated
+// so that new records stay aligned.
+func (ve *VectorEngineImpl) rebuildOffsetsFromDataFile() error {
+	info, err := ve.dataFile.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if size == 0 {
+		return ve.writeDataHeader(ve.dataFile)
+	}
+
+	header := make([]byte, vectorDataHeaderSize)
+	if _, err := ve.dataFile.ReadAt(header, 0); err != nil || !bytes.Equal(header[:4], vectorDataMagic) {
+		return ve.migrateLegacyDataFile(size)
+	}
+	if v := binary.LittleEndian.Uint16(header[4:6]); v != vectorDataVersion {
+		return fmt.Errorf("unsupported vector data file version %d", v)
+	}
+	// The file keeps the encoding it was created with
+	if enc := header[6]; enc != ve.encoding {
+		if enc > encodingInt8 {
+			return fmt.Errorf("unsupported vector data encoding %d", enc)
+		}
+		ve.encoding = enc
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(ve.dataFile, vectorDataHeaderSize, size-vectorDataHeaderSize))
+	vecSize := ve.vectorRecordSize()
+	offset := int64(vectorDataHeaderSize)
+	rec := make([]byte, vecSize)
+scan:
+	for {
+		if _, err := io.ReadFull(br, rec[:9]); err != nil {
+			break
+		}
+		id := int64(binary.LittleEndian.Uint64(rec[1:9]))
+		switch rec[0] {
+		case recordTombstone:
+			delete(ve.fileOffsets, id)
+			offset += 9
+		case recordVector:
+			if _, err := io.ReadFull(br, rec[9:]); err != nil {
+				break scan
+			}
+			ve.fileOffsets[id] = offset
+			offset += vecSize
+		default:
+			log.Printf("Unknown vector data record type %q at offset %d", rec[0], offset)
+			break scan
+		}
+		ve.dataRecords++
+	}
+	if offset < size {
+		log.Printf("Truncating partial vector data record at offset %d", offset)
+		return ve.dataFile.Truncate(offset)
+	}
+	return nil
+}
+
+func (ve *VectorEngineImpl) writeDataHeader(f *os.File) error {
+	header := make([]byte, vectorDataHeaderSize)
+	copy(header, vectorDataMagic)
+	binary.LittleEndian.PutUint16(header[4:6], vectorDataVersion)
+	header[6] = ve.encoding
+	if _, err := f.WriteAt(header, 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// migrateLegacyDataFile reads a data file of bare id|vector records and
+// rewrites its live vectors in the current format.
+func (ve *VectorEngineImpl) migrateLegacyDataFile(size int64) error {
+	legacySize := int64(8 + 4*ve.maxVectorSize)
+	latest := make(map[int64][]float32)
+	var order []int64
+	buf := make([]byte, legacySize)
+	for offset := int64(0); offset+legacySize <= size; offset += legacySize {
+		if _, err := ve.dataFile.ReadAt(buf, offset); err != nil {
+			return fmt.Errorf("read legacy data file: %w", err)
+		}
+		id := int64(binary.LittleEndian.Uint64(buf[0:8]))
+		vec, err := bytesToFloat32Array(buf[8:])
+		if err != nil {
+			return err
+		}
+		if _, seen := latest[id]; !seen {
+			order = append(order, id)
+		}
+		latest[id] = vec
+	}
+	log.Printf("Upgrading vector data file %s (%d vectors)", ve.dataPath, len(order))
+	return ve.rewriteDataFile(func(write func(id int64, vec []float32) error) error {
+		for _, id := range order {
+			if err := write(id, latest[id]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Compact rewrites the data file with only the latest vector of each live
+// ID, dropping overwritten vectors and tombstones. The live vectors are
+// copied without holding ve.lock; it is only taken to snapshot the offsets,
+// and then to carry over the changes made meanwhile and swap the files.
+func (ve *VectorEngineImpl) Compact() error {
+	ve.compactMu.Lock()
+	defer ve.compactMu.Unlock()
+
+	ve.flushData(true)
+
+	dw, snapshot, err := ve.copyLiveVectors()
+	if err != nil {
+		return fmt.Errorf("compact vector data: %w", err)
+	}
+
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+	before := ve.dataRecords
+	if err := ve.finishCompaction(dw, snapshot); err != nil {
+		return fmt.Errorf("compact vector data: %w", err)
+	}
+	log.Printf("Compacted vector data file %s: %d -> %d records", ve.dataPath, before, ve.dataRecords)
+	return nil
+}
+
+// copyLiveVectors writes the live vectors into a new data file and returns
+// it with the offsets it copied. Records are never changed in place and the
+// data file is only replaced under compactMu, so the snapshot stays readable
+// after ve.lock is released.
+func (ve *VectorEngineImpl) copyLiveVectors() (*dataFileWriter, map[int64]int64, error) {
+	ve.lock.RLock()
+	file := ve.dataFile
+	snapshot := make(map[int64]int64, len(ve.fileOffsets))
+	ids := make([]int64, 0, len(ve.fileOffsets))
+	for id, offset := range ve.fileOffsets {
+		snapshot[id] = offset
+		ids = append(ids, id)
+	}
+	ve.lock.RUnlock()
+	// Keep the file order so the rewrite reads sequentially
+	sort.Slice(ids, func(i, j int) bool { return snapshot[ids[i]] < snapshot[ids[j]] })
+
+	dw, err := ve.newDataFileWriter()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, id := range ids {
+		vec, err := ve.readVectorFrom(file, snapshot[id])
+		if err == nil {
+			err = dw.write(id, vec)
+		}
+		if err != nil {
+			dw.abort()
+			return nil, nil, err
+		}
+	}
+	return dw, snapshot, nil
+}
+
+// finishCompaction appends the vectors written and the IDs removed since
+// the snapshot was copied, then swaps in the new file; the caller must hold
+// ve.lock.
+func (ve *VectorEngineImpl) finishCompaction(dw *dataFileWriter, snapshot map[int64]int64) error {
+	for id, offset := range ve.fileOffsets {
+		if old, ok := snapshot[id]; ok && old == offset {
+			continue
+		}
+		vec, err := ve.readVectorAt(offset)
+		if err == nil {
+			err = dw.write(id, vec)
+		}
+		if err != nil {
+			dw.abort()
+			return err
+		}
+	}
+	for id := range snapshot {
+		if _, live := ve.fileOffsets[id]; live {
+			continue
+		}
+		if err := dw.write(id, nil); err != nil {
+			dw.abort()
+			return err
+		}
+	}
+	return ve.installDataFile(dw)
+}
+
+// rewriteDataFile writes a new data file with the records produced by fill,
+// swaps it in atomically and reopens it; the caller must hold ve.lock (or
+// own the engine, during open).
+func (ve *VectorEngineImpl) rewriteDataFile(fill func(write func(id int64, vec []float32) error) error) error {
+	dw, err := ve.newDataFileWriter()
+	if err != nil {
+		return err
+	}
+	if err := fill(dw.write); err != nil {
+		dw.abort()
+		return err
+	}
+	return ve.installDataFile(dw)
+}
+
+// dataFileWriter writes a replacement data file next to the current one.
+type dataFileWriter struct {
+	ve      *VectorEngineImpl
+	file    *os.File
+	w       *bufio.Writer
+	offset  int64
+	offsets map[int64]int64
+	records int64
+}
+
+func (ve *VectorEngineImpl) newDataFileWriter() (*dataFileWriter, error) {
+	tmp, err := os.OpenFile(ve.dataPath+".compact", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	dw := &dataFileWriter{ve: ve, file: tmp, offset: vectorDataHeaderSize, offsets: make(map[int64]int64)}
+	if err := ve.writeDataHeader(tmp); err != nil {
+		dw.abort()
+		return nil, err
+	}
+	if _, err := tmp.Seek(dw.offset, io.SeekStart); err != nil {
+		dw.abort()
+		return nil, err
+	}
+	dw.w = bufio.NewWriter(tmp)
+	return dw, nil
+}
+
+// write appends a vector record, or a tombstone when vec is nil.
+func (dw *dataFileWriter) write(id int64, vec []float32) error {
+	rec := dw.ve.encodeVectorRecord(id, vec)
+	if _, err := dw.w.Write(rec); err != nil {
+		return err
+	}
+	if vec == nil {
+		delete(dw.offsets, id)
+	} else {
+		dw.offsets[id] = dw.offset
+	}
+	dw.offset += int64(len(rec))
+	dw.records++
+	return nil
+}
+
+func (dw *dataFileWriter) abort() {
+	dw.file.Close()
+	os.Remove(dw.file.Name())
+}
+
+// installDataFile syncs the new file and renames it over the data file; the
+// caller must hold ve.lock.
+func (ve *VectorEngineImpl) installDataFile(dw *dataFileWriter) error {
+	if err := dw.w.Flush(); err != nil {
+		dw.abort()
+		return err
+	}
+	if err := dw.file.Sync(); err != nil {
+		dw.abort()
+		return err
+	}
+	if err := os.Rename(dw.file.Name(), ve.dataPath); err != nil {
+		dw.abort()
+		return err
+	}
+	syncDir(filepath.Dir(ve.dataPath))
+
+	ve.dataFile.Close()
+	ve.dataFile = dw.file
+	ve.fileOffsets = dw.offsets
+	ve.dataRecords = dw.records
+	return nil
+}
+
+// needsCompaction reports whether enough of the data file is dead records.
+func (ve *VectorEngineImpl) needsCompaction() bool {
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	dead := ve.dataRecords - int64(len(ve.fileOffsets))
+	return dead >= compactMinDead && float64(dead) >= compactDeadRatio*float64(ve.dataRecords)
+}
+
+func (ve *VectorEngineImpl) autoCompact() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !ve.needsCompaction() {
+				continue
+			}
+			if err := ve.Compact(); err != nil {
+				log.Printf("Vector data compaction failed: %v", err)
+			}
+		case <-ve.quitChan:
+			return
+		}
+	}
+}
+
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
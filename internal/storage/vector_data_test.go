@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestVectorDataFile(t *testing.T) {
+	dir := t.TempDir()
+	ve := openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	for _, rec := range []struct {
+		id  int64
+		vec []float32
+	}{{1, []float32{1, 1}}, {2, []float32{2, 2}}, {1, []float32{3, 3}}, {2, nil}, {3, []float32{4, 4}}} {
+		if err := ve.appendToDataFile(rec.id, rec.vec); err != nil {
+			t.Fatalf("appendToDataFile failed: %v", err)
+		}
+	}
+	// A torn write at the tail is dropped on reopen
+	ve.dataFile.Write([]byte{recordVector, 9, 9})
+	ve.Close()
+
+	// The tombstone keeps ID 2 removed across restarts
+	ve = openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	if len(ve.fileOffsets) != 2 || ve.dataRecords != 5 {
+		t.Fatalf("expected 2 live IDs in 5 records, got %v in %d", ve.fileOffsets, ve.dataRecords)
+	}
+	if _, ok := ve.fileOffsets[2]; ok {
+		t.Fatal("removed ID came back after reopening")
+	}
+	if vec, _ := ve.readVectorAt(ve.fileOffsets[1]); !reflect.DeepEqual(vec, []float32{3, 3}) {
+		t.Fatalf("expected the latest vector of ID 1, got %v", vec)
+	}
+	if err := ve.appendToDataFile(4, []float32{5, 5}); err != nil {
+		t.Fatalf("appendToDataFile failed: %v", err)
+	}
+
+	if err := ve.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	info, _ := os.Stat(ve.dataPath)
+	if want := int64(vectorDataHeaderSize) + 3*ve.vectorRecordSize(); info.Size() != want || ve.dataRecords != 3 {
+		t.Fatalf("expected %d bytes and 3 records after compaction, got %d and %d", want, info.Size(), ve.dataRecords)
+	}
+	for id, want := range map[int64][]float32{1: {3, 3}, 3: {4, 4}, 4: {5, 5}} {
+		if vec, err := ve.readVectorAt(ve.fileOffsets[id]); err != nil || !reflect.DeepEqual(vec, want) {
+			t.Fatalf("ID %d: expected %v after compaction, got %v (%v)", id, want, vec, err)
+		}
+	}
+	ve.Close()
+	if ve = openTestVectorEngine(t, dir, 2, faiss.MetricL2, ""); len(ve.fileOffsets) != 3 {
+		t.Fatalf("expected 3 live IDs after reopening the compacted file, got %v", ve.fileOffsets)
+	}
+}
+
+func TestVectorDataFileLegacyUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vector_data.db")
+
+	// Headerless id|vector records, as written by earlier versions
+	var legacy []byte
+	for _, rec := range []struct {
+		id  int64
+		vec []float32
+	}{{7, []float32{1, 2}}, {8, []float32{3, 4}}, {7, []float32{5, 6}}} {
+		buf := make([]byte, 16)
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(rec.id))
+		binary.LittleEndian.PutUint32(buf[8:12], math.Float32bits(rec.vec[0]))
+		binary.LittleEndian.PutUint32(buf[12:16], math.Float32bits(rec.vec[1]))
+		legacy = append(legacy, buf...)
+	}
+	if err := os.WriteFile(path, legacy, 0666); err != nil {
+		t.Fatalf("write legacy file: %v", err)
+	}
+
+	ve := openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	if len(ve.fileOffsets) != 2 {
+		t.Fatalf("expected 2 IDs, got %v", ve.fileOffsets)
+	}
+	if vec, _ := ve.readVectorAt(ve.fileOffsets[7]); !reflect.DeepEqual(vec, []float32{5, 6}) {
+		t.Fatalf("expected the latest vector of ID 7, got %v", vec)
+	}
+	ve.Close()
+	if ve = openTestVectorEngine(t, dir, 2, faiss.MetricL2, ""); len(ve.fileOffsets) != 2 || ve.dataRecords != 2 {
+		t.Fatalf("expected the upgraded file to reopen with 2 records, got %v", ve.fileOffsets)
+	}
+}
+
+func TestVectorDataFileCompactionCarriesOver(t *testing.T) {
+	dir := t.TempDir()
+	ve := openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	for id := int64(1); id <= 3; id++ {
+		if err := ve.appendToDataFile(id, []float32{float32(id), float32(id)}); err != nil {
+			t.Fatalf("appendToDataFile failed: %v", err)
+		}
+	}
+
+	dw, snapshot, err := ve.copyLiveVectors()
+	if err != nil {
+		t.Fatalf("copyLiveVectors failed: %v", err)
+	}
+	// Changes made while the live vectors are copied
+	for _, rec := range []struct {
+		id  int64
+		vec []float32
+	}{{1, []float32{9, 9}}, {3, nil}, {4, []float32{4, 4}}} {
+		if err := ve.appendToDataFile(rec.id, rec.vec); err != nil {
+			t.Fatalf("appendToDataFile failed: %v", err)
+		}
+	}
+	if err := ve.finishCompaction(dw, snapshot); err != nil {
+		t.Fatalf("finishCompaction failed: %v", err)
+	}
+
+	want := map[int64][]float32{1: {9, 9}, 2: {2, 2}, 4: {4, 4}}
+	check := func(ve *VectorEngineImpl) {
+		t.Helper()
+		if len(ve.fileOffsets) != len(want) {
+			t.Fatalf("expected IDs %v, got %v", want, ve.fileOffsets)
+		}
+		for id, vec := range want {
+			if got, err := ve.readVectorAt(ve.fileOffsets[id]); err != nil || !reflect.DeepEqual(got, vec) {
+				t.Fatalf("ID %d: expected %v, got %v (%v)", id, vec, got, err)
+			}
+		}
+	}
+	check(ve)
+	ve.Close()
+	check(openTestVectorEngine(t, dir, 2, faiss.MetricL2, ""))
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/shibudb.org/shibudb-server/internal/float16"
+)
+
+// Encodings of vectors in the data file. float16 halves the file and int8
+// quarters it (plus 8 bytes per vector); both are lossy, and GetVectorByID
+// returns the decoded stored values. The FAISS index is built from the
+// original float32 vectors on insert, but RebuildIndex and MigrateIndex can
+// only rebuild it from the decoded ones, and reranking, which needs exact
+// distances, is refused.
+const (
+	encodingFloat32 byte = iota
+	encodingFloat16
+	encodingInt8
+)
+
+var vectorEncodings = map[string]byte{
+	"":        encodingFloat32,
+	"float32": encodingFloat32,
+	"float16": encodingFloat16,
+	"int8":    encodingInt8,
+}
+
+// ValidateVectorEncoding checks a data file encoding name: float32 (the
+// default), float16 or int8.
+func ValidateVectorEncoding(name string) error {
+	if _, ok := vectorEncodings[name]; !ok {
+		return fmt.Errorf("vector encoding '%s' is not allowed (use float32, float16 or int8)", name)
+	}
+	return nil
+}
+
+// encodedVectorSize is the size of an encoded vector of dim values.
+func encodedVectorSize(encoding byte, dim int) int {
+	switch encoding {
+	case encodingFloat16:
+		return 2 * dim
+	case encodingInt8:
+		return 8 + dim // min and step, then one code per value
+	}
+	return 4 * dim
+}
+
+// encodeVector writes v into buf, which has encodedVectorSize bytes.
+func encodeVector(encoding byte, buf []byte, v []float32) {
+	switch encoding {
+	case encodingFloat16:
+		for i, x := range v {
+			binary.LittleEndian.PutUint16(buf[2*i:], float16.FromFloat32(x))
+		}
+	case encodingInt8:
+		// Each vector is quantized over its own range: x ≈ min + code*step
+		lo, hi := float32(math.Inf(1)), float32(math.Inf(-1))
+		for _, x := range v {
+			lo = float32(math.Min(float64(lo), float64(x)))
+			hi = float32(math.Max(float64(hi), float64(x)))
+		}
+		step := (hi - lo) / 255
+		binary.LittleEndian.PutUint32(buf[0:], math.Float32bits(lo))
+		binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(step))
+		for i, x := range v {
+			code := 0.0
+			if step > 0 {
+				code = math.Round(float64((x - lo) / step))
+			}
+			buf[8+i] = byte(math.Max(0, math.Min(255, code)))
+		}
+	default:
+		for i, x := range v {
+			binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+		}
+	}
+}
+
+// decodeVector reads a vector of dim values encoded by encodeVector.
+func decodeVector(encoding byte, buf []byte, dim int) []float32 {
+	v := make([]float32, dim)
+	switch encoding {
+	case encodingFloat16:
+		for i := range v {
+			v[i] = float16.ToFloat32(binary.LittleEndian.Uint16(buf[2*i:]))
+		}
+	case encodingInt8:
+		lo := math.Float32frombits(binary.LittleEndian.Uint32(buf[0:]))
+		step := math.Float32frombits(binary.LittleEndian.Uint32(buf[4:]))
+		for i := range v {
+			v[i] = lo + float32(buf[8+i])*step
+		}
+	default:
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+		}
+	}
+	return v
+}
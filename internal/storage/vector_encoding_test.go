@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestVectorEncodings(t *testing.T) {
+	vec := []float32{-1.5, 0, 0.333, 2.25, 100}
+	for name, enc := range vectorEncodings {
+		buf := make([]byte, encodedVectorSize(enc, len(vec)))
+		encodeVector(enc, buf, vec)
+		got := decodeVector(enc, buf, len(vec))
+		for i := range vec {
+			tol := 0.0
+			switch enc {
+			case encodingFloat16:
+				tol = math.Abs(float64(vec[i])) * 1e-3
+			case encodingInt8:
+				tol = (100 + 1.5) / 255 / 2 * 1.001
+			}
+			if d := math.Abs(float64(got[i] - vec[i])); d > tol {
+				t.Errorf("%s: value %d decoded as %v, want %v (±%v)", name, i, got[i], vec[i], tol)
+			}
+		}
+	}
+	if err := ValidateVectorEncoding("bf16"); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+}
+
+func TestVectorDataFileEncoding(t *testing.T) {
+	dir := t.TempDir()
+	ve := openTestVectorEngine(t, dir, 2, faiss.MetricL2, "float16")
+	if err := ve.appendToDataFile(1, []float32{0.5, -3}); err != nil {
+		t.Fatalf("appendToDataFile failed: %v", err)
+	}
+	ve.Close()
+
+	// The file keeps its encoding whatever a later open asks for
+	ve = openTestVectorEngine(t, dir, 2, faiss.MetricL2, "float32")
+	if ve.encoding != encodingFloat16 {
+		t.Fatalf("expected float16 encoding from the header, got %d", ve.encoding)
+	}
+	info, _ := ve.dataFile.Stat()
+	if want := int64(vectorDataHeaderSize + 9 + 4); info.Size() != want {
+		t.Errorf("expected a %d byte file, got %d", want, info.Size())
+	}
+	vec, err := ve.readVectorAt(ve.fileOffsets[1])
+	if err != nil || vec[0] != 0.5 || vec[1] != -3 {
+		t.Errorf("expected [0.5 -3], got %v, %v", vec, err)
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Vectors can belong to a parent document (e.g. the chunks of a long text),
+// recorded in the attribute log and the WAL like payloads.
+const (
+	attrParent      = 'D'
+	walParentPrefix = 'D'
+)
+
+// groupOversample is how many vectors per requested document a grouped
+// search fetches at first; maxGroupFetch bounds the fetch as it doubles.
+const (
+	groupOversample = 4
+	maxGroupFetch   = 100000
+)
+
+// GroupResult is one document of a grouped search with its best-matching
+// vector. Parent is empty for a vector without a parent document, which
+// forms a group of its own.
+type GroupResult struct {
+	Parent string
+	ID     int64
+	Score  float32
+}
+
+// SetParent sets the parent document of a vector; an empty parent clears it.
+func (ve *VectorEngineImpl) SetParent(id int64, parent string) error {
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+
+	if ve.wal != nil {
+		if err := ve.wal.WriteEntry(walAttrKey(walParentPrefix, id), parent); err != nil {
+			return err
+		}
+	}
+	return ve.setParentAfterWAL(id, parent)
+}
+
+func (ve *VectorEngineImpl) setParentAfterWAL(id int64, parent string) error {
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+	return ve.setParentLocked(id, parent)
+}
+
+// setParentLocked records a parent; the caller must hold ve.lock.
+func (ve *VectorEngineImpl) setParentLocked(id int64, parent string) error {
+	if ve.parents[id] == parent {
+		return nil
+	}
+	if err := ve.appendAttr(attrParent, id, parent); err != nil {
+		return fmt.Errorf("write parent: %w", err)
+	}
+	if parent == "" {
+		delete(ve.parents, id)
+	} else {
+		ve.parents[id] = parent
+	}
+	return nil
+}
+
+// SearchGrouped returns the k documents nearest to the query, each with its
+// best-matching vector, best first. Vectors are fetched with an oversampled
+// SearchTopKWithOptions, doubling the fetch until k documents are found or
+// the space is exhausted.
+func (ve *VectorEngineImpl) SearchGrouped(query []float32, k int, opts SearchOptions) ([]GroupResult, error) {
+	fetch := k * groupOversample
+	for {
+		ids, scores, err := ve.SearchTopKWithOptions(query, fetch, opts)
+		if err != nil {
+			return nil, err
+		}
+		groups := ve.groupResults(ids, scores, k)
+		if len(groups) >= k || len(ids) < fetch || fetch >= maxGroupFetch {
+			return groups, nil
+		}
+		fetch *= 2
+	}
+}
+
+// groupResults keeps the first (best) vector of each document among ordered
+// search results, up to k documents.
+func (ve *VectorEngineImpl) groupResults(ids []int64, scores []float32, k int) []GroupResult {
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	seen := make(map[string]struct{})
+	var groups []GroupResult
+	for i, id := range ids {
+		parent := ve.parents[id]
+		group := parent
+		if parent == "" {
+			group = "\x00" + strconv.FormatInt(id, 10)
+		}
+		if _, ok := seen[group]; ok {
+			continue
+		}
+		seen[group] = struct{}{}
+		groups = append(groups, GroupResult{Parent: parent, ID: id, Score: scores[i]})
+		if len(groups) == k {
+			break
+		}
+	}
+	return groups
+}
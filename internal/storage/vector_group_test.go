@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestGroupResults(t *testing.T) {
+	ve := openTestVectorEngine(t, t.TempDir(), 2, faiss.MetricL2, "")
+	for id, parent := range map[int64]string{1: "doc-a", 2: "doc-a", 3: "doc-b", 5: "doc-b"} {
+		if err := ve.SetParent(id, parent); err != nil {
+			t.Fatalf("SetParent failed: %v", err)
+		}
+	}
+	ids := []int64{2, 1, 4, 3, 5, 6}
+	scores := []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6}
+
+	got := ve.groupResults(ids, scores, 3)
+	want := []GroupResult{{"doc-a", 2, 0.1}, {"", 4, 0.3}, {"doc-b", 3, 0.4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := ve.groupResults(ids, scores, 10); len(got) != 4 {
+		t.Errorf("expected 4 groups, got %v", got)
+	}
+}
+
+func TestVectorParentsPersist(t *testing.T) {
+	dir := t.TempDir()
+	ve := openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	for id, parent := range map[int64]string{1: "doc-a", 2: "doc-b"} {
+		if err := ve.SetParent(id, parent); err != nil {
+			t.Fatalf("SetParent failed: %v", err)
+		}
+	}
+	if err := ve.SetParent(2, ""); err != nil {
+		t.Fatalf("SetParent (clear) failed: %v", err)
+	}
+	ve.Close()
+
+	ve = openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	if !reflect.DeepEqual(ve.parents, map[int64]string{1: "doc-a"}) {
+		t.Errorf("expected parents {1: doc-a} after reopen, got %v", ve.parents)
+	}
+}
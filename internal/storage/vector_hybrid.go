@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// walTextPrefix marks vector WAL entries that carry the text of a vector
+// (key: prefix + 8-byte ID) rather than the vector itself.
+const walTextPrefix = 'T'
+
+// Fusion methods for HybridSearch.
+const (
+	FusionRRF      = "rrf"
+	FusionWeighted = "weighted"
+)
+
+// rrfK is the rank offset of reciprocal rank fusion (Cormack et al., 2009).
+const rrfK = 60
+
+// hybridOversample is how many candidates each side contributes per result.
+const hybridOversample = 4
+
+// HybridOptions controls how keyword and vector results are fused.
+type HybridOptions struct {
+	// Fusion is FusionRRF (default) or FusionWeighted.
+	Fusion string
+	// Alpha weighs the vector score against the keyword score for weighted
+	// fusion: alpha*vector + (1-alpha)*keyword, with both min-max normalized.
+	Alpha float64
+}
+
+// SetVectorText stores (or, with empty text, clears) the text searched by
+// HybridSearch for a vector ID.
+func (ve *VectorEngineImpl) SetVectorText(id int64, text string) error {
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+
+	if ve.wal != nil {
+		key := make([]byte, 9)
+		key[0] = walTextPrefix
+		binary.LittleEndian.PutUint64(key[1:], uint64(id))
+		if err := ve.wal.WriteEntry(string(key), text); err != nil {
+			return err
+		}
+	}
+	ve.setTextAfterWAL(id, text)
+	return nil
+}
+
+func (ve *VectorEngineImpl) setTextAfterWAL(id int64, text string) {
+	if strings.TrimSpace(text) == "" {
+		ve.text.Remove(id)
+	} else {
+		ve.text.Add(id, text)
+	}
+	atomic.StoreInt32(&ve.textDirty, 1)
+}
+
+// HybridSearch runs a BM25 keyword search over the vector texts and a vector
+// search, and fuses both rankings into one list of up to k IDs with their
+// fused scores (higher is better).
+func (ve *VectorEngineImpl) HybridSearch(query []float32, text string, k int, opts HybridOptions) ([]int64, []float64, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil, errors.New("hybrid search requires query text")
+	}
+	if opts.Fusion == "" {
+		opts.Fusion = FusionRRF
+	}
+	if opts.Fusion != FusionRRF && opts.Fusion != FusionWeighted {
+		return nil, nil, fmt.Errorf("fusion '%s' is not allowed (use %s or %s)", opts.Fusion, FusionRRF, FusionWeighted)
+	}
+	if opts.Alpha < 0 || opts.Alpha > 1 {
+		return nil, nil, errors.New("alpha must be between 0 and 1")
+	}
+
+	n := k * hybridOversample
+	vecIDs, dists, err := ve.SearchTopK(query, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	textIDs, textScores, err := ve.text.Search(text, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keyword hits only count for vectors that still exist
+	ve.lock.RLock()
+	keptIDs, keptScores := textIDs[:0], textScores[:0]
+	for i, id := range textIDs {
+		if _, ok := ve.fileOffsets[id]; ok {
+			keptIDs = append(keptIDs, id)
+			keptScores = append(keptScores, textScores[i])
+			if len(keptIDs) == n {
+				break
+			}
+		}
+	}
+	ve.lock.RUnlock()
+
+	// Turn distances into similarities so that higher is better on both sides
+	vecScores := make([]float64, len(dists))
+	for i, d := range dists {
+		if ve.isSimilarity() {
+			vecScores[i] = float64(d)
+		} else {
+			vecScores[i] = -float64(d)
+		}
+	}
+
+	var ids []int64
+	var scores []float64
+	if opts.Fusion == FusionWeighted {
+		ids, scores = fuseWeighted(vecIDs, vecScores, keptIDs, keptScores, opts.Alpha)
+	} else {
+		ids, scores = fuseRRF(vecIDs, keptIDs)
+	}
+	if len(ids) > k {
+		ids, scores = ids[:k], scores[:k]
+	}
+	return ids, scores, nil
+}
+
+// fuseRRF scores each ID by the sum of 1/(rrfK + rank) over the ranked lists
+// it appears in.
+func fuseRRF(lists ...[]int64) ([]int64, []float64) {
+	fused := make(map[int64]float64)
+	for _, list := range lists {
+		for rank, id := range list {
+			fused[id] += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+	return sortFused(fused)
+}
+
+// fuseWeighted min-max normalizes both score lists to [0, 1] and combines
+// them as alpha*vector + (1-alpha)*keyword; a missing score counts as 0.
+func fuseWeighted(vecIDs []int64, vecScores []float64, textIDs []int64, textScores []float64, alpha float64) ([]int64, []float64) {
+	fused := make(map[int64]float64)
+	add := func(ids []int64, scores []float64, weight float64) {
+		if len(scores) == 0 {
+			return
+		}
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, s := range scores {
+			lo, hi = math.Min(lo, s), math.Max(hi, s)
+		}
+		for i, id := range ids {
+			norm := 1.0
+			if hi > lo {
+				norm = (scores[i] - lo) / (hi - lo)
+			}
+			fused[id] += weight * norm
+		}
+	}
+	add(vecIDs, vecScores, alpha)
+	add(textIDs, textScores, 1-alpha)
+	return sortFused(fused)
+}
+
+func sortFused(fused map[int64]float64) ([]int64, []float64) {
+	ids := make([]int64, 0, len(fused))
+	for id := range fused {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if fused[ids[i]] != fused[ids[j]] {
+			return fused[ids[i]] > fused[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	scores := make([]float64, len(ids))
+	for i, id := range ids {
+		scores[i] = fused[id]
+	}
+	return ids, scores
+}
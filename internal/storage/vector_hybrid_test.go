@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHybridFusion(t *testing.T) {
+	vecIDs := []int64{1, 2, 3}
+	textIDs := []int64{3, 4, 1}
+
+	ids, scores := fuseRRF(vecIDs, textIDs)
+	// 1 and 3 appear in both lists (ranks 1+3 and 3+1), so they tie at the top
+	if !reflect.DeepEqual(ids, []int64{1, 3, 2, 4}) {
+		t.Fatalf("unexpected RRF order %v (scores %v)", ids, scores)
+	}
+	if want := 1.0/61 + 1.0/63; scores[0] != want || scores[1] != want {
+		t.Fatalf("unexpected RRF scores %v", scores)
+	}
+
+	// Vector similarities (negated distances) and BM25 scores
+	vecScores := []float64{-0.1, -0.5, -0.9}
+	textScores := []float64{8, 4, 2}
+
+	ids, scores = fuseWeighted(vecIDs, vecScores, textIDs, textScores, 1)
+	if !reflect.DeepEqual(ids[:3], []int64{1, 2, 3}) || scores[0] != 1 {
+		t.Fatalf("alpha=1 should rank by vector score only, got %v %v", ids, scores)
+	}
+	ids, _ = fuseWeighted(vecIDs, vecScores, textIDs, textScores, 0)
+	if ids[0] != 3 || ids[1] != 4 {
+		t.Fatalf("alpha=0 should rank by keyword score only, got %v", ids)
+	}
+	ids, scores = fuseWeighted(vecIDs, vecScores, textIDs, textScores, 0.5)
+	// 3: 0.5*0 + 0.5*1, 1: 0.5*1 + 0.5*0, 2: 0.5*0.5, 4: 0.5*(2/6)
+	if !reflect.DeepEqual(ids, []int64{1, 3, 2, 4}) || scores[0] != 0.5 || scores[2] != 0.25 {
+		t.Fatalf("unexpected weighted fusion %v %v", ids, scores)
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Vectors can be addressed by string keys such as UUIDs. Keys that are
+// int64 literals are used as the vector ID directly; other keys get an ID
+// allocated downwards from -2 (FAISS reserves -1), recorded in the attribute
+// log and the WAL. Mappings are kept when a vector is removed, so a key
+// inserted again gets its old ID back.
+const (
+	attrKey      = 'K'
+	walKeyPrefix = 'K'
+)
+
+// firstKeyID is the ID given to the first string key of a space.
+const firstKeyID = -2
+
+// vectorKeys is the bidirectional string key mapping of a space.
+type vectorKeys struct {
+	ids  map[string]int64
+	keys map[int64]string
+	next int64
+}
+
+func newVectorKeys() *vectorKeys {
+	return &vectorKeys{
+		ids:  make(map[string]int64),
+		keys: make(map[int64]string),
+		next: firstKeyID,
+	}
+}
+
+func (vk *vectorKeys) set(id int64, key string) {
+	vk.ids[key] = id
+	vk.keys[id] = key
+	if id <= vk.next {
+		vk.next = id - 1
+	}
+}
+
+// ResolveKey returns the vector ID of a key. With create, a string key seen
+// for the first time is given a new ID; otherwise unknown keys are an error.
+func (ve *VectorEngineImpl) ResolveKey(key string, create bool) (int64, error) {
+	if key == "" {
+		return 0, errors.New("vector key must not be empty")
+	}
+	if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+		if id == -1 {
+			return 0, errors.New("vector ID -1 is reserved")
+		}
+		ve.lock.RLock()
+		owner, taken := ve.keys.keys[id]
+		ve.lock.RUnlock()
+		if taken {
+			return 0, fmt.Errorf("vector ID %d is in use by key '%s'", id, owner)
+		}
+		return id, nil
+	}
+
+	ve.lock.RLock()
+	id, ok := ve.keys.ids[key]
+	ve.lock.RUnlock()
+	if ok {
+		return id, nil
+	}
+	if !create {
+		return 0, fmt.Errorf("vector '%s' not found", key)
+	}
+
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+	if id, ok := ve.keys.ids[key]; ok {
+		return id, nil // created concurrently
+	}
+	id = ve.keys.next
+	for ve.vectorExistsLocked(id) {
+		id-- // taken by a vector inserted with a negative numeric key
+	}
+	if ve.wal != nil {
+		if err := ve.wal.WriteEntry(walAttrKey(walKeyPrefix, id), key); err != nil {
+			return 0, err
+		}
+	}
+	if err := ve.setKeyLocked(id, key); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// VectorKey returns the key a vector was inserted with: its string key, or
+// the ID itself when isString is false.
+func (ve *VectorEngineImpl) VectorKey(id int64) (key string, isString bool) {
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	if key, ok := ve.keys.keys[id]; ok {
+		return key, true
+	}
+	return strconv.FormatInt(id, 10), false
+}
+
+// setKeyLocked records a key mapping; the caller must hold ve.lock.
+func (ve *VectorEngineImpl) setKeyLocked(id int64, key string) error {
+	if _, ok := ve.keys.keys[id]; ok {
+		return nil
+	}
+	if err := ve.appendAttr(attrKey, id, key); err != nil {
+		return fmt.Errorf("write vector key: %w", err)
+	}
+	ve.keys.set(id, key)
+	return nil
+}
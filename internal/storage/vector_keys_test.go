@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestVectorKeys(t *testing.T) {
+	dir := t.TempDir()
+	ve := openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	// A negative numeric ID already stored is skipped by allocation
+	if err := ve.InsertVector(-3, []float32{1, 1}); err != nil {
+		t.Fatalf("InsertVector failed: %v", err)
+	}
+
+	a, err := ve.ResolveKey("7f9c2ba4-e88f-11e8-9f32-f2801f1b9fd1", true)
+	if err != nil || a != -2 {
+		t.Fatalf("expected ID -2 for the first key, got %d, %v", a, err)
+	}
+	if again, _ := ve.ResolveKey("7f9c2ba4-e88f-11e8-9f32-f2801f1b9fd1", true); again != a {
+		t.Errorf("expected the same ID for a known key, got %d", again)
+	}
+	b, err := ve.ResolveKey("sha256:abc", true)
+	if err != nil || b != -4 {
+		t.Fatalf("expected ID -4 for the second key, got %d, %v", b, err)
+	}
+	if id, err := ve.ResolveKey("42", false); err != nil || id != 42 {
+		t.Errorf("expected numeric key 42 as is, got %d, %v", id, err)
+	}
+	if _, err := ve.ResolveKey("-2", true); err == nil {
+		t.Error("expected an error for a numeric key taken by a string key")
+	}
+	if _, err := ve.ResolveKey("unknown", false); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+	if _, err := ve.ResolveKey("-1", true); err == nil {
+		t.Error("expected an error for the reserved ID -1")
+	}
+	// A vector still queued for the data file is skipped too
+	ve.persistBuf = append(ve.persistBuf, struct {
+		id  int64
+		vec []float32
+	}{-5, nil})
+	if c, err := ve.ResolveKey("queued-neighbour", true); err != nil || c != -6 {
+		t.Fatalf("expected ID -6 next to the queued vector -5, got %d, %v", c, err)
+	}
+	ve.persistBuf = nil
+	if key, isString := ve.VectorKey(b); key != "sha256:abc" || !isString {
+		t.Errorf("expected key sha256:abc, got %q (%v)", key, isString)
+	}
+	if key, isString := ve.VectorKey(42); key != "42" || isString {
+		t.Errorf("expected numeric key 42, got %q (%v)", key, isString)
+	}
+	ve.Close()
+
+	// Mappings survive a restart and allocation continues below them
+	ve = openTestVectorEngine(t, dir, 2, faiss.MetricL2, "")
+	if id, err := ve.ResolveKey("sha256:abc", false); err != nil || id != -4 {
+		t.Errorf("expected ID -4 after reopen, got %d, %v", id, err)
+	}
+	if id, _ := ve.ResolveKey("new", true); id != -7 {
+		t.Errorf("expected ID -7 for a new key after reopen, got %d", id)
+	}
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Page sizes of ListVectorIDs.
+const (
+	DefaultListLimit = 1000
+	MaxListLimit     = 10000
+)
+
+// ListOptions select the vectors of a listing or export. Vectors are
+// returned in ascending ID order, so a cursor stays valid while the space
+// changes: vectors inserted behind it are simply not seen.
+type ListOptions struct {
+	// Cursor is the NextCursor of the previous page; empty starts at the
+	// lowest ID
+	Cursor string
+	// Min and Max bound the IDs, inclusive, when set
+	Min, Max *int64
+	// Limit is the page size
+	Limit int
+}
+
+// VectorIDPage is one page of ListVectorIDs.
+type VectorIDPage struct {
+	IDs []int64 `json:"ids"`
+	// String keys of the listed vectors inserted with one
+	Keys map[int64]string `json:"keys,omitempty"`
+	// Empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ExportedVector is a stored vector with its key, payload and parent.
+type ExportedVector struct {
+	ID      int64           `json:"id"`
+	Key     string          `json:"key,omitempty"`
+	Vector  []float32       `json:"vector"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Parent  string          `json:"parent,omitempty"`
+}
+
+// ListVectorIDs returns a page of the live vector IDs, including vectors
+// still waiting for the index to be trained. A zero limit means
+// DefaultListLimit.
+func (ve *VectorEngineImpl) ListVectorIDs(opts ListOptions) (*VectorIDPage, error) {
+	if opts.Limit == 0 {
+		opts.Limit = DefaultListLimit
+	}
+	if opts.Limit < 0 || opts.Limit > MaxListLimit {
+		return nil, fmt.Errorf("page size must be between 1 and %d", MaxListLimit)
+	}
+	ve.flushData(true)
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	ids, next, err := ve.selectIDsLocked(opts)
+	if err != nil {
+		return nil, err
+	}
+	page := &VectorIDPage{IDs: ids, NextCursor: next}
+	for _, id := range ids {
+		if key, ok := ve.keys.keys[id]; ok {
+			if page.Keys == nil {
+				page.Keys = make(map[int64]string)
+			}
+			page.Keys[id] = key
+		}
+	}
+	return page, nil
+}
+
+// ExportVectors passes the selected vectors to fn in ascending ID order and
+// returns the cursor of the next page; a zero limit exports every vector
+// from the cursor on. Vectors are read in batches, so vectors removed during
+// the export may be left out and are never returned half-written.
+func (ve *VectorEngineImpl) ExportVectors(opts ListOptions, fn func(ExportedVector) error) (string, error) {
+	if opts.Limit < 0 {
+		return "", errors.New("limit must not be negative")
+	}
+	ve.flushData(true)
+	ve.lock.RLock()
+	ids, next, err := ve.selectIDsLocked(opts)
+	ve.lock.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	for start := 0; start < len(ids); start += rebuildAddBatch {
+		end := start + rebuildAddBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch, err := ve.exportBatch(ids[start:end])
+		if err != nil {
+			return "", err
+		}
+		for _, rec := range batch {
+			if err := fn(rec); err != nil {
+				return "", err
+			}
+		}
+	}
+	return next, nil
+}
+
+// exportBatch reads the vectors of the IDs that are still live.
+func (ve *VectorEngineImpl) exportBatch(ids []int64) ([]ExportedVector, error) {
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	out := make([]ExportedVector, 0, len(ids))
+	for _, id := range ids {
+		var vec []float32
+		if offset, ok := ve.fileOffsets[id]; ok {
+			var err error
+			if vec, err = ve.readVectorAt(offset); err != nil {
+				return nil, err
+			}
+		} else if pending, ok := ve.pendingAdd[id]; ok {
+			vec = append([]float32(nil), pending...)
+		} else {
+			continue // removed since the export started
+		}
+		rec := ExportedVector{ID: id, Key: ve.keys.keys[id], Vector: vec, Parent: ve.parents[id]}
+		if payload, ok := ve.payloads.get(id); ok {
+			rec.Payload = json.RawMessage(payload)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// selectIDsLocked returns the sorted live IDs after the cursor within the
+// range, at most opts.Limit of them when it is positive, and the cursor of
+// the next page. The caller must hold ve.lock.
+func (ve *VectorEngineImpl) selectIDsLocked(opts ListOptions) ([]int64, string, error) {
+	var after *int64
+	if opts.Cursor != "" {
+		c, err := strconv.ParseInt(opts.Cursor, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid cursor '%s'", opts.Cursor)
+		}
+		after = &c
+	}
+	if opts.Min != nil && opts.Max != nil && *opts.Min > *opts.Max {
+		return nil, "", errors.New("ID range minimum is greater than its maximum")
+	}
+	match := func(id int64) bool {
+		return (after == nil || id > *after) &&
+			(opts.Min == nil || id >= *opts.Min) &&
+			(opts.Max == nil || id <= *opts.Max)
+	}
+
+	// A paged selection keeps only the lowest Limit+1 IDs in a max-heap, one
+	// more than the page to tell whether another page follows
+	var ids idMaxHeap
+	keep := func(id int64) {
+		switch {
+		case opts.Limit <= 0:
+			ids = append(ids, id)
+		case len(ids) <= opts.Limit:
+			heap.Push(&ids, id)
+		case id < ids[0]:
+			ids[0] = id
+			heap.Fix(&ids, 0)
+		}
+	}
+	for id := range ve.fileOffsets {
+		if match(id) {
+			keep(id)
+		}
+	}
+	for id := range ve.pendingAdd {
+		if _, stored := ve.fileOffsets[id]; !stored && match(id) {
+			keep(id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if opts.Limit > 0 && len(ids) > opts.Limit {
+		ids = ids[:opts.Limit]
+		return ids, strconv.FormatInt(ids[len(ids)-1], 10), nil
+	}
+	return ids, "", nil
+}
+
+// idMaxHeap is a container/heap of IDs with the largest on top.
+type idMaxHeap []int64
+
+func (h idMaxHeap) Len() int           { return len(h) }
+func (h idMaxHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h idMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *idMaxHeap) Push(x interface{}) { *h = append(*h, x.(int64)) }
+
+func (h *idMaxHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
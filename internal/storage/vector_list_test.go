@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestListVectorIDs(t *testing.T) {
+	ve := openTestVectorEngine(t, t.TempDir(), 2, faiss.MetricL2, "")
+	if id, err := ve.ResolveKey("doc-a", true); err != nil || id != -2 {
+		t.Fatalf("expected ID -2 for key doc-a, got %d, %v", id, err)
+	}
+	// A vector still waiting for the index is listed too
+	ve.pendingAdd[9] = []float32{9, 9}
+	for _, id := range []int64{5, -2, 3, 7, 1} {
+		if err := ve.appendToDataFile(id, []float32{float32(id), 0}); err != nil {
+			t.Fatalf("appendToDataFile failed: %v", err)
+		}
+	}
+	if err := ve.appendToDataFile(7, nil); err != nil {
+		t.Fatalf("appendToDataFile failed: %v", err)
+	}
+	if err := ve.SetPayload(3, `{"color":"red"}`); err != nil {
+		t.Fatalf("SetPayload failed: %v", err)
+	}
+	if err := ve.SetParent(3, "doc-3"); err != nil {
+		t.Fatalf("SetParent failed: %v", err)
+	}
+
+	var got []int64
+	opts := ListOptions{Limit: 2}
+	for pages := 0; ; pages++ {
+		page, err := ve.ListVectorIDs(opts)
+		if err != nil {
+			t.Fatalf("ListVectorIDs failed: %v", err)
+		}
+		got = append(got, page.IDs...)
+		if pages == 0 && page.Keys[-2] != "doc-a" {
+			t.Errorf("expected the key of ID -2 on the first page, got %v", page.Keys)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if want := []int64{-2, 1, 3, 5, 9}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected IDs %v, got %v", want, got)
+	}
+
+	min, max := int64(0), int64(5)
+	page, err := ve.ListVectorIDs(ListOptions{Min: &min, Max: &max, Cursor: "1"})
+	if err != nil || !reflect.DeepEqual(page.IDs, []int64{3, 5}) || page.NextCursor != "" {
+		t.Fatalf("expected IDs 3 and 5 in range after cursor 1, got %+v (%v)", page, err)
+	}
+	if _, err := ve.ListVectorIDs(ListOptions{Cursor: "abc"}); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+	if _, err := ve.ListVectorIDs(ListOptions{Limit: MaxListLimit + 1}); err == nil {
+		t.Error("expected an error for an oversized page")
+	}
+
+	var exported []ExportedVector
+	next, err := ve.ExportVectors(ListOptions{Min: &min}, func(rec ExportedVector) error {
+		exported = append(exported, rec)
+		return nil
+	})
+	if err != nil || next != "" {
+		t.Fatalf("ExportVectors failed: %v (next %q)", err, next)
+	}
+	if len(exported) != 4 || exported[3].ID != 9 || !reflect.DeepEqual(exported[3].Vector, []float32{9, 9}) {
+		t.Fatalf("expected 4 vectors ending with pending ID 9, got %+v", exported)
+	}
+	if rec := exported[1]; rec.ID != 3 || string(rec.Payload) != `{"color":"red"}` || rec.Parent != "doc-3" || !reflect.DeepEqual(rec.Vector, []float32{3, 0}) {
+		t.Fatalf("unexpected export of ID 3: %+v", rec)
+	}
+}
+
+func TestListVectorIDsPagesInOrder(t *testing.T) {
+	ve := openTestVectorEngine(t, t.TempDir(), 1, faiss.MetricL2, "")
+	perm := rand.Perm(500)
+	ids := make([]int64, len(perm))
+	vectors := make([][]float32, len(perm))
+	for i, id := range perm {
+		ids[i], vectors[i] = int64(id)-250, []float32{float32(id)}
+	}
+	if err := ve.InsertVectors(ids, vectors); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+
+	var got []int64
+	opts := ListOptions{Limit: 7}
+	for {
+		page, err := ve.ListVectorIDs(opts)
+		if err != nil {
+			t.Fatalf("ListVectorIDs failed: %v", err)
+		}
+		got = append(got, page.IDs...)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if len(got) != 500 {
+		t.Fatalf("expected 500 IDs, got %d", len(got))
+	}
+	for i, id := range got {
+		if id != int64(i)-250 {
+			t.Fatalf("expected ID %d at position %d, got %d", i-250, i, id)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+// MetricCosine selects cosine similarity for NewVectorEngine. FAISS has no
+// cosine metric: vectors and queries are L2-normalized by the engine and
+// searched by inner product, so scores are cosine similarities in [-1, 1]
+// (higher is closer). The value is outside the range of FAISS metric types.
+const MetricCosine = -1
+
+// faissMetric returns the FAISS metric an engine metric is searched with.
+func faissMetric(metric int) int {
+	if metric == MetricCosine {
+		return faiss.MetricInnerProduct
+	}
+	return metric
+}
+
+// isSimilarity reports whether search scores grow with closeness.
+func (ve *VectorEngineImpl) isSimilarity() bool {
+	return ve.metric == faiss.MetricInnerProduct || ve.metric == MetricCosine
+}
+
+// prepareVector returns the vector as stored and searched: a normalized copy
+// for cosine spaces, the vector itself otherwise.
+func (ve *VectorEngineImpl) prepareVector(v []float32) ([]float32, error) {
+	if ve.metric != MetricCosine {
+		return v, nil
+	}
+	return normalizeVector(v)
+}
+
+// ValidateVector reports the error InsertVector would return for vector, so
+// that callers can reject it before allocating an ID for a string key.
+func (ve *VectorEngineImpl) ValidateVector(vector []float32) error {
+	if len(vector) != ve.maxVectorSize {
+		return fmt.Errorf("vector length mismatch: expected %d", ve.maxVectorSize)
+	}
+	_, err := ve.prepareVector(vector)
+	return err
+}
+
+// finishScores clamps cosine similarities into [-1, 1], which float rounding
+// of normalized inner products can overshoot.
+func (ve *VectorEngineImpl) finishScores(scores []float32) []float32 {
+	if ve.metric != MetricCosine {
+		return scores
+	}
+	for i, s := range scores {
+		if s > 1 {
+			scores[i] = 1
+		} else if s < -1 {
+			scores[i] = -1
+		}
+	}
+	return scores
+}
+
+// normalizeVector returns a unit-length copy of v.
+func normalizeVector(v []float32) ([]float32, error) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return nil, errors.New("cosine metric requires a non-zero, finite vector")
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeVector(t *testing.T) {
+	v := []float32{3, 4}
+	n, err := normalizeVector(v)
+	if err != nil {
+		t.Fatalf("normalizeVector failed: %v", err)
+	}
+	if n[0] != 0.6 || n[1] != 0.8 {
+		t.Fatalf("expected [0.6 0.8], got %v", n)
+	}
+	if v[0] != 3 {
+		t.Fatal("normalizeVector modified its input")
+	}
+	if _, err := normalizeVector([]float32{0, 0}); err == nil {
+		t.Fatal("expected an error for a zero vector")
+	}
+	if _, err := normalizeVector([]float32{float32(math.Inf(1)), 1}); err == nil {
+		t.Fatal("expected an error for an infinite vector")
+	}
+
+	ve := openTestVectorEngine(t, t.TempDir(), 2, MetricCosine, "")
+	if got := ve.finishScores([]float32{1.0000001, 0.5, -1.0000001}); got[0] != 1 || got[1] != 0.5 || got[2] != -1 {
+		t.Fatalf("unexpected clamped scores %v", got)
+	}
+	if !ve.isSimilarity() {
+		t.Fatal("cosine scores are similarities")
+	}
+}
+
+func TestValidateVector(t *testing.T) {
+	ve := openTestVectorEngine(t, t.TempDir(), 2, MetricCosine, "")
+	if err := ve.ValidateVector([]float32{1, 2}); err != nil {
+		t.Fatalf("ValidateVector failed: %v", err)
+	}
+	for _, v := range [][]float32{{1}, {0, 0}, {1, 2, 3}} {
+		if err := ve.ValidateVector(v); err == nil {
+			t.Errorf("ValidateVector(%v) succeeded, want an error", v)
+		}
+	}
+}
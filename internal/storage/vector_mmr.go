@@ -0,0 +1,86 @@
+package storage
+
+import "math"
+
+// mmrLocked picks k of the candidates by maximal marginal relevance: each
+// pick maximizes
+//
+//	lambda * sim(query, v) - (1 - lambda) * max sim(v, picked)
+//
+// over the stored vectors, so lambda 1 ranks by relevance only and lower
+// values trade relevance for diversity. Similarity is the space's metric,
+// negated for distance metrics. The returned scores are the exact distances
+// (or similarities) to the query. The caller must hold ve.lock.
+func (ve *VectorEngineImpl) mmrLocked(query []float32, ids []int64, k int, lambda float64) ([]int64, []float32, error) {
+	found := make([]int64, 0, len(ids))
+	vecs := make([][]float32, 0, len(ids))
+	for _, id := range ids {
+		offset, ok := ve.fileOffsets[id]
+		if !ok {
+			continue
+		}
+		vec, err := ve.readVectorAt(offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		found = append(found, id)
+		vecs = append(vecs, vec)
+	}
+
+	distance := exactDistances[ve.metric]
+	sim := func(a, b []float32) float64 {
+		if ve.isSimilarity() {
+			return distance(a, b)
+		}
+		return -distance(a, b)
+	}
+	picked := mmrSelect(query, vecs, k, lambda, sim)
+	outIDs := make([]int64, len(picked))
+	outD := make([]float32, len(picked))
+	for i, p := range picked {
+		outIDs[i] = found[p]
+		outD[i] = float32(distance(query, vecs[p]))
+	}
+	return outIDs, outD, nil
+}
+
+// mmrSelect returns the indexes of up to k vectors in pick order.
+func mmrSelect(query []float32, vecs [][]float32, k int, lambda float64, sim func(a, b []float32) float64) []int {
+	relevance := make([]float64, len(vecs))
+	for i, v := range vecs {
+		relevance[i] = sim(query, v)
+	}
+	// Highest similarity of each candidate to any picked vector
+	redundancy := make([]float64, len(vecs))
+	for i := range redundancy {
+		redundancy[i] = math.Inf(-1)
+	}
+	used := make([]bool, len(vecs))
+
+	var picked []int
+	for len(picked) < k && len(picked) < len(vecs) {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range vecs {
+			if used[i] {
+				continue
+			}
+			score := lambda * relevance[i]
+			if len(picked) > 0 {
+				score -= (1 - lambda) * redundancy[i]
+			}
+			if best == -1 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		used[best] = true
+		picked = append(picked, best)
+		for i := range vecs {
+			if !used[i] {
+				if s := sim(vecs[i], vecs[best]); s > redundancy[i] {
+					redundancy[i] = s
+				}
+			}
+		}
+	}
+	return picked
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// searchParamDefaults lists the search-time parameters accepted per query and
+// per space, with the values FAISS starts from. They are set through a FAISS
+// ParameterSpace, so "quantizer_efSearch" reaches the HNSW quantizer of an
+// IVF index and "k_factor" applies to refined indexes.
+var searchParamDefaults = map[string]float64{
+	"nprobe":             1,
+	"efSearch":           16,
+	"quantizer_efSearch": 16,
+	"k_factor":           1,
+}
+
+// ValidateSearchParams checks search parameter names and values.
+func ValidateSearchParams(params map[string]float64) error {
+	for name, val := range params {
+		if _, ok := searchParamDefaults[name]; !ok {
+			names := make([]string, 0, len(searchParamDefaults))
+			for n := range searchParamDefaults {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("search parameter '%s' is not allowed (use %s)", name, strings.Join(names, ", "))
+		}
+		if val < 1 || val != float64(int64(val)) {
+			return fmt.Errorf("search parameter '%s' must be a positive integer", name)
+		}
+	}
+	return nil
+}
+
+// ParseSearchParams parses parameters written the FAISS way, e.g.
+// "nprobe=16,efSearch=64".
+func ParseSearchParams(s string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid search parameter '%s' (expected name=value)", part)
+		}
+		val, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for search parameter '%s'", kv[0])
+		}
+		params[strings.TrimSpace(kv[0])] = val
+	}
+	return params, ValidateSearchParams(params)
+}
+
+// SetSearchDefaults sets the search parameters used when a query gives none.
+// Parameters dropped from the defaults go back to the FAISS defaults.
+func (ve *VectorEngineImpl) SetSearchDefaults(params map[string]float64) error {
+	if err := ValidateSearchParams(params); err != nil {
+		return err
+	}
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+
+	old := ve.searchDefaults
+	ve.searchDefaults = params
+	if err := ve.restoreSearchParams(old); err != nil {
+		return err
+	}
+	return ve.applySearchParams(params)
+}
+
+// lockForSearch takes the engine lock for a search and returns the nprobe
+// to pass with each k-NN search call, 0 for the index setting. A per-query
+// nprobe on an IVF index travels in the FAISS search parameters, under the
+// read lock. FAISS has no per-call form of the other parameters, or of any
+// parameter for range searches, so those change the index settings: the
+// search runs exclusively and restores the space defaults when unlocked.
+func (ve *VectorEngineImpl) lockForSearch(opts SearchOptions, ranged bool) (int, func(), error) {
+	if len(opts.Params) == 0 {
+		ve.lock.RLock()
+		return 0, ve.lock.RUnlock, nil
+	}
+	if err := ValidateSearchParams(opts.Params); err != nil {
+		return 0, nil, err
+	}
+	if nprobe, ok := opts.Params["nprobe"]; ok && len(opts.Params) == 1 && !ranged {
+		ve.lock.RLock()
+		if ve.idMapIndex.isIVF() {
+			return int(nprobe), ve.lock.RUnlock, nil
+		}
+		ve.lock.RUnlock()
+	}
+	ve.lock.Lock()
+	if err := ve.applySearchParams(opts.Params); err != nil {
+		ve.restoreSearchParams(opts.Params)
+		ve.lock.Unlock()
+		return 0, nil, err
+	}
+	return 0, func() {
+		if err := ve.restoreSearchParams(opts.Params); err != nil {
+			log.Printf("Restoring search parameters failed: %v", err)
+		}
+		ve.lock.Unlock()
+	}, nil
+}
+
+// applySearchParams sets parameters on the index; the caller must hold ve.lock.
+func (ve *VectorEngineImpl) applySearchParams(params map[string]float64) error {
+	if len(params) == 0 {
+		return nil
+	}
+	for name, val := range params {
+		if err := ve.idMapIndex.setParameter(name, val); err != nil {
+			return fmt.Errorf("set search parameter '%s' for index type %s: %w", name, ve.indexType, err)
+		}
+	}
+	return nil
+}
+
+// restoreSearchParams resets the given parameters to the space default, or
+// the FAISS default when the space has none; the caller must hold ve.lock.
+// Parameters the index does not have are skipped.
+func (ve *VectorEngineImpl) restoreSearchParams(params map[string]float64) error {
+	if len(params) == 0 {
+		return nil
+	}
+	for name := range params {
+		val, ok := ve.searchDefaults[name]
+		if !ok {
+			val = searchParamDefaults[name]
+		}
+		_ = ve.idMapIndex.setParameter(name, val)
+	}
+	return nil
+}
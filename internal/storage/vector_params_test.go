@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestParseSearchParams(t *testing.T) {
+	params, err := ParseSearchParams("nprobe=16, efSearch=64")
+	if err != nil {
+		t.Fatalf("ParseSearchParams failed: %v", err)
+	}
+	if want := map[string]float64{"nprobe": 16, "efSearch": 64}; !reflect.DeepEqual(params, want) {
+		t.Fatalf("expected %v, got %v", want, params)
+	}
+	if params, err := ParseSearchParams(""); err != nil || len(params) != 0 {
+		t.Fatalf("expected no parameters, got %v %v", params, err)
+	}
+
+	for _, bad := range []string{"nprobe", "nprobe=x", "nprobe=0", "nprobe=1.5", "ef=10"} {
+		if _, err := ParseSearchParams(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestConcurrentSearchesWithNprobe(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/vector_nprobe_data.db"
+	indexPath := "testdata/vector_nprobe_index.faiss"
+	walPath := "testdata/vector_nprobe_wal.db"
+	for _, p := range []string{dataPath, indexPath, walPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "IVF4,Flat", faiss.MetricL2, false)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+	for id := int64(0); id < 64; id++ {
+		if err := ve.InsertVector(id, []float32{float32(id), float32(id % 8)}); err != nil {
+			t.Fatalf("InsertVector failed: %v", err)
+		}
+	}
+
+	// Searches probing every list are exact, whatever the other searches
+	// running alongside them probe.
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			nprobe := float64(1 + 3*(g%2))
+			for id := int64(0); id < 64; id++ {
+				query := []float32{float32(id), float32(id % 8)}
+				ids, _, err := ve.SearchTopKWithOptions(query, 1, SearchOptions{Params: map[string]float64{"nprobe": nprobe}})
+				if err != nil {
+					t.Errorf("search with nprobe %v failed: %v", nprobe, err)
+					return
+				}
+				if nprobe == 4 && (len(ids) != 1 || ids[0] != id) {
+					t.Errorf("expected ID %d with nprobe 4, got %v", id, ids)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,403 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shibudb.org/shibudb-server/internal/filter"
+)
+
+// Per-vector attributes (payloads) live in an append-only log next to the
+// vector data file:
+//
+//	kind byte | id int64 | len uint32 | data
+//
+// The last record for an (id, kind) wins; empty data clears the attribute.
+const (
+	attrPayload = 'P'
+)
+
+// walPayloadPrefix marks vector WAL entries that carry a payload
+// (key: prefix + 8-byte ID).
+const walPayloadPrefix = 'P'
+
+const attrHeaderSize = 1 + 8 + 4
+
+// SetPayload attaches a JSON object to a vector, replacing any previous
+// payload. An empty payload removes it.
+func (ve *VectorEngineImpl) SetPayload(id int64, payload string) error {
+	if err := ValidatePayload(payload); err != nil {
+		return err
+	}
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+
+	if ve.wal != nil {
+		if err := ve.wal.WriteEntry(walAttrKey(walPayloadPrefix, id), payload); err != nil {
+			return err
+		}
+	}
+	return ve.setPayloadAfterWAL(id, payload)
+}
+
+// ValidatePayload checks that a non-empty payload is a JSON object, so that
+// callers can reject it before storing the vector it belongs to.
+func ValidatePayload(payload string) error {
+	if payload == "" {
+		return nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &obj); err != nil || obj == nil {
+		return errors.New("payload must be a JSON object")
+	}
+	return nil
+}
+
+// GetPayload returns the payload of a vector.
+func (ve *VectorEngineImpl) GetPayload(id int64) (string, error) {
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	payload, ok := ve.payloads.get(id)
+	if !ok {
+		return "", fmt.Errorf("no payload for ID %d", id)
+	}
+	return payload, nil
+}
+
+func (ve *VectorEngineImpl) setPayloadAfterWAL(id int64, payload string) error {
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+	return ve.setPayloadLocked(id, payload)
+}
+
+// setPayloadLocked records a payload; the caller must hold ve.lock.
+func (ve *VectorEngineImpl) setPayloadLocked(id int64, payload string) error {
+	if payload == "" {
+		if _, ok := ve.payloads.get(id); !ok {
+			return nil
+		}
+	}
+	if err := ve.appendAttr(attrPayload, id, payload); err != nil {
+		return fmt.Errorf("write payload: %w", err)
+	}
+	if payload == "" {
+		ve.payloads.remove(id)
+		return nil
+	}
+	return ve.payloads.set(id, payload)
+}
+
+// allowedIDs returns the live IDs whose payload matches expr; the caller must
+// hold ve.lock.
+func (ve *VectorEngineImpl) allowedIDs(expr filter.Expr) []int64 {
+	matched := ve.payloads.match(expr)
+	ids := matched[:0]
+	for _, id := range matched {
+		if _, ok := ve.fileOffsets[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// searchFilteredLocked returns the k nearest neighbours among the allowed
+// IDs, searching with nprobe when positive; the caller must hold ve.lock.
+// The search is restricted with an ID selector; index types that reject
+// search parameters fall back to widening an unfiltered search until k
+// allowed results are found.
+func (ve *VectorEngineImpl) searchFilteredLocked(query []float32, k int, ids []int64, nprobe int) ([]int64, []float32, error) {
+	if len(ids) == 0 || k <= 0 {
+		return nil, nil, nil
+	}
+	searchK := k
+	if searchK > len(ids) {
+		searchK = len(ids)
+	}
+	dists, labels, err := searchWithSelector(ve.idMapIndex, query, int64(searchK), ids, nprobe)
+	if err == nil {
+		var outIDs []int64
+		var outD []float32
+		for i, label := range labels {
+			if label == -1 {
+				continue
+			}
+			if _, exists := ve.fileOffsets[label]; exists {
+				outIDs = append(outIDs, label)
+				outD = append(outD, dists[i])
+			}
+		}
+		return outIDs, outD, nil
+	}
+	log.Printf("Filtered search without ID selector: %v", err)
+
+	allowed := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		allowed[id] = struct{}{}
+	}
+	total := ve.idMapIndex.Ntotal()
+	for n := int64(k) * 2; ; n *= 2 {
+		if n > total {
+			n = total
+		}
+		dists, labels, err := ve.searchIndexLocked(query, n, nprobe)
+		if err != nil {
+			return nil, nil, err
+		}
+		var outIDs []int64
+		var outD []float32
+		for i, label := range labels {
+			if _, ok := allowed[label]; ok {
+				outIDs = append(outIDs, label)
+				outD = append(outD, dists[i])
+				if len(outIDs) == k {
+					return outIDs, outD, nil
+				}
+			}
+		}
+		if n == total {
+			return outIDs, outD, nil
+		}
+	}
+}
+
+func (ve *VectorEngineImpl) appendAttr(kind byte, id int64, data string) error {
+	buf := make([]byte, attrHeaderSize+len(data))
+	buf[0] = kind
+	binary.LittleEndian.PutUint64(buf[1:9], uint64(id))
+	binary.LittleEndian.PutUint32(buf[9:13], uint32(len(data)))
+	copy(buf[attrHeaderSize:], data)
+	pos, err := ve.attrFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	_, err = ve.attrFile.WriteAt(buf, pos)
+	return err
+}
+
+// loadAttrs replays the attribute log, truncating a partially written tail.
+func (ve *VectorEngineImpl) loadAttrs() error {
+	info, err := ve.attrFile.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	offset := int64(0)
+	header := make([]byte, attrHeaderSize)
+	for offset+attrHeaderSize <= size {
+		if _, err := ve.attrFile.ReadAt(header, offset); err != nil {
+			return fmt.Errorf("read attributes: %w", err)
+		}
+		kind := header[0]
+		id := int64(binary.LittleEndian.Uint64(header[1:9]))
+		n := int64(binary.LittleEndian.Uint32(header[9:13]))
+		if offset+attrHeaderSize+n > size {
+			break
+		}
+		data := make([]byte, n)
+		if _, err := ve.attrFile.ReadAt(data, offset+attrHeaderSize); err != nil {
+			return fmt.Errorf("read attributes: %w", err)
+		}
+		switch kind {
+		case attrPayload:
+			if n == 0 {
+				ve.payloads.remove(id)
+			} else if err := ve.payloads.set(id, string(data)); err != nil {
+				log.Printf("Skipping invalid payload for ID %d: %v", id, err)
+			}
+		case attrKey:
+			ve.keys.set(id, string(data))
+		case attrParent:
+			if n == 0 {
+				delete(ve.parents, id)
+			} else {
+				ve.parents[id] = string(data)
+			}
+		}
+		offset += attrHeaderSize + n
+	}
+	if offset < size {
+		log.Printf("Truncating partial attribute record at offset %d", offset)
+		return ve.attrFile.Truncate(offset)
+	}
+	return nil
+}
+
+func walAttrKey(prefix byte, id int64) string {
+	key := make([]byte, 9)
+	key[0] = prefix
+	binary.LittleEndian.PutUint64(key[1:], uint64(id))
+	return string(key)
+}
+
+// payloadIndex keeps decoded payloads plus an inverted index from
+// (path, scalar value) to IDs, used to narrow eq/in filters before they are
+// evaluated.
+type payloadIndex struct {
+	raw      map[int64]string
+	docs     map[int64]interface{}
+	postings map[string]map[int64]struct{}
+}
+
+func newPayloadIndex() *payloadIndex {
+	return &payloadIndex{
+		raw:      make(map[int64]string),
+		docs:     make(map[int64]interface{}),
+		postings: make(map[string]map[int64]struct{}),
+	}
+}
+
+func (pi *payloadIndex) get(id int64) (string, bool) {
+	raw, ok := pi.raw[id]
+	return raw, ok
+}
+
+func (pi *payloadIndex) set(id int64, raw string) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil || doc == nil {
+		return errors.New("payload must be a JSON object")
+	}
+	pi.remove(id)
+	pi.raw[id] = raw
+	pi.docs[id] = doc
+	for _, term := range payloadTerms(doc) {
+		ids, ok := pi.postings[term]
+		if !ok {
+			ids = make(map[int64]struct{})
+			pi.postings[term] = ids
+		}
+		ids[id] = struct{}{}
+	}
+	return nil
+}
+
+func (pi *payloadIndex) remove(id int64) {
+	doc, ok := pi.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range payloadTerms(doc) {
+		if ids, ok := pi.postings[term]; ok {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(pi.postings, term)
+			}
+		}
+	}
+	delete(pi.docs, id)
+	delete(pi.raw, id)
+}
+
+// match returns the sorted IDs whose payload matches expr.
+func (pi *payloadIndex) match(expr filter.Expr) []int64 {
+	var ids []int64
+	if cands, ok := pi.candidates(expr); ok {
+		for id := range cands {
+			if expr.Match(pi.docs[id]) {
+				ids = append(ids, id)
+			}
+		}
+	} else {
+		for id, doc := range pi.docs {
+			if expr.Match(doc) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// candidates returns a superset of the IDs matching expr when the inverted
+// index can narrow it down; ok is false when a full scan is needed.
+func (pi *payloadIndex) candidates(expr filter.Expr) (map[int64]struct{}, bool) {
+	switch e := expr.(type) {
+	case filter.Compare:
+		if e.Op != "eq" {
+			return nil, false
+		}
+		return pi.lookup(e.Path, []interface{}{e.Value})
+	case filter.In:
+		return pi.lookup(e.Path, e.Values)
+	case filter.And:
+		var best map[int64]struct{}
+		found := false
+		for _, child := range e {
+			cands, ok := pi.candidates(child)
+			if ok && (!found || len(cands) < len(best)) {
+				best, found = cands, true
+			}
+		}
+		return best, found
+	case filter.Or:
+		union := make(map[int64]struct{})
+		for _, child := range e {
+			cands, ok := pi.candidates(child)
+			if !ok {
+				return nil, false
+			}
+			for id := range cands {
+				union[id] = struct{}{}
+			}
+		}
+		return union, true
+	}
+	return nil, false
+}
+
+func (pi *payloadIndex) lookup(path string, values []interface{}) (map[int64]struct{}, bool) {
+	// Paths with array positions are not in the inverted index
+	for _, seg := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(seg); err == nil {
+			return nil, false
+		}
+	}
+	ids := make(map[int64]struct{})
+	for _, v := range values {
+		term, ok := encodeIndexValue(v)
+		if !ok {
+			return nil, false
+		}
+		for id := range pi.postings[path+"\x00"+term] {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids, true
+}
+
+// payloadTerms lists "path\x00term" for every scalar in a payload; nested
+// objects extend the path and array elements share their array's path.
+func payloadTerms(doc interface{}) []string {
+	var terms []string
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, child := range val {
+				if prefix == "" {
+					walk(k, child)
+				} else {
+					walk(prefix+"."+k, child)
+				}
+			}
+		case []interface{}:
+			for _, elem := range val {
+				if _, nested := elem.([]interface{}); !nested {
+					walk(prefix, elem)
+				}
+			}
+		default:
+			if term, ok := encodeIndexValue(val); ok && prefix != "" {
+				terms = append(terms, prefix+"\x00"+term)
+			}
+		}
+	}
+	walk("", doc)
+	return terms
+}
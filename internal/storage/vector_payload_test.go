@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shibudb.org/shibudb-server/internal/filter"
+)
+
+func TestPayloadIndex(t *testing.T) {
+	pi := newPayloadIndex()
+	payloads := map[int64]string{
+		1: `{"color":"red","size":10,"tags":["sale","new"],"brand":{"name":"acme"}}`,
+		2: `{"color":"blue","size":12,"tags":["new"]}`,
+		3: `{"color":"red","size":14}`,
+		4: `{"color":"green","brand":{"name":"acme"}}`,
+	}
+	for id, p := range payloads {
+		if err := pi.set(id, p); err != nil {
+			t.Fatalf("set %d failed: %v", id, err)
+		}
+	}
+	if err := pi.set(5, `[1,2]`); err == nil {
+		t.Fatal("expected an error for a non-object payload")
+	}
+
+	tests := []struct {
+		filter  string
+		want    []int64
+		indexed bool
+	}{
+		{`{"eq":{"color":"red"}}`, []int64{1, 3}, true},
+		{`{"in":{"color":["blue","green"]}}`, []int64{2, 4}, true},
+		{`{"eq":{"tags":"new"}}`, []int64{1, 2}, true},
+		{`{"eq":{"brand.name":"acme"}}`, []int64{1, 4}, true},
+		{`{"eq":{"size":12}}`, []int64{2}, true},
+		{`{"eq":{"tags.0":"sale"}}`, []int64{1}, false},
+		{`{"gte":{"size":12}}`, []int64{2, 3}, false},
+		{`{"and":[{"eq":{"color":"red"}},{"gt":{"size":10}}]}`, []int64{3}, true},
+		{`{"or":[{"eq":{"color":"blue"}},{"eq":{"brand.name":"acme"}}]}`, []int64{1, 2, 4}, true},
+		{`{"or":[{"eq":{"color":"blue"}},{"lt":{"size":11}}]}`, []int64{1, 2}, false},
+		{`{"not":{"eq":{"color":"red"}}}`, []int64{2, 4}, false},
+	}
+	for _, tt := range tests {
+		expr, err := filter.Parse(tt.filter)
+		if err != nil {
+			t.Fatalf("Parse(%s) failed: %v", tt.filter, err)
+		}
+		if got := pi.match(expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("match(%s) = %v, want %v", tt.filter, got, tt.want)
+		}
+		if _, ok := pi.candidates(expr); ok != tt.indexed {
+			t.Errorf("candidates(%s) indexed = %v, want %v", tt.filter, ok, tt.indexed)
+		}
+	}
+
+	// Replacing and removing payloads keeps the inverted index in sync
+	if err := pi.set(1, `{"color":"blue"}`); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	pi.remove(3)
+	expr, _ := filter.Parse(`{"eq":{"color":"red"}}`)
+	if got := pi.match(expr); len(got) != 0 {
+		t.Fatalf("expected no red payloads, got %v", got)
+	}
+	expr, _ = filter.Parse(`{"eq":{"color":"blue"}}`)
+	if got := pi.match(expr); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("unexpected blue payloads %v", got)
+	}
+	if _, ok := pi.postings["tags\x00"+mustTerm(t, "sale")]; ok {
+		t.Fatal("stale posting left after replacing a payload")
+	}
+	if raw, ok := pi.get(1); !ok || raw != `{"color":"blue"}` {
+		t.Fatalf("unexpected raw payload %q", raw)
+	}
+}
+
+func TestValidatePayload(t *testing.T) {
+	for payload, valid := range map[string]bool{
+		``:                true,
+		`{"color":"red"}`: true,
+		`{}`:              true,
+		`[1,2]`:           false,
+		`null`:            false,
+		`{"color":`:       false,
+	} {
+		if err := ValidatePayload(payload); (err == nil) != valid {
+			t.Errorf("ValidatePayload(%q) = %v, want valid=%v", payload, err, valid)
+		}
+	}
+}
+
+func mustTerm(t *testing.T, v interface{}) string {
+	term, ok := encodeIndexValue(v)
+	if !ok {
+		t.Fatalf("cannot encode %v", v)
+	}
+	return term
+}
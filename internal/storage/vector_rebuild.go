@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// defaultRebuildSample is how many stored vectors train a rebuilt index when
+// the caller does not choose.
+const defaultRebuildSample = 100000
+
+// rebuildAddBatch is how many vectors are read and added to a new index at a
+// time.
+const rebuildAddBatch = 10000
+
+// vectorChange is an insert (or, with a nil vector, a removal) made while an
+// index rebuild is running, replayed onto the new index before the swap.
+type vectorChange struct {
+	id  int64
+	vec []float32
+}
+
+// RebuildIndex retrains the index from a sample of up to sampleSize stored
+// vectors (0 means defaultRebuildSample) and re-adds every live vector. The
+// work runs in the background; the current index serves searches until it is
+// swapped for the new one.
+func (ve *VectorEngineImpl) RebuildIndex(sampleSize int) error {
+	ve.lock.RLock()
+	indexType, metric := ve.indexType, ve.metric
+	ve.lock.RUnlock()
+	return ve.startRebuild(indexType, metric, sampleSize, func(err error) {
+		if err != nil {
+			log.Printf("Index rebuild failed: %v", err)
+		}
+	})
+}
+
+// MigrateIndex rebuilds the space's vectors into an index of another type
+// and metric in the background, like RebuildIndex, and calls done once the
+// new index is swapped in or the migration failed.
+//
+// Vectors of a cosine space are stored normalized, so migrating away from
+// cosine keeps them normalized. Migrating into cosine is rejected: the
+// stored vectors of other metrics are not normalized, and GET_VECTOR,
+// reranking and diversity read them as they are.
+func (ve *VectorEngineImpl) MigrateIndex(indexType string, metric int, done func(error)) error {
+	ve.lock.RLock()
+	current := ve.metric
+	ve.lock.RUnlock()
+	if metric == MetricCosine && current != MetricCosine {
+		return errors.New("cannot migrate into the cosine metric: stored vectors are not normalized; create a cosine space and import the vectors instead")
+	}
+	return ve.startRebuild(indexType, metric, 0, done)
+}
+
+// IsRebuilding reports whether an index rebuild or migration is running.
+func (ve *VectorEngineImpl) IsRebuilding() bool {
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	return ve.rebuildLog != nil
+}
+
+func (ve *VectorEngineImpl) startRebuild(indexType string, metric int, sampleSize int, done func(error)) error {
+	if sampleSize <= 0 {
+		sampleSize = defaultRebuildSample
+	}
+	// Creating the index up front rejects descriptions FAISS cannot build for
+	// this dimension before any work starts
+	idx, err := newFaissIndex(ve.maxVectorSize, "IDMap,"+indexType, faissMetric(metric))
+	if err != nil {
+		return fmt.Errorf("create FAISS index: %w", err)
+	}
+	ve.lock.Lock()
+	if ve.rebuildLog != nil {
+		ve.lock.Unlock()
+		idx.Delete()
+		return errors.New("an index rebuild is already running")
+	}
+	// Queued writes must be in the data file, which the rebuild reads. They
+	// are written in the same hold of ve.lock that takes the ID snapshot and
+	// starts the rebuild log, so every insert is either in the snapshot or
+	// in the log
+	ve.persistMu.Lock()
+	queued := ve.persistBuf
+	ve.persistBuf = nil
+	ve.persistMu.Unlock()
+	if err := ve.writeRecordsLocked(queued); err != nil {
+		ve.lock.Unlock()
+		idx.Delete()
+		return err
+	}
+	ids := make([]int64, 0, len(ve.fileOffsets))
+	for id := range ve.fileOffsets {
+		ids = append(ids, id)
+	}
+	need := requiredTrainCountFor(indexType)
+	if len(ids)+len(ve.pendingAdd) < need {
+		ve.lock.Unlock()
+		idx.Delete()
+		return fmt.Errorf("index type %s needs at least %d vectors to train, space has %d", indexType, need, len(ids)+len(ve.pendingAdd))
+	}
+	if sampleSize < need {
+		sampleSize = need
+	}
+	ve.rebuildLog = []vectorChange{}
+	ve.lock.Unlock()
+
+	go func() {
+		err := ve.rebuild(idx, indexType, metric, ids, sampleSize)
+		if err != nil {
+			ve.lock.Lock()
+			ve.rebuildLog = nil
+			ve.lock.Unlock()
+		}
+		done(err)
+	}()
+	return nil
+}
+
+// rebuild fills the new index from the given stored IDs, then swaps it in.
+// It owns idx and deletes it on failure.
+func (ve *VectorEngineImpl) rebuild(idx *faissIndex, indexType string, metric int, ids []int64, sampleSize int) error {
+	start := time.Now()
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	if need := requiredTrainCountFor(indexType); need > 0 {
+		n := sampleSize
+		if n > len(ids) {
+			n = len(ids)
+		}
+		_, sample, err := ve.readStoredVectors(ids[:n])
+		if err != nil {
+			idx.Delete()
+			return err
+		}
+		if err := idx.Train(sample); err != nil {
+			idx.Delete()
+			return fmt.Errorf("index training failed: %w", err)
+		}
+	}
+
+	for i := 0; i < len(ids); i += rebuildAddBatch {
+		end := i + rebuildAddBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		found, data, err := ve.readStoredVectors(ids[i:end])
+		if err != nil {
+			idx.Delete()
+			return err
+		}
+		if len(found) == 0 {
+			continue
+		}
+		if err := idx.AddWithIDs(data, found); err != nil {
+			idx.Delete()
+			return fmt.Errorf("add vectors: %w", err)
+		}
+	}
+
+	if err := ve.swapIndex(idx, indexType, metric); err != nil {
+		idx.Delete()
+		return err
+	}
+	if err := ve.checkpoint(); err != nil {
+		log.Printf("Checkpoint after index rebuild failed: %v", err)
+	}
+	log.Printf("Rebuilt %s index with %d vectors in %v", indexType, len(ids), time.Since(start))
+	return nil
+}
+
+// readStoredVectors reads the stored vectors of the IDs that are still live,
+// flattened.
+func (ve *VectorEngineImpl) readStoredVectors(ids []int64) ([]int64, []float32, error) {
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	found := make([]int64, 0, len(ids))
+	data := make([]float32, 0, len(ids)*ve.maxVectorSize)
+	for _, id := range ids {
+		offset, ok := ve.fileOffsets[id]
+		if !ok {
+			continue // removed since the rebuild started
+		}
+		vec, err := ve.readVectorAt(offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		found = append(found, id)
+		data = append(data, vec...)
+	}
+	return found, data, nil
+}
+
+// swapIndex replays the changes made during the rebuild onto the new index
+// and makes it the engine's index.
+func (ve *VectorEngineImpl) swapIndex(idx *faissIndex, indexType string, metric int) error {
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+
+	select {
+	case <-ve.quitChan:
+		return errors.New("engine closed during index rebuild")
+	default:
+	}
+
+	// Latest change per ID; vectors still waiting for the old index to be
+	// trained go into the new, trained one
+	latest := make(map[int64][]float32)
+	var order []int64
+	note := func(id int64, vec []float32) {
+		if _, seen := latest[id]; !seen {
+			order = append(order, id)
+		}
+		latest[id] = vec
+	}
+	for _, c := range ve.rebuildLog {
+		note(c.id, c.vec)
+	}
+	for id, vec := range ve.pendingAdd {
+		note(id, vec)
+	}
+
+	if len(order) > 0 {
+		if _, err := idx.RemoveIDs(order); err != nil {
+			log.Printf("Warning: RemoveIDs failed for index type %s: %v", indexType, err)
+		}
+		var ids []int64
+		var data []float32
+		for _, id := range order {
+			if vec := latest[id]; vec != nil {
+				ids = append(ids, id)
+				data = append(data, vec...)
+			}
+		}
+		if len(ids) > 0 {
+			if err := idx.AddWithIDs(data, ids); err != nil {
+				return fmt.Errorf("replay changes: %w", err)
+			}
+		}
+	}
+	for id, vec := range ve.pendingAdd {
+		ve.enqueuePersist(id, vec)
+	}
+
+	old := ve.idMapIndex
+	ve.idMapIndex, ve.baseIndex = idx, idx
+	ve.indexType, ve.metric = indexType, metric
+	ve.pendingAdd = make(map[int64][]float32)
+	ve.trainPool = nil
+	ve.rebuildLog = nil
+	old.Delete()
+
+	// The new index starts from FAISS defaults
+	if err := ve.applySearchParams(ve.searchDefaults); err != nil {
+		log.Printf("Applying search parameters to the rebuilt index failed: %v", err)
+	}
+	return nil
+}
+
+// noteRebuildChange records a change for a running rebuild; the caller must
+// hold ve.lock.
+func (ve *VectorEngineImpl) noteRebuildChange(id int64, vec []float32) {
+	if ve.rebuildLog != nil {
+		ve.rebuildLog = append(ve.rebuildLog, vectorChange{id: id, vec: vec})
+	}
+}
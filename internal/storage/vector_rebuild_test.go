@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestRebuildKeepsConcurrentInserts(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/vector_rebuild_data.db"
+	indexPath := "testdata/vector_rebuild_index.faiss"
+	walPath := "testdata/vector_rebuild_wal.db"
+	for _, p := range []string{dataPath, indexPath, walPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "Flat", faiss.MetricL2, true)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	// Still queued for the data file when the rebuild starts
+	for id := int64(0); id < 100; id++ {
+		if err := ve.InsertVector(id, []float32{float32(id), 0}); err != nil {
+			t.Fatalf("InsertVector failed: %v", err)
+		}
+	}
+
+	done := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for id := int64(100); id < 300; id++ {
+			if err := ve.InsertVector(id, []float32{float32(id), 0}); err != nil {
+				t.Errorf("InsertVector during rebuild failed: %v", err)
+			}
+		}
+	}()
+	if err := ve.MigrateIndex("Flat", faiss.MetricL2, func(err error) { done <- err }); err != nil {
+		t.Fatalf("MigrateIndex failed: %v", err)
+	}
+	wg.Wait()
+	if err := <-done; err != nil {
+		t.Fatalf("rebuild failed: %v", err)
+	}
+
+	for id := int64(0); id < 300; id++ {
+		ids, _, err := ve.SearchTopK([]float32{float32(id), 0}, 1)
+		if err != nil || len(ids) != 1 || ids[0] != id {
+			t.Fatalf("expected ID %d to be searchable after the rebuild, got %v (%v)", id, ids, err)
+		}
+	}
+}
+
+func TestMigrateIntoCosineRejected(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/vector_migrate_data.db"
+	indexPath := "testdata/vector_migrate_index.faiss"
+	walPath := "testdata/vector_migrate_wal.db"
+	for _, p := range []string{dataPath, indexPath, walPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "Flat", faiss.MetricL2, false)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+	if err := ve.InsertVector(1, []float32{3, 4}); err != nil {
+		t.Fatalf("InsertVector failed: %v", err)
+	}
+
+	if err := ve.MigrateIndex("Flat", MetricCosine, func(error) {}); err == nil {
+		t.Fatal("expected migrating an L2 space into cosine to be rejected")
+	}
+	if ve.IsRebuilding() {
+		t.Fatal("expected no rebuild to start")
+	}
+}
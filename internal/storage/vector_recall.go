@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxRecallQueries bounds the query set of a recall evaluation, whose ground
+// truth scans every stored vector once per query.
+const maxRecallQueries = 10000
+
+// RecallResult is the recall and latency of one search parameter setting.
+type RecallResult struct {
+	// Params are the search parameters; empty means the space defaults.
+	Params map[string]float64 `json:"params"`
+	Recall float64            `json:"recall"`
+	MeanMs float64            `json:"mean_ms"`
+	P50Ms  float64            `json:"p50_ms"`
+	P95Ms  float64            `json:"p95_ms"`
+	P99Ms  float64            `json:"p99_ms"`
+	QPS    float64            `json:"qps"`
+}
+
+// RecallReport is the outcome of EvaluateRecall.
+type RecallReport struct {
+	IndexType     string         `json:"index_type"`
+	K             int            `json:"k"`
+	Queries       int            `json:"queries"`
+	Vectors       int            `json:"vectors"`
+	GroundTruthMs float64        `json:"ground_truth_ms"`
+	Results       []RecallResult `json:"results"`
+}
+
+// RecallSettings returns the search parameter settings evaluated by default
+// for an index type: a sweep of nprobe for IVF indexes and of efSearch for
+// HNSW indexes, and the space defaults otherwise.
+func RecallSettings(indexType string) []map[string]float64 {
+	var name string
+	var values []float64
+	switch {
+	case strings.Contains(indexType, "IVF"):
+		name, values = "nprobe", []float64{1, 4, 16, 64}
+	case strings.Contains(indexType, "HNSW"):
+		name, values = "efSearch", []float64{16, 32, 64, 128, 256}
+	default:
+		return []map[string]float64{{}}
+	}
+	settings := make([]map[string]float64, len(values))
+	for i, v := range values {
+		settings[i] = map[string]float64{name: v}
+	}
+	return settings
+}
+
+// SampleQueries returns up to n stored vectors picked at random, for use as
+// a recall query set.
+func (ve *VectorEngineImpl) SampleQueries(n int) ([][]float32, error) {
+	if n <= 0 {
+		return nil, errors.New("sample size must be positive")
+	}
+	ve.flushData(true)
+	ve.lock.RLock()
+	ids := make([]int64, 0, len(ve.fileOffsets))
+	for id := range ve.fileOffsets {
+		ids = append(ids, id)
+	}
+	ve.lock.RUnlock()
+
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	if n < len(ids) {
+		ids = ids[:n]
+	}
+	_, data, err := ve.readStoredVectors(ids)
+	if err != nil {
+		return nil, err
+	}
+	queries := make([][]float32, 0, len(data)/ve.maxVectorSize)
+	for i := 0; i+ve.maxVectorSize <= len(data); i += ve.maxVectorSize {
+		queries = append(queries, data[i:i+ve.maxVectorSize])
+	}
+	return queries, nil
+}
+
+// EvaluateRecall measures how well the space's index finds the true k
+// nearest neighbours of the queries at each setting of search parameters (none
+// means RecallSettings of the index type). The ground truth is computed
+// exactly from the stored vectors; recall@k is the average share of the true
+// top k found, and latency percentiles are per query.
+func (ve *VectorEngineImpl) EvaluateRecall(queries [][]float32, k int, settings []map[string]float64) (*RecallReport, error) {
+	if k <= 0 {
+		return nil, errors.New("k must be positive")
+	}
+	if len(queries) == 0 || len(queries) > maxRecallQueries {
+		return nil, fmt.Errorf("recall evaluation needs between 1 and %d queries", maxRecallQueries)
+	}
+	ve.lock.RLock()
+	indexType, metric := ve.indexType, ve.metric
+	ve.lock.RUnlock()
+	distance, ok := exactDistances[metric]
+	if !ok {
+		return nil, fmt.Errorf("recall evaluation is not supported for metric %d", metric)
+	}
+	if len(settings) == 0 {
+		settings = RecallSettings(indexType)
+	}
+	prepared := make([][]float32, len(queries))
+	for i, q := range queries {
+		if len(q) != ve.maxVectorSize {
+			return nil, fmt.Errorf("query %d has dimension %d, expected %d", i, len(q), ve.maxVectorSize)
+		}
+		pq, err := ve.prepareVector(q)
+		if err != nil {
+			return nil, fmt.Errorf("query %d: %w", i, err)
+		}
+		prepared[i] = pq
+	}
+	for _, params := range settings {
+		if err := ValidateSearchParams(params); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ground truth from the data file, read in batches
+	start := time.Now()
+	ve.flushData(true)
+	ve.lock.RLock()
+	ids := make([]int64, 0, len(ve.fileOffsets))
+	for id := range ve.fileOffsets {
+		ids = append(ids, id)
+	}
+	ve.lock.RUnlock()
+	truth := newTopKAccumulator(len(prepared), k, ve.isSimilarity())
+	for i := 0; i < len(ids); i += rebuildAddBatch {
+		end := i + rebuildAddBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		found, data, err := ve.readStoredVectors(ids[i:end])
+		if err != nil {
+			return nil, err
+		}
+		for j, id := range found {
+			vec := data[j*ve.maxVectorSize : (j+1)*ve.maxVectorSize]
+			for qi, q := range prepared {
+				truth.add(qi, id, distance(q, vec))
+			}
+		}
+	}
+	report := &RecallReport{
+		IndexType:     indexType,
+		K:             k,
+		Queries:       len(queries),
+		Vectors:       len(ids),
+		GroundTruthMs: durationMs(time.Since(start)),
+	}
+
+	for _, params := range settings {
+		latencies := make([]time.Duration, len(queries))
+		var recall float64
+		begin := time.Now()
+		for qi, q := range queries {
+			t := time.Now()
+			found, _, err := ve.SearchTopKWithOptions(q, k, SearchOptions{Params: params})
+			latencies[qi] = time.Since(t)
+			if err != nil {
+				return nil, err
+			}
+			recall += recallAt(found, truth.ids[qi])
+		}
+		total := time.Since(begin)
+		if params == nil {
+			params = map[string]float64{}
+		}
+		res := summarizeLatencies(latencies)
+		res.Params = params
+		res.Recall = recall / float64(len(queries))
+		res.QPS = float64(len(queries)) / total.Seconds()
+		report.Results = append(report.Results, res)
+	}
+	return report, nil
+}
+
+// topKAccumulator keeps the k best (id, distance) pairs of every query.
+type topKAccumulator struct {
+	k          int
+	similarity bool
+	ids        [][]int64
+	dists      [][]float64
+}
+
+func newTopKAccumulator(queries, k int, similarity bool) *topKAccumulator {
+	return &topKAccumulator{k: k, similarity: similarity, ids: make([][]int64, queries), dists: make([][]float64, queries)}
+}
+
+func (t *topKAccumulator) better(a, b float64) bool {
+	if t.similarity {
+		return a > b
+	}
+	return a < b
+}
+
+// add offers a candidate to a query's list, which stays ordered best first.
+func (t *topKAccumulator) add(q int, id int64, d float64) {
+	ids, dists := t.ids[q], t.dists[q]
+	if len(ids) == t.k && !t.better(d, dists[len(dists)-1]) {
+		return
+	}
+	pos := sort.Search(len(dists), func(i int) bool { return t.better(d, dists[i]) })
+	if len(ids) < t.k {
+		ids = append(ids, 0)
+		dists = append(dists, 0)
+	}
+	copy(ids[pos+1:], ids[pos:])
+	copy(dists[pos+1:], dists[pos:])
+	ids[pos], dists[pos] = id, d
+	t.ids[q], t.dists[q] = ids, dists
+}
+
+// recallAt returns the share of the true neighbours found; with no true
+// neighbours (an empty space) recall is 1.
+func recallAt(found, truth []int64) float64 {
+	if len(truth) == 0 {
+		return 1
+	}
+	want := make(map[int64]struct{}, len(truth))
+	for _, id := range truth {
+		want[id] = struct{}{}
+	}
+	hits := 0
+	for _, id := range found {
+		if _, ok := want[id]; ok {
+			hits++
+			delete(want, id)
+		}
+	}
+	return float64(hits) / float64(len(truth))
+}
+
+// summarizeLatencies returns the mean and nearest-rank percentiles.
+func summarizeLatencies(latencies []time.Duration) RecallResult {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) float64 {
+		rank := int(p*float64(len(sorted)) + 0.999999)
+		if rank < 1 {
+			rank = 1
+		}
+		return durationMs(sorted[rank-1])
+	}
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+	return RecallResult{
+		MeanMs: durationMs(sum) / float64(len(sorted)),
+		P50Ms:  percentile(0.50),
+		P95Ms:  percentile(0.95),
+		P99Ms:  percentile(0.99),
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopKAccumulator(t *testing.T) {
+	acc := newTopKAccumulator(2, 3, false)
+	dists := []float64{5, 1, 4, 2, 3, 0.5}
+	for i, d := range dists {
+		acc.add(0, int64(i), d)
+		acc.add(1, int64(i), d)
+	}
+	if want := []int64{5, 1, 3}; !reflect.DeepEqual(acc.ids[0], want) {
+		t.Errorf("distance top-k: got %v, want %v", acc.ids[0], want)
+	}
+
+	sim := newTopKAccumulator(1, 2, true)
+	for i, d := range dists {
+		sim.add(0, int64(i), d)
+	}
+	if want := []int64{0, 2}; !reflect.DeepEqual(sim.ids[0], want) {
+		t.Errorf("similarity top-k: got %v, want %v", sim.ids[0], want)
+	}
+}
+
+func TestRecallAt(t *testing.T) {
+	tests := []struct {
+		found, truth []int64
+		want         float64
+	}{
+		{[]int64{1, 2, 3}, []int64{1, 2, 3}, 1},
+		{[]int64{1, 9, 3}, []int64{3, 2, 1}, 2.0 / 3.0},
+		{[]int64{1, 1}, []int64{1, 2}, 0.5},
+		{nil, []int64{1}, 0},
+		{nil, nil, 1},
+	}
+	for _, tt := range tests {
+		if got := recallAt(tt.found, tt.truth); got != tt.want {
+			t.Errorf("recallAt(%v, %v) = %v, want %v", tt.found, tt.truth, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeLatencies(t *testing.T) {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[len(latencies)-1-i] = time.Duration(i+1) * time.Millisecond
+	}
+	res := summarizeLatencies(latencies)
+	if res.P50Ms != 50 || res.P95Ms != 95 || res.P99Ms != 99 || res.MeanMs != 50.5 {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestRecallSettings(t *testing.T) {
+	if s := RecallSettings("IVF32,Flat"); len(s) != 4 || s[0]["nprobe"] != 1 {
+		t.Errorf("IVF settings: %v", s)
+	}
+	if s := RecallSettings("HNSW32"); len(s) != 5 || s[4]["efSearch"] != 256 {
+		t.Errorf("HNSW settings: %v", s)
+	}
+	if s := RecallSettings("Flat"); len(s) != 1 || len(s[0]) != 0 {
+		t.Errorf("Flat settings: %v", s)
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SearchByID returns the k nearest neighbours of stored vectors ("more like
+// this"). The query is the average of the positive vectors; negative vectors
+// push it away from their average:
+//
+//	query = avg(positive) + (avg(positive) - avg(negative))
+//
+// The given IDs themselves are excluded from the results.
+func (ve *VectorEngineImpl) SearchByID(positive, negative []int64, k int, opts SearchOptions) ([]int64, []float32, error) {
+	if len(positive) == 0 {
+		return nil, nil, errors.New("at least one positive ID is required")
+	}
+	query, err := ve.recommendQuery(positive, negative)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exclude := make(map[int64]struct{}, len(positive)+len(negative))
+	for _, id := range positive {
+		exclude[id] = struct{}{}
+	}
+	for _, id := range negative {
+		exclude[id] = struct{}{}
+	}
+	ids, dists, err := ve.SearchTopKWithOptions(query, k+len(exclude), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	outIDs := make([]int64, 0, k)
+	outD := make([]float32, 0, k)
+	for i, id := range ids {
+		if _, skip := exclude[id]; skip {
+			continue
+		}
+		outIDs = append(outIDs, id)
+		outD = append(outD, dists[i])
+		if len(outIDs) == k {
+			break
+		}
+	}
+	return outIDs, outD, nil
+}
+
+// recommendQuery builds the SearchByID query vector from stored vectors.
+func (ve *VectorEngineImpl) recommendQuery(positive, negative []int64) ([]float32, error) {
+	pos, err := ve.averageStored(positive)
+	if err != nil {
+		return nil, err
+	}
+	if len(negative) == 0 {
+		return pos, nil
+	}
+	neg, err := ve.averageStored(negative)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pos {
+		pos[i] += pos[i] - neg[i]
+	}
+	return pos, nil
+}
+
+func (ve *VectorEngineImpl) averageStored(ids []int64) ([]float32, error) {
+	sum := make([]float64, ve.maxVectorSize)
+	for _, id := range ids {
+		vec, err := ve.GetVectorByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("vector %d: %w", id, err)
+		}
+		for i, v := range vec {
+			sum[i] += float64(v)
+		}
+	}
+	avg := make([]float32, len(sum))
+	for i, s := range sum {
+		avg[i] = float32(s / float64(len(ids)))
+	}
+	return avg, nil
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+// maxRerankFactor bounds how many candidates a reranked search fetches per
+// requested result.
+const maxRerankFactor = 100
+
+// mmrCandidateFactor is how many candidates per requested result a diverse
+// search picks from unless Rerank asks for more.
+const mmrCandidateFactor = 4
+
+// searchCandidates is how many candidates a search fetches for k results.
+func searchCandidates(k int, opts SearchOptions) int {
+	factor := opts.Rerank
+	if opts.Diversity != nil && factor < mmrCandidateFactor {
+		factor = mmrCandidateFactor
+	}
+	if factor <= 1 {
+		return k
+	}
+	return k * factor
+}
+
+// validateRefine checks the rerank and diversity options and that the
+// space's metric can be computed exactly. Rerank also needs the stored
+// vectors to be exact, so it is refused for lossy encodings.
+func (ve *VectorEngineImpl) validateRefine(opts SearchOptions) error {
+	if opts.Rerank == 0 && opts.Diversity == nil {
+		return nil
+	}
+	if opts.Rerank < 0 || opts.Rerank > maxRerankFactor {
+		return fmt.Errorf("rerank factor must be between 1 and %d", maxRerankFactor)
+	}
+	if d := opts.Diversity; d != nil && (*d < 0 || *d > 1) {
+		return errors.New("diversity lambda must be between 0 and 1")
+	}
+	if _, ok := exactDistances[ve.metric]; !ok {
+		return fmt.Errorf("rerank and diversity are not supported for metric %d", ve.metric)
+	}
+	if opts.Rerank > 0 && ve.encoding != encodingFloat32 {
+		return fmt.Errorf("rerank is not supported for the lossy %s vector encoding", encodingNames[ve.encoding])
+	}
+	return nil
+}
+
+// refineLocked turns search candidates into the k results: by maximal
+// marginal relevance with Diversity, by exact distance with Rerank, and
+// unchanged otherwise. The caller must hold ve.lock.
+func (ve *VectorEngineImpl) refineLocked(query []float32, ids []int64, dists []float32, k int, opts SearchOptions) ([]int64, []float32, error) {
+	switch {
+	case opts.Diversity != nil:
+		return ve.mmrLocked(query, ids, k, *opts.Diversity)
+	case opts.Rerank > 0:
+		return ve.rerankLocked(query, ids, k)
+	}
+	if len(ids) > k {
+		ids, dists = ids[:k], dists[:k]
+	}
+	return ids, dists, nil
+}
+
+// rerankLocked recomputes the distances of the candidates from their stored
+// full-precision vectors and returns the best k; the caller must hold ve.lock.
+// query must already be prepared for the space's metric.
+func (ve *VectorEngineImpl) rerankLocked(query []float32, ids []int64, k int) ([]int64, []float32, error) {
+	distance := exactDistances[ve.metric]
+	type scored struct {
+		id   int64
+		dist float32
+	}
+	results := make([]scored, 0, len(ids))
+	for _, id := range ids {
+		offset, ok := ve.fileOffsets[id]
+		if !ok {
+			continue
+		}
+		vec, err := ve.readVectorAt(offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, scored{id, float32(distance(query, vec))})
+	}
+	similarity := ve.isSimilarity()
+	sort.SliceStable(results, func(i, j int) bool {
+		if similarity {
+			return results[i].dist > results[j].dist
+		}
+		return results[i].dist < results[j].dist
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	outIDs := make([]int64, len(results))
+	outD := make([]float32, len(results))
+	for i, r := range results {
+		outIDs[i], outD[i] = r.id, r.dist
+	}
+	return outIDs, outD, nil
+}
+
+// exactDistances computes each metric the way FAISS defines it: squared L2,
+// and inner product for cosine spaces, whose vectors are stored normalized.
+var exactDistances = map[int]func(a, b []float32) float64{
+	faiss.MetricL2:            squaredL2,
+	faiss.MetricInnerProduct:  innerProduct,
+	MetricCosine:              innerProduct,
+	faiss.MetricL1:            l1Distance,
+	faiss.MetricLinf:          linfDistance,
+	faiss.MetricCanberra:      canberraDistance,
+	faiss.MetricBrayCurtis:    brayCurtisDistance,
+	faiss.MetricJensenShannon: jensenShannonDistance,
+}
+
+func squaredL2(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return sum
+}
+
+func innerProduct(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func l1Distance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += math.Abs(float64(a[i]) - float64(b[i]))
+	}
+	return sum
+}
+
+func linfDistance(a, b []float32) float64 {
+	var max float64
+	for i := range a {
+		if d := math.Abs(float64(a[i]) - float64(b[i])); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+func canberraDistance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		if den := math.Abs(x) + math.Abs(y); den > 0 {
+			sum += math.Abs(x-y) / den
+		}
+	}
+	return sum
+}
+
+func brayCurtisDistance(a, b []float32) float64 {
+	var num, den float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		num += math.Abs(x - y)
+		den += math.Abs(x + y)
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+func jensenShannonDistance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		m := 0.5 * (x + y)
+		if x > 0 {
+			sum += x * math.Log(x/m)
+		}
+		if y > 0 {
+			sum += y * math.Log(y/m)
+		}
+	}
+	return 0.5 * sum
+}
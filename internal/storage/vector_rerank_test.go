@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestExactDistances(t *testing.T) {
+	a := []float32{1, 0, 2}
+	b := []float32{0, 1, 2}
+	tests := []struct {
+		metric int
+		want   float64
+	}{
+		{faiss.MetricL2, 2},
+		{faiss.MetricInnerProduct, 4},
+		{MetricCosine, 4},
+		{faiss.MetricL1, 2},
+		{faiss.MetricLinf, 1},
+		{faiss.MetricCanberra, 2},
+		{faiss.MetricBrayCurtis, 2.0 / 6.0},
+		{faiss.MetricJensenShannon, math.Log(2)},
+	}
+	for _, tt := range tests {
+		got := exactDistances[tt.metric](a, b)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("metric %d: got %v, want %v", tt.metric, got, tt.want)
+		}
+	}
+	if _, ok := exactDistances[faiss.MetricLp]; ok {
+		t.Error("Lp needs a metric argument and should not be reranked")
+	}
+}
+
+func TestSearchCandidates(t *testing.T) {
+	lambda := 0.5
+	tests := []struct {
+		opts SearchOptions
+		want int
+	}{
+		{SearchOptions{}, 10},
+		{SearchOptions{Rerank: 1}, 10},
+		{SearchOptions{Rerank: 4}, 40},
+		{SearchOptions{Diversity: &lambda}, 10 * mmrCandidateFactor},
+		{SearchOptions{Diversity: &lambda, Rerank: 8}, 80},
+	}
+	for _, tt := range tests {
+		if n := searchCandidates(10, tt.opts); n != tt.want {
+			t.Errorf("%+v: expected %d candidates, got %d", tt.opts, tt.want, n)
+		}
+	}
+}
+
+func TestValidateRefineEncoding(t *testing.T) {
+	lambda := 0.5
+	for _, enc := range []string{"float16", "int8"} {
+		ve := openTestVectorEngine(t, t.TempDir(), 2, faiss.MetricL2, enc)
+		if err := ve.validateRefine(SearchOptions{Rerank: 4}); err == nil {
+			t.Errorf("encoding %s: expected rerank to be rejected", enc)
+		}
+		if err := ve.validateRefine(SearchOptions{Diversity: &lambda}); err != nil {
+			t.Errorf("encoding %s: diversity failed: %v", enc, err)
+		}
+	}
+	ve := openTestVectorEngine(t, t.TempDir(), 2, faiss.MetricL2, "")
+	if err := ve.validateRefine(SearchOptions{Rerank: 4}); err != nil {
+		t.Errorf("float32: rerank failed: %v", err)
+	}
+}
+
+func TestMMRSelect(t *testing.T) {
+	// Two near-duplicates close to the query and one distinct vector
+	query := []float32{1, 0.2}
+	vecs := [][]float32{{1, 0}, {0.99, 0.01}, {0.6, 0.8}}
+	sim := func(a, b []float32) float64 { return innerProduct(a, b) }
+
+	if got := mmrSelect(query, vecs, 2, 1, sim); got[0] != 0 || got[1] != 1 {
+		t.Errorf("lambda 1: expected relevance order [0 1], got %v", got)
+	}
+	if got := mmrSelect(query, vecs, 2, 0.5, sim); got[0] != 0 || got[1] != 2 {
+		t.Errorf("lambda 0.5: expected the distinct vector second, got %v", got)
+	}
+	if got := mmrSelect(query, vecs, 5, 0.5, sim); len(got) != 3 {
+		t.Errorf("Expected all 3 candidates when k exceeds them, got %v", got)
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"os"
+	"time"
+)
+
+// VectorStats describes the state of a vector space's engine.
+type VectorStats struct {
+	// Live vectors, including those waiting for training, and vectors
+	// searchable in the index
+	Vectors int64 `json:"vectors"`
+	Indexed int64 `json:"indexed"`
+
+	// Training: an untrained index holds vectors in PendingAdd until
+	// TrainPool reaches RequiredTrainCount
+	Trained            bool `json:"trained"`
+	RequiredTrainCount int  `json:"required_train_count"`
+	TrainPool          int  `json:"train_pool"`
+	PendingAdd         int  `json:"pending_add"`
+	Rebuilding         bool `json:"rebuilding"`
+
+	// Data file records, of which DeadRecords are overwritten vectors and
+	// tombstones; TombstoneRatio is their share, compacted past 0.5
+	DataRecords    int64   `json:"data_records"`
+	DeadRecords    int64   `json:"dead_records"`
+	TombstoneRatio float64 `json:"tombstone_ratio"`
+
+	Payloads       int    `json:"payloads"`
+	Encoding       string `json:"encoding"`
+	DataFileBytes  int64  `json:"data_file_bytes"`
+	IndexFileBytes int64  `json:"index_file_bytes"`
+
+	// Zero until the index is first written
+	LastCheckpoint time.Time `json:"last_checkpoint"`
+}
+
+var encodingNames = map[byte]string{
+	encodingFloat32: "float32",
+	encodingFloat16: "float16",
+	encodingInt8:    "int8",
+}
+
+// Stats returns the counts, training status and file sizes of the space.
+func (ve *VectorEngineImpl) Stats() (*VectorStats, error) {
+	ve.flushData(true)
+	ve.lock.RLock()
+	defer ve.lock.RUnlock()
+	nTrain := ve.requiredTrainCount()
+	stats := &VectorStats{
+		Vectors:            int64(len(ve.fileOffsets)),
+		Indexed:            ve.idMapIndex.Ntotal(),
+		Trained:            nTrain == 0 || ve.baseIndex.IsTrained(),
+		RequiredTrainCount: nTrain,
+		TrainPool:          len(ve.trainPool),
+		PendingAdd:         len(ve.pendingAdd),
+		Rebuilding:         ve.rebuildLog != nil,
+		DataRecords:        ve.dataRecords,
+		DeadRecords:        ve.dataRecords - int64(len(ve.fileOffsets)),
+		Payloads:           len(ve.payloads.raw),
+		Encoding:           encodingNames[ve.encoding],
+		LastCheckpoint:     ve.lastCheckpoint,
+	}
+	for id := range ve.pendingAdd {
+		if _, ok := ve.fileOffsets[id]; !ok {
+			stats.Vectors++
+		}
+	}
+	if stats.DataRecords > 0 {
+		stats.TombstoneRatio = float64(stats.DeadRecords) / float64(stats.DataRecords)
+	}
+	if fi, err := ve.dataFile.Stat(); err == nil {
+		stats.DataFileBytes = fi.Size()
+	} else {
+		return nil, err
+	}
+	if fi, err := os.Stat(ve.indexFile); err == nil {
+		stats.IndexFileBytes = fi.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return stats, nil
+}
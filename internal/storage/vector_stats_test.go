@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestVectorStats(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/vector_stats_data.db"
+	indexPath := "testdata/vector_stats_index.faiss"
+	walPath := "testdata/vector_stats_wal.db"
+	for _, p := range []string{dataPath, indexPath, walPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "IVF4,Flat", faiss.MetricL2, false)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	if err := ve.InsertVectors([]int64{1, 2}, [][]float32{{1, 1}, {2, 2}}); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	stats, err := ve.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Trained || stats.Vectors != 2 || stats.Indexed != 0 || stats.PendingAdd != 2 || stats.RequiredTrainCount != 4 {
+		t.Fatalf("unexpected stats before training: %+v", stats)
+	}
+
+	if err := ve.InsertVectors([]int64{3, 4}, [][]float32{{3, 3}, {4, 4}}); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	if err := ve.RemoveVector(4); err != nil {
+		t.Fatalf("RemoveVector failed: %v", err)
+	}
+	stats, err = ve.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if !stats.Trained || stats.Vectors != 3 || stats.Indexed != 3 || stats.PendingAdd != 0 || stats.TrainPool != 0 {
+		t.Fatalf("unexpected stats after training: %+v", stats)
+	}
+	if stats.DataRecords != 5 || stats.DeadRecords != 2 || stats.TombstoneRatio != 0.4 {
+		t.Fatalf("expected 2 of 5 records dead, got %+v", stats)
+	}
+	if stats.DataFileBytes == 0 || stats.Encoding != "float32" {
+		t.Fatalf("unexpected data file stats: %+v", stats)
+	}
+}
@@ -4,47 +4,81 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/DataIntelligenceCrew/go-faiss"
+	"github.com/shibudb.org/shibudb-server/internal/filter"
+	"github.com/shibudb.org/shibudb-server/internal/textindex"
 	"github.com/shibudb.org/shibudb-server/internal/wal"
 )
 
 type VectorEngineImpl struct {
 	dataFile      *os.File
+	dataPath      string
+	dataRecords   int64 // records in the data file, live or dead
 	indexFile     string
 	wal           *wal.WAL
 	maxVectorSize int
 
 	// FAISS indices (ID-mapped)
-	baseIndex  faiss.Index
-	idMapIndex faiss.Index
+	baseIndex  *faissIndex
+	idMapIndex *faissIndex
 
 	indexType string
 	metric    int
 
+	// Search parameters applied when a query sets none (e.g. nprobe)
+	searchDefaults map[string]float64
+
 	// For training-aware ingestion
 	trainPool  [][]float32         // vectors for training only
 	pendingAdd map[int64][]float32 // id -> vector waiting to be AddWithIDs after training
 
 	// For fast GetVectorByID from append-only data file
 	fileOffsets map[int64]int64 // id -> byte offset in data file
+	encoding    byte            // how vectors are stored in the data file
+
+	// Per-vector JSON payloads, persisted in an attribute log
+	attrFile *os.File
+	payloads *payloadIndex
+
+	// String keys of vectors not addressed by a numeric ID
+	keys *vectorKeys
+
+	// Parent document of each vector that has one
+	parents map[int64]string
+
+	// Optional text per vector for hybrid search
+	text      *textindex.Index
+	textFile  string
+	textDirty int32
+
+	// Changes made while an index rebuild runs; nil when none is running
+	rebuildLog []vectorChange
 
 	// Lifecycle / checkpointing
-	quitChan     chan struct{}
-	flushRunning int32
-	closeOnce    sync.Once
+	quitChan       chan struct{}
+	flushRunning   int32
+	closeOnce      sync.Once
+	lastCheckpoint time.Time
 
 	lock sync.RWMutex
 
+	// Held shared by writers from their WAL write until the change is
+	// applied, and exclusively by checkpoint, which clears the WAL once the
+	// applied state is on disk
+	walMu sync.RWMutex
+
+	// Serializes data file compactions
+	compactMu sync.Mutex
+
 	// --- batching for data file ---
 	persistMu  sync.Mutex
 	persistBuf []struct {
@@ -58,27 +92,77 @@ type VectorEngineImpl struct {
 
 var _ VectorEngine = (*VectorEngineImpl)(nil)
 
+// SearchOptions refine a vector search.
+type SearchOptions struct {
+	// Filter restricts the search to vectors whose payload matches.
+	Filter filter.Expr
+	// Params overrides the space's search parameters for this search only,
+	// e.g. {"nprobe": 16} for IVF or {"efSearch": 128} for HNSW indexes.
+	// nprobe alone runs alongside other searches; the rest briefly change
+	// the index settings and run exclusively.
+	Params map[string]float64
+	// Rerank, when set, fetches Rerank times k candidates and orders them by
+	// exact distances computed from the stored full-precision vectors.
+	Rerank int
+	// Diversity, when set, picks the k results from an oversampled candidate
+	// set by maximal marginal relevance with this lambda: 1 ranks by
+	// relevance only, 0 by diversity only.
+	Diversity *float64
+}
+
 // NewVectorEngine builds/loads the ID-mapped FAISS index and opens data + WAL files.
 func NewVectorEngine(dataPath, indexPath, walPath string, maxVectorSize int, indexDesc string, metric int, enableWAL bool) (*VectorEngineImpl, error) {
+	return NewVectorEngineWithEncoding(dataPath, indexPath, walPath, maxVectorSize, indexDesc, metric, enableWAL, "")
+}
+
+// NewVectorEngineWithEncoding is NewVectorEngine with the encoding of a new
+// data file: float32 (the default), float16 or int8. An existing data file
+// keeps the encoding it was created with.
+func NewVectorEngineWithEncoding(dataPath, indexPath, walPath string, maxVectorSize int, indexDesc string, metric int, enableWAL bool, encoding string) (*VectorEngineImpl, error) {
+	if err := ValidateVectorEncoding(encoding); err != nil {
+		return nil, err
+	}
 	df, err := os.OpenFile(dataPath, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
 		return nil, fmt.Errorf("open data file: %w", err)
 	}
 
-	// Create (or read) the ID-mapped index
-	var idmap faiss.Index
-	if _, err := os.Stat(indexPath); err == nil {
-		idmap, err = faiss.ReadIndex(indexPath, 0)
+	// Create (or read) the ID-mapped index; the index file was written by
+	// the last checkpoint
+	var idmap *faissIndex
+	var lastCheckpoint time.Time
+	if fi, err := os.Stat(indexPath); err == nil {
+		lastCheckpoint = fi.ModTime()
+		idmap, err = readFaissIndex(indexPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read FAISS index from file: %w", err)
 		}
 	} else {
-		idmap, err = faiss.IndexFactory(maxVectorSize, "IDMap,"+indexDesc, metric)
+		idmap, err = newFaissIndex(maxVectorSize, "IDMap,"+indexDesc, faissMetric(metric))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create FAISS index: %w", err)
 		}
 	}
 
+	// Load (or create) the text index used by hybrid search
+	textPath := strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + "_text.dat"
+	var text *textindex.Index
+	if _, err := os.Stat(textPath); err == nil {
+		text, err = textindex.Load(textPath)
+		if err != nil {
+			return nil, fmt.Errorf("load text index: %w", err)
+		}
+	} else {
+		analyzer, _ := textindex.NewAnalyzer(textindex.DefaultAnalyzer)
+		text = textindex.New(analyzer)
+	}
+
+	attrPath := strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + "_attrs.db"
+	af, err := os.OpenFile(attrPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("open attribute file: %w", err)
+	}
+
 	var w *wal.WAL
 	if enableWAL {
 		w, err = wal.OpenWAL(walPath)
@@ -89,6 +173,7 @@ func NewVectorEngine(dataPath, indexPath, walPath string, maxVectorSize int, ind
 
 	e := &VectorEngineImpl{
 		dataFile:      df,
+		dataPath:      dataPath,
 		indexFile:     indexPath,
 		wal:           w,
 		maxVectorSize: maxVectorSize,
@@ -99,8 +184,17 @@ func NewVectorEngine(dataPath, indexPath, walPath string, maxVectorSize int, ind
 		trainPool:     make([][]float32, 0, 1024),
 		pendingAdd:    make(map[int64][]float32),
 		fileOffsets:   make(map[int64]int64),
+		encoding:      vectorEncodings[encoding],
+		attrFile:      af,
+		payloads:      newPayloadIndex(),
+		keys:          newVectorKeys(),
+		parents:       make(map[int64]string),
+		text:          text,
+		textFile:      textPath,
 		quitChan:      make(chan struct{}),
 
+		lastCheckpoint: lastCheckpoint,
+
 		// batching defaults
 		maxBatch: 1024,
 		maxDelay: 50 * time.Millisecond,
@@ -111,6 +205,9 @@ func NewVectorEngine(dataPath, indexPath, walPath string, maxVectorSize int, ind
 	if err := e.rebuildOffsetsFromDataFile(); err != nil {
 		return nil, fmt.Errorf("rebuildOffsetsFromDataFile: %w", err)
 	}
+	if err := e.loadAttrs(); err != nil {
+		return nil, fmt.Errorf("loadAttrs: %w", err)
+	}
 
 	// Replay WAL if enabled, which will train (if needed) and add pending vectors.
 	if enableWAL {
@@ -122,31 +219,40 @@ func NewVectorEngine(dataPath, indexPath, walPath string, maxVectorSize int, ind
 	// Background maintenance
 	go e.autoCheckpoint()
 	go e.autoFlushData()
+	go e.autoCompact()
 
 	return e, nil
 }
 
 // requiredTrainCount returns a conservative minimum to allow training.
 func (ve *VectorEngineImpl) requiredTrainCount() int {
+	return requiredTrainCountFor(ve.indexType)
+}
+
+// requiredTrainCountFor returns the training minimum of an index description.
+func requiredTrainCountFor(indexType string) int {
+	// Scalar quantizers learn value ranges; SQfp16 needs no training
+	needsSQ := strings.Contains(indexType, "SQ") && !strings.Contains(indexType, "SQfp16")
+
 	// Flat/HNSW need no training
-	if ve.indexType == "Flat" || strings.HasPrefix(ve.indexType, "HNSW") {
+	if !needsSQ && (indexType == "Flat" || strings.HasPrefix(indexType, "HNSW")) {
 		return 0
 	}
 
 	// IVF*n* → need at least nlist (practically 4–10× nlist)
 	nlist := 0
-	if strings.HasPrefix(ve.indexType, "IVF") {
-		fmt.Sscanf(ve.indexType, "IVF%d", &nlist)
+	if strings.HasPrefix(indexType, "IVF") {
+		fmt.Sscanf(indexType, "IVF%d", &nlist)
 	}
 
 	// PQ present? require >= 256 samples minimally
-	needsPQ := strings.Contains(ve.indexType, "PQ")
+	needsPQ := strings.Contains(indexType, "PQ")
 
 	minTrain := 0
 	if nlist > 0 {
 		minTrain = nlist
 	}
-	if needsPQ && minTrain < 256 {
+	if (needsPQ || needsSQ) && minTrain < 256 {
 		minTrain = 256
 	}
 
@@ -157,52 +263,126 @@ func (ve *VectorEngineImpl) InsertVector(id int64, vector []float32) error {
 	if len(vector) != ve.maxVectorSize {
 		return fmt.Errorf("vector length mismatch: expected %d", ve.maxVectorSize)
 	}
+	vector, err := ve.prepareVector(vector)
+	if err != nil {
+		return err
+	}
+
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
 
 	// 1) WAL first (if enabled)
+	if err := ve.logInsert(id, vector); err != nil {
+		return err
+	}
+
+	// 2) Ingest (train if needed, add to FAISS, enqueue persistence); the
+	// WAL entry stays until the next checkpoint persists it
+	return ve.insertAfterWAL(id, vector)
+}
+
+// InsertVectors inserts a batch of vectors with one WAL fsync and a single
+// FAISS add. A repeated ID within the batch keeps its last vector.
+func (ve *VectorEngineImpl) InsertVectors(ids []int64, vectors [][]float32) error {
+	if len(ids) != len(vectors) {
+		return fmt.Errorf("got %d IDs for %d vectors", len(ids), len(vectors))
+	}
+	prepared := make([][]float32, len(vectors))
+	for i, v := range vectors {
+		if len(v) != ve.maxVectorSize {
+			return fmt.Errorf("vector %d length mismatch: expected %d", ids[i], ve.maxVectorSize)
+		}
+		pv, err := ve.prepareVector(v)
+		if err != nil {
+			return fmt.Errorf("vector %d: %w", ids[i], err)
+		}
+		prepared[i] = pv
+	}
+	vectors = prepared
+	ids, vectors = dedupeBatch(ids, vectors)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+
 	if ve.wal != nil {
-		key := make([]byte, 8)
-		binary.LittleEndian.PutUint64(key, uint64(id))
-		if err := ve.wal.WriteEntry(string(key), string(float32ArrayToBytes(vector))); err != nil {
+		entries := make([][2]string, len(ids))
+		for i, id := range ids {
+			key := make([]byte, 8)
+			binary.LittleEndian.PutUint64(key, uint64(id))
+			entries[i] = [2]string{string(key), string(float32ArrayToBytes(vectors[i]))}
+		}
+		if err := ve.wal.WriteEntries(entries); err != nil {
 			return err
 		}
 	}
 
-	// 2) Ingest (train if needed, add to FAISS, enqueue persistence), then mark committed.
-	if err := ve.insertAfterWAL(id, vector); err != nil {
-		return err
-	}
+	return ve.insertBatchAfterWAL(ids, vectors)
+}
 
-	if ve.wal != nil {
-		return ve.wal.MarkCommitted()
+// logInsert writes the WAL entry of an insert, when the WAL is enabled.
+func (ve *VectorEngineImpl) logInsert(id int64, vector []float32) error {
+	if ve.wal == nil {
+		return nil
 	}
-	return nil
+	entry := walVectorEntry(id, vector)
+	return ve.wal.WriteEntry(entry[0], entry[1])
+}
+
+// walVectorEntry is the WAL key and value of an insert.
+func walVectorEntry(id int64, vector []float32) [2]string {
+	key := make([]byte, 8)
+	binary.LittleEndian.PutUint64(key, uint64(id))
+	return [2]string{string(key), string(float32ArrayToBytes(vector))}
 }
 
 // insertAfterWAL performs the ingest without writing to WAL (used by InsertVector and WAL replay).
 func (ve *VectorEngineImpl) insertAfterWAL(id int64, vector []float32) error {
+	return ve.insertBatchAfterWAL([]int64{id}, [][]float32{vector})
+}
+
+// insertBatchAfterWAL ingests distinct IDs: once the index is trained they
+// replace any existing vectors and are added with one AddWithIDs call.
+func (ve *VectorEngineImpl) insertBatchAfterWAL(ids []int64, vectors [][]float32) error {
 	ve.lock.Lock()
 	defer ve.lock.Unlock()
+	return ve.insertBatchLocked(ids, vectors)
+}
+
+// insertBatchLocked is insertBatchAfterWAL for a caller holding ve.lock.
+func (ve *VectorEngineImpl) insertBatchLocked(ids []int64, vectors [][]float32) error {
+	for i, id := range ids {
+		ve.noteRebuildChange(id, vectors[i])
+	}
 
 	nTrain := ve.requiredTrainCount()
 	trained := (nTrain == 0) || ve.baseIndex.IsTrained()
 
 	if trained {
-		// Replace duplicate id if exists
-		sel, _ := faiss.NewIDSelectorBatch([]int64{id})
-		_, _ = ve.idMapIndex.RemoveIDs(sel)
-		sel.Delete()
+		// Replace duplicate ids if they exist
+		_, _ = ve.idMapIndex.RemoveIDs(ids)
 
-		if err := ve.idMapIndex.AddWithIDs(vector, []int64{id}); err != nil {
+		data := make([]float32, 0, len(ids)*ve.maxVectorSize)
+		for _, v := range vectors {
+			data = append(data, v...)
+		}
+		if err := ve.idMapIndex.AddWithIDs(data, ids); err != nil {
 			return err
 		}
 		// Enqueue to persist (batched)
-		ve.enqueuePersist(id, vector)
+		for i, id := range ids {
+			ve.enqueuePersist(id, vectors[i])
+		}
 		return nil
 	}
 
 	// Not trained yet: stage for training + later AddWithIDs
-	ve.pendingAdd[id] = vector
-	ve.trainPool = append(ve.trainPool, vector)
+	for i, id := range ids {
+		ve.pendingAdd[id] = vectors[i]
+		ve.trainPool = append(ve.trainPool, vectors[i])
+	}
 
 	// If we crossed training threshold, train and flush pendingAdd in bulk
 	if len(ve.trainPool) >= nTrain {
@@ -236,16 +416,61 @@ func (ve *VectorEngineImpl) insertAfterWAL(id int64, vector []float32) error {
 	return nil
 }
 
+// dedupeBatch drops all but the last occurrence of each ID, keeping order.
+func dedupeBatch(ids []int64, vectors [][]float32) ([]int64, [][]float32) {
+	last := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		last[id] = i
+	}
+	if len(last) == len(ids) {
+		return ids, vectors
+	}
+	outIDs := make([]int64, 0, len(last))
+	outVecs := make([][]float32, 0, len(last))
+	for i, id := range ids {
+		if last[id] == i {
+			outIDs = append(outIDs, id)
+			outVecs = append(outVecs, vectors[i])
+		}
+	}
+	return outIDs, outVecs
+}
+
 func (ve *VectorEngineImpl) SearchTopK(query []float32, k int) ([]int64, []float32, error) {
+	return ve.SearchTopKWithOptions(query, k, SearchOptions{})
+}
+
+// SearchTopKWithOptions is SearchTopK with search options. With a filter, the
+// k nearest neighbours are computed over the matching vectors only.
+func (ve *VectorEngineImpl) SearchTopKWithOptions(query []float32, k int, opts SearchOptions) ([]int64, []float32, error) {
 	if len(query) != ve.maxVectorSize {
 		return nil, nil, errors.New("invalid query size")
 	}
-	ve.lock.RLock()
-	defer ve.lock.RUnlock()
+	query, err := ve.prepareVector(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	nprobe, unlock, err := ve.lockForSearch(opts, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	if err := ve.validateRefine(opts); err != nil {
+		return nil, nil, err
+	}
+	candidates := searchCandidates(k, opts)
+
+	if opts.Filter != nil {
+		ids, dists, err := ve.searchFilteredLocked(query, candidates, ve.allowedIDs(opts.Filter), nprobe)
+		if err == nil {
+			ids, dists, err = ve.refineLocked(query, ids, dists, k, opts)
+		}
+		return ids, ve.finishScores(dists), err
+	}
 
 	// Search more results than needed to account for filtered out removed vectors
-	searchK := k * 2
-	dists, labels, err := ve.idMapIndex.Search(query, int64(searchK))
+	searchK := candidates * 2
+	dists, labels, err := ve.searchIndexLocked(query, int64(searchK), nprobe)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -258,49 +483,136 @@ func (ve *VectorEngineImpl) SearchTopK(query []float32, k int) ([]int64, []float
 		if _, exists := ve.fileOffsets[label]; exists {
 			filteredLabels = append(filteredLabels, label)
 			filteredDists = append(filteredDists, dists[i])
-			if len(filteredLabels) >= k {
+			if len(filteredLabels) >= candidates {
 				break
 			}
 		}
 	}
+	filteredLabels, filteredDists, err = ve.refineLocked(query, filteredLabels, filteredDists, k, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return filteredLabels, ve.finishScores(filteredDists), nil
+}
+
+// SearchTopKBatch runs SearchTopK for several queries with a single FAISS
+// search. Filtered searches restrict each query with its own ID selector.
+func (ve *VectorEngineImpl) SearchTopKBatch(queries [][]float32, k int, opts SearchOptions) ([][]int64, [][]float32, error) {
+	flat := make([]float32, 0, len(queries)*ve.maxVectorSize)
+	prepared := make([][]float32, len(queries))
+	for i, q := range queries {
+		if len(q) != ve.maxVectorSize {
+			return nil, nil, errors.New("invalid query size")
+		}
+		pq, err := ve.prepareVector(q)
+		if err != nil {
+			return nil, nil, err
+		}
+		prepared[i] = pq
+		flat = append(flat, pq...)
+	}
+	queries = prepared
+	allIDs := make([][]int64, len(queries))
+	allDists := make([][]float32, len(queries))
+	if len(queries) == 0 {
+		return allIDs, allDists, nil
+	}
 
-	return filteredLabels, filteredDists, nil
+	nprobe, unlock, err := ve.lockForSearch(opts, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	if err := ve.validateRefine(opts); err != nil {
+		return nil, nil, err
+	}
+	candidates := searchCandidates(k, opts)
+
+	if opts.Filter != nil {
+		allowed := ve.allowedIDs(opts.Filter)
+		for i, q := range queries {
+			ids, dists, err := ve.searchFilteredLocked(q, candidates, allowed, nprobe)
+			if err == nil {
+				ids, dists, err = ve.refineLocked(q, ids, dists, k, opts)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			allIDs[i], allDists[i] = ids, ve.finishScores(dists)
+		}
+		return allIDs, allDists, nil
+	}
+
+	// Search more results than needed to account for filtered out removed vectors
+	searchK := candidates * 2
+	dists, labels, err := ve.searchIndexLocked(flat, int64(searchK), nprobe)
+	if err != nil {
+		return nil, nil, err
+	}
+	for q := range queries {
+		for i := q * searchK; i < (q+1)*searchK; i++ {
+			if _, exists := ve.fileOffsets[labels[i]]; exists {
+				allIDs[q] = append(allIDs[q], labels[i])
+				allDists[q] = append(allDists[q], dists[i])
+				if len(allIDs[q]) >= candidates {
+					break
+				}
+			}
+		}
+		if allIDs[q], allDists[q], err = ve.refineLocked(queries[q], allIDs[q], allDists[q], k, opts); err != nil {
+			return nil, nil, err
+		}
+		allDists[q] = ve.finishScores(allDists[q])
+	}
+	return allIDs, allDists, nil
 }
 
 func (ve *VectorEngineImpl) RangeSearch(query []float32, radius float32) ([]int64, []float32, error) {
+	return ve.RangeSearchWithOptions(query, radius, SearchOptions{})
+}
+
+// RangeSearchWithOptions is RangeSearch with search options. A range search
+// already returns every vector within the radius, so a filter is applied
+// exactly to its results.
+func (ve *VectorEngineImpl) RangeSearchWithOptions(query []float32, radius float32, opts SearchOptions) ([]int64, []float32, error) {
 	if len(query) != ve.maxVectorSize {
 		return nil, nil, errors.New("invalid query size")
 	}
-
-	ve.lock.RLock()
-	defer ve.lock.RUnlock()
-
-	res, err := ve.idMapIndex.RangeSearch(query, radius)
+	query, err := ve.prepareVector(query)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer res.Delete()
 
-	labels, dists := res.Labels() // []int64,[]float32
-	lims := res.Lims()            // []int64 (len == nq+1)
+	_, unlock, err := ve.lockForSearch(opts, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
 
-	// enforce single query for this API
-	if len(lims) != 2 {
-		return nil, nil, fmt.Errorf("expected 1 query, got %d", len(lims)-1)
+	labels, dists, err := ve.idMapIndex.RangeSearch(query, radius)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	start := int(lims[0])
-	end := int(lims[1])
-	if start < 0 || end < start || end > len(labels) || end > len(dists) {
-		return nil, nil, fmt.Errorf("invalid lims: [%d,%d) over labels=%d dists=%d",
-			start, end, len(labels), len(dists))
+	var allowed map[int64]struct{}
+	if opts.Filter != nil {
+		allowed = make(map[int64]struct{})
+		for _, id := range ve.allowedIDs(opts.Filter) {
+			allowed[id] = struct{}{}
+		}
 	}
 
 	// Filter out vectors that have been removed (not in fileOffsets)
 	var outIDs []int64
 	var outD []float32
 
-	for i := start; i < end; i++ {
+	for i := range labels {
+		if allowed != nil {
+			if _, ok := allowed[labels[i]]; !ok {
+				continue
+			}
+		}
 		if _, exists := ve.fileOffsets[labels[i]]; exists {
 			outIDs = append(outIDs, labels[i])
 			outD = append(outD, dists[i])
@@ -316,31 +628,34 @@ func (ve *VectorEngineImpl) RangeSearch(query []float32, radius float32) ([]int6
 	for i := 0; i < len(outIDs); i++ {
 		ps[i] = pair{outIDs[i], outD[i]}
 	}
-	sort.Slice(ps, func(i, j int) bool { return ps[i].dst < ps[j].dst })
+	// Best first: smallest distance, or largest similarity
+	sort.Slice(ps, func(i, j int) bool {
+		if ve.isSimilarity() {
+			return ps[i].dst > ps[j].dst
+		}
+		return ps[i].dst < ps[j].dst
+	})
 	for i := 0; i < len(outIDs); i++ {
 		outIDs[i], outD[i] = ps[i].id, ps[i].dst
 	}
 
-	return outIDs, outD, nil
+	return outIDs, ve.finishScores(outD), nil
 }
 
 func (ve *VectorEngineImpl) GetVectorByID(id int64) ([]float32, error) {
 	ve.lock.RLock()
+	defer ve.lock.RUnlock()
 	offset, ok := ve.fileOffsets[id]
-	ve.lock.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("ID %d not found", id)
 	}
-
-	recordSize := 8 + 4*ve.maxVectorSize
-	buf := make([]byte, recordSize)
-	if _, err := ve.dataFile.ReadAt(buf, offset); err != nil {
-		return nil, fmt.Errorf("read vector at offset %d: %w", offset, err)
-	}
-	return bytesToFloat32Array(buf[8:])
+	return ve.readVectorAt(offset)
 }
 
 func (ve *VectorEngineImpl) RemoveVector(id int64) error {
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+
 	// 1) WAL first - log the deletion (if enabled)
 	if ve.wal != nil {
 		key := make([]byte, 8)
@@ -352,14 +667,7 @@ func (ve *VectorEngineImpl) RemoveVector(id int64) error {
 	}
 
 	// 2) Remove from FAISS index and tracking
-	if err := ve.removeAfterWAL(id); err != nil {
-		return err
-	}
-
-	if ve.wal != nil {
-		return ve.wal.MarkCommitted()
-	}
-	return nil
+	return ve.removeAfterWAL(id)
 }
 
 // removeAfterWAL performs the removal without writing to WAL (used by RemoveVector and WAL replay).
@@ -368,14 +676,7 @@ func (ve *VectorEngineImpl) removeAfterWAL(id int64) error {
 	defer ve.lock.Unlock()
 
 	// Remove from FAISS index
-	sel, err := faiss.NewIDSelectorBatch([]int64{id})
-	if err != nil {
-		return fmt.Errorf("create ID selector: %w", err)
-	}
-	defer sel.Delete()
-
-	_, err = ve.idMapIndex.RemoveIDs(sel)
-	if err != nil {
+	if _, err := ve.idMapIndex.RemoveIDs([]int64{id}); err != nil {
 		// Some index types (like HNSW) don't support remove_ids
 		// In this case, we'll just remove from tracking but keep the index as-is
 		// The vector will be effectively "removed" from searches since it's not in fileOffsets
@@ -384,9 +685,36 @@ func (ve *VectorEngineImpl) removeAfterWAL(id int64) error {
 
 	// Remove from pending additions if it exists there
 	delete(ve.pendingAdd, id)
+	ve.noteRebuildChange(id, nil)
 
-	// Remove from file offsets tracking
+	// Remove from file offsets tracking and persist a tombstone, after any
+	// queued writes of the same ID so that it stays removed on restart
 	delete(ve.fileOffsets, id)
+	ve.persistMu.Lock()
+	pending := ve.persistBuf
+	ve.persistBuf = nil
+	ve.persistMu.Unlock()
+	if err := ve.writeRecordsLocked(pending); err != nil {
+		return err
+	}
+	// The tombstone is synced by the next checkpoint; until then the WAL
+	// (when enabled) holds the removal
+	if err := ve.appendToDataFile(id, nil); err != nil {
+		return fmt.Errorf("write tombstone: %w", err)
+	}
+
+	if ve.text.Remove(id) {
+		atomic.StoreInt32(&ve.textDirty, 1)
+	}
+	if _, ok := ve.payloads.get(id); ok {
+		if err := ve.appendAttr(attrPayload, id, ""); err != nil {
+			return fmt.Errorf("remove payload: %w", err)
+		}
+		ve.payloads.remove(id)
+	}
+	if err := ve.setParentLocked(id, ""); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -408,6 +736,7 @@ func (ve *VectorEngineImpl) Close() error {
 			ve.wal.Close()
 		}
 		ve.dataFile.Close()
+		ve.attrFile.Close()
 		ve.idMapIndex.Delete()
 	})
 	return nil
@@ -435,18 +764,43 @@ func (ve *VectorEngineImpl) autoCheckpoint() {
 	}
 }
 
+// checkpoint writes the index and pending vectors to disk and then clears
+// the WAL, whose entries are all applied by then.
 func (ve *VectorEngineImpl) checkpoint() error {
+	ve.walMu.Lock()
+	defer ve.walMu.Unlock()
 	ve.lock.Lock()
 	defer ve.lock.Unlock()
 
+	ve.persistMu.Lock()
+	pending := ve.persistBuf
+	ve.persistBuf = nil
+	ve.persistMu.Unlock()
+	if err := ve.writeRecordsLocked(pending); err != nil {
+		return err
+	}
+
 	// Persist the (ID-mapped) index
-	if err := faiss.WriteIndex(ve.idMapIndex, ve.indexFile); err != nil {
+	if err := ve.idMapIndex.write(ve.indexFile); err != nil {
 		return fmt.Errorf("write index: %w", err)
 	}
+	if atomic.CompareAndSwapInt32(&ve.textDirty, 1, 0) {
+		if err := ve.text.Save(ve.textFile); err != nil {
+			atomic.StoreInt32(&ve.textDirty, 1)
+			return fmt.Errorf("write text index: %w", err)
+		}
+	}
 	// Ensure data file flushed
 	if err := ve.dataFile.Sync(); err != nil {
 		return fmt.Errorf("sync data file: %w", err)
 	}
+	if err := ve.attrFile.Sync(); err != nil {
+		return fmt.Errorf("sync attribute file: %w", err)
+	}
+	ve.lastCheckpoint = time.Now()
+	if ve.wal != nil {
+		return ve.wal.Clear()
+	}
 	return nil
 }
 
@@ -468,6 +822,35 @@ func (ve *VectorEngineImpl) replayWAL() error {
 			continue
 		}
 		keyBytes := []byte(entry[0])
+		if len(keyBytes) == 9 && keyBytes[0] == walTextPrefix {
+			id := int64(binary.LittleEndian.Uint64(keyBytes[1:]))
+			ve.setTextAfterWAL(id, entry[1])
+			continue
+		}
+		if len(keyBytes) == 9 && keyBytes[0] == walKeyPrefix {
+			id := int64(binary.LittleEndian.Uint64(keyBytes[1:]))
+			ve.lock.Lock()
+			err := ve.setKeyLocked(id, entry[1])
+			ve.lock.Unlock()
+			if err != nil {
+				return fmt.Errorf("replay key id=%d: %w", id, err)
+			}
+			continue
+		}
+		if len(keyBytes) == 9 && keyBytes[0] == walParentPrefix {
+			id := int64(binary.LittleEndian.Uint64(keyBytes[1:]))
+			if err := ve.setParentAfterWAL(id, entry[1]); err != nil {
+				return fmt.Errorf("replay parent id=%d: %w", id, err)
+			}
+			continue
+		}
+		if len(keyBytes) == 9 && keyBytes[0] == walPayloadPrefix {
+			id := int64(binary.LittleEndian.Uint64(keyBytes[1:]))
+			if err := ve.setPayloadAfterWAL(id, entry[1]); err != nil {
+				return fmt.Errorf("replay payload id=%d: %w", id, err)
+			}
+			continue
+		}
 		if len(keyBytes) != 8 {
 			return fmt.Errorf("invalid WAL key length: expected 8, got %d", len(keyBytes))
 		}
@@ -493,61 +876,10 @@ func (ve *VectorEngineImpl) replayWAL() error {
 		}
 	}
 
-	// After successful replay, checkpoint and clear WAL
+	// After successful replay, checkpoint, which also clears the WAL
 	if err := ve.checkpoint(); err != nil {
 		return fmt.Errorf("checkpoint after replay: %w", err)
 	}
-	ve.wal.Clear()
-	return nil
-}
-
-func (ve *VectorEngineImpl) appendToDataFile(id int64, vector []float32) error {
-	// Seek end, remember offset for GetVectorByID
-	pos, err := ve.dataFile.Seek(0, io.SeekEnd)
-	if err != nil {
-		return err
-	}
-	buf := make([]byte, 8+len(vector)*4)
-	binary.LittleEndian.PutUint64(buf[0:8], uint64(id))
-	for i, v := range vector {
-		binary.LittleEndian.PutUint32(buf[8+i*4:], math.Float32bits(v))
-	}
-	if _, err := ve.dataFile.Write(buf); err != nil {
-		return err
-	}
-	ve.fileOffsets[id] = pos
-	return nil
-}
-
-func (ve *VectorEngineImpl) rebuildOffsetsFromDataFile() error {
-	// Walk the file and record the last offset for each ID (latest write wins).
-	if _, err := ve.dataFile.Seek(0, io.SeekStart); err != nil {
-		return err
-	}
-	recordSize := 8 + 4*ve.maxVectorSize
-	offset := int64(0)
-
-	for {
-		buf := make([]byte, recordSize)
-		n, err := ve.dataFile.Read(buf)
-		if err != nil {
-			if err == io.EOF || (err == io.ErrUnexpectedEOF && n == 0) {
-				break
-			}
-			if err == io.ErrUnexpectedEOF && n > 0 {
-				// Truncated tail — ignore the last partial record
-				break
-			}
-			return fmt.Errorf("read data file: %w", err)
-		}
-		if n < recordSize {
-			// Partial/truncated record — ignore
-			break
-		}
-		id := int64(binary.LittleEndian.Uint64(buf[0:8]))
-		ve.fileOffsets[id] = offset
-		offset += int64(recordSize)
-	}
 	return nil
 }
 
@@ -588,32 +920,48 @@ func (ve *VectorEngineImpl) autoFlushData() {
 
 func (ve *VectorEngineImpl) flushData(force bool) {
 	ve.persistMu.Lock()
-	buf := ve.persistBuf
-	if !force && len(buf) == 0 {
-		ve.persistMu.Unlock()
-		return
-	}
-	ve.persistBuf = nil
+	empty := len(ve.persistBuf) == 0
 	ve.persistMu.Unlock()
-
-	if len(buf) == 0 {
+	if empty {
 		return
 	}
 
-	// single locked append to file + single fsync
+	// single locked append to file + single fsync; the buffer is taken under
+	// ve.lock so that removals (which flush it themselves) stay ordered
 	ve.lock.Lock()
 	defer ve.lock.Unlock()
 
+	ve.persistMu.Lock()
+	buf := ve.persistBuf
+	ve.persistBuf = nil
+	ve.persistMu.Unlock()
+
 	for _, it := range buf {
 		if err := ve.appendToDataFile(it.id, it.vec); err != nil {
 			log.Printf("appendToDataFile failed for id=%d: %v", it.id, err)
 		}
 	}
-	if err := ve.dataFile.Sync(); err != nil {
-		log.Printf("data file sync failed: %v", err)
+	if len(buf) > 0 {
+		if err := ve.dataFile.Sync(); err != nil {
+			log.Printf("data file sync failed: %v", err)
+		}
 	}
 }
 
+// writeRecordsLocked appends queued records; the caller must hold ve.lock
+// and sync the data file.
+func (ve *VectorEngineImpl) writeRecordsLocked(buf []struct {
+	id  int64
+	vec []float32
+}) error {
+	for _, it := range buf {
+		if err := ve.appendToDataFile(it.id, it.vec); err != nil {
+			return fmt.Errorf("appendToDataFile id=%d: %w", it.id, err)
+		}
+	}
+	return nil
+}
+
 func float32ArrayToBytes(arr []float32) []byte {
 	buf := make([]byte, len(arr)*4)
 	for i, v := range arr {
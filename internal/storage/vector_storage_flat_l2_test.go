@@ -4,6 +4,7 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -19,6 +20,24 @@ func randomVector(dim int) []float32 {
 	return vec
 }
 
+// openTestVectorEngine opens a Flat vector engine without a WAL on the files
+// in dir, creating them on first use, and closes it when the test ends.
+// Tests pass a t.TempDir() and open the same dir again after Close to check
+// what survives a restart.
+func openTestVectorEngine(t *testing.T, dir string, dim, metric int, encoding string) *VectorEngineImpl {
+	t.Helper()
+	ve, err := NewVectorEngineWithEncoding(
+		filepath.Join(dir, "vector_data.db"),
+		filepath.Join(dir, "vector_index.faiss"),
+		filepath.Join(dir, "vector_wal.db"),
+		dim, "Flat", metric, false, encoding)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	t.Cleanup(func() { ve.Close() })
+	return ve
+}
+
 func TestVectorEngineImpl_InsertAndSearch(t *testing.T) {
 	dataPath := "testdata/vector_data.db"
 	indexPath := "testdata/vector_index.faiss"
@@ -328,3 +347,272 @@ func TestVectorEngineImpl_InvalidInsert(t *testing.T) {
 		t.Error("Expected error for nil vector")
 	}
 }
+
+func TestVectorEngineImpl_BatchInsertAndSearch(t *testing.T) {
+	dataPath := "testdata/vector_data_batch.db"
+	indexPath := "testdata/vector_index_batch.faiss"
+	walPath := "testdata/vector_wal_batch.db"
+	attrPath := "testdata/vector_data_batch_attrs.db"
+	maxVectorSize := 4
+
+	os.MkdirAll("testdata", 0755)
+	for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, maxVectorSize, "Flat", faiss.MetricL2, true)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	ids := make([]int64, 500)
+	vectors := make([][]float32, 500)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+		vectors[i] = randomVector(maxVectorSize)
+	}
+	// A repeated ID keeps its last vector
+	ids = append(ids, 1)
+	vectors = append(vectors, vectors[10])
+	if err := ve.InsertVectors(ids, vectors); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	if err := ve.InsertVectors([]int64{1}, [][]float32{{1}}); err == nil {
+		t.Error("Expected vector length mismatch error")
+	}
+
+	time.Sleep(500 * time.Millisecond) // Ensure batch writes are flushed
+
+	stored, err := ve.GetVectorByID(1)
+	if err != nil {
+		t.Fatalf("GetVectorByID failed: %v", err)
+	}
+	if !reflect.DeepEqual(stored, vectors[10]) {
+		t.Errorf("Expected the last vector for a repeated ID, got %v", stored)
+	}
+
+	queries := [][]float32{vectors[100], vectors[200], vectors[300]}
+	batchIDs, _, err := ve.SearchTopKBatch(queries, 3, SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchTopKBatch failed: %v", err)
+	}
+	if len(batchIDs) != len(queries) {
+		t.Fatalf("Expected %d result lists, got %d", len(queries), len(batchIDs))
+	}
+	for i, q := range queries {
+		single, _, err := ve.SearchTopK(q, 3)
+		if err != nil {
+			t.Fatalf("SearchTopK failed: %v", err)
+		}
+		if !reflect.DeepEqual(batchIDs[i], single) {
+			t.Errorf("query %d: batch results %v differ from single search %v", i, batchIDs[i], single)
+		}
+	}
+}
+
+func TestVectorEngineImpl_Cosine(t *testing.T) {
+	dataPath := "testdata/vector_data_cosine.db"
+	indexPath := "testdata/vector_index_cosine.faiss"
+	walPath := "testdata/vector_wal_cosine.db"
+	attrPath := "testdata/vector_data_cosine_attrs.db"
+
+	os.MkdirAll("testdata", 0755)
+	for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "Flat", MetricCosine, true)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	if err := ve.InsertVectors([]int64{1, 2, 3}, [][]float32{{10, 0}, {0, 5}, {-2, 0}}); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	if err := ve.InsertVector(4, []float32{0, 0}); err == nil {
+		t.Error("Expected an error for a zero vector")
+	}
+	time.Sleep(500 * time.Millisecond) // Ensure batch writes are flushed
+
+	// Magnitude does not matter, only direction
+	ids, scores, err := ve.SearchTopK([]float32{3, 0}, 3)
+	if err != nil {
+		t.Fatalf("SearchTopK failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Fatalf("Expected ids [1 2 3], got %v", ids)
+	}
+	want := []float32{1, 0, -1}
+	for i := range want {
+		if d := scores[i] - want[i]; d > 1e-6 || d < -1e-6 {
+			t.Errorf("Expected similarities %v, got %v", want, scores)
+			break
+		}
+	}
+}
+
+func TestVectorEngineImpl_MigrateIndex(t *testing.T) {
+	dataPath := "testdata/vector_data_migrate.db"
+	indexPath := "testdata/vector_index_migrate.faiss"
+	walPath := "testdata/vector_wal_migrate.db"
+	attrPath := "testdata/vector_data_migrate_attrs.db"
+
+	os.MkdirAll("testdata", 0755)
+	for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "Flat", faiss.MetricL2, true)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	if err := ve.InsertVectors([]int64{1, 2, 3}, [][]float32{{10, 0}, {0, 5}, {-2, 0}}); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	if err := ve.MigrateIndex("Bogus", MetricCosine, func(error) {}); err == nil {
+		t.Error("Expected an error for an invalid index type")
+	}
+
+	done := make(chan error, 1)
+	if err := ve.MigrateIndex("HNSW32", MetricCosine, func(err error) { done <- err }); err != nil {
+		t.Fatalf("MigrateIndex failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Migration failed: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Migration did not finish")
+	}
+
+	// Stored vectors were normalized into the cosine index
+	ids, scores, err := ve.SearchTopK([]float32{3, 0}, 3)
+	if err != nil {
+		t.Fatalf("SearchTopK failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3}) || scores[0] < 0.999 {
+		t.Errorf("Expected cosine ranking [1 2 3], got %v %v", ids, scores)
+	}
+}
+
+func TestVectorEngineImpl_SearchByID(t *testing.T) {
+	dataPath := "testdata/vector_data_by_id.db"
+	indexPath := "testdata/vector_index_by_id.faiss"
+	walPath := "testdata/vector_wal_by_id.db"
+	attrPath := "testdata/vector_data_by_id_attrs.db"
+
+	os.MkdirAll("testdata", 0755)
+	for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "Flat", faiss.MetricL2, true)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	if err := ve.InsertVectors([]int64{1, 2, 3, 4}, [][]float32{{0, 0}, {1, 0}, {2, 0}, {0, 5}}); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond) // Ensure batch writes are flushed
+
+	ids, _, err := ve.SearchByID([]int64{1}, nil, 2, SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchByID failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Errorf("Expected [2 3] without the source ID, got %v", ids)
+	}
+
+	// Query (2,-5): away from 4, towards 2
+	ids, _, err = ve.SearchByID([]int64{2}, []int64{4}, 2, SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchByID with negative failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{3, 1}) {
+		t.Errorf("Expected [3 1], got %v", ids)
+	}
+
+	if _, _, err := ve.SearchByID(nil, []int64{4}, 2, SearchOptions{}); err == nil {
+		t.Error("Expected an error without positive IDs")
+	}
+	if _, _, err := ve.SearchByID([]int64{99}, nil, 2, SearchOptions{}); err == nil {
+		t.Error("Expected an error for an unknown ID")
+	}
+}
+
+func TestVectorEngineImpl_Diversity(t *testing.T) {
+	dataPath := "testdata/vector_data_mmr.db"
+	indexPath := "testdata/vector_index_mmr.faiss"
+	walPath := "testdata/vector_wal_mmr.db"
+	attrPath := "testdata/vector_data_mmr_attrs.db"
+
+	os.MkdirAll("testdata", 0755)
+	for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath, attrPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "Flat", faiss.MetricL2, true)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	// 1 and 2 are near-duplicates, 3 is farther but distinct
+	if err := ve.InsertVectors([]int64{1, 2, 3}, [][]float32{{1, 0}, {0.99, 0.01}, {0.6, 0.8}}); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond) // Ensure batch writes are flushed
+
+	query := []float32{1, 0.2}
+	ids, _, err := ve.SearchTopK(query, 2)
+	if err != nil || !reflect.DeepEqual(ids, []int64{2, 1}) {
+		t.Fatalf("Expected [2 1] without diversity, got %v %v", ids, err)
+	}
+	lambda := 0.3
+	ids, dists, err := ve.SearchTopKWithOptions(query, 2, SearchOptions{Diversity: &lambda})
+	if err != nil {
+		t.Fatalf("SearchTopKWithOptions failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Errorf("Expected [2 3] with diversity, got %v", ids)
+	}
+	if len(dists) != 2 || dists[1] < 0.51 || dists[1] > 0.53 {
+		t.Errorf("Expected exact distances to the query, got %v", dists)
+	}
+	bad := 1.5
+	if _, _, err := ve.SearchTopKWithOptions(query, 2, SearchOptions{Diversity: &bad}); err == nil {
+		t.Error("Expected an error for lambda above 1")
+	}
+}
@@ -71,6 +71,47 @@ func TestVectorEngineImpl_InsertAndSearch_IVF32Flat(t *testing.T) {
 		}
 	})
 
+	t.Run("Search with per-query nprobe", func(t *testing.T) {
+		// Probing every list makes the IVF search exhaustive
+		ids, dists, err := ve.SearchTopKWithOptions(vec, 1, SearchOptions{Params: map[string]float64{"nprobe": 32}})
+		if err != nil {
+			t.Fatalf("SearchTopKWithOptions failed: %v", err)
+		}
+		if len(ids) != 1 || dists[0] != 0 {
+			t.Errorf("Expected an exact match with nprobe=32, got %v %v", ids, dists)
+		}
+		if _, _, err := ve.SearchTopKWithOptions(vec, 1, SearchOptions{Params: map[string]float64{"efSearch": 64}}); err == nil {
+			t.Error("Expected an error for efSearch on an IVF index")
+		}
+		if err := ve.SetSearchDefaults(map[string]float64{"nprobe": 32}); err != nil {
+			t.Fatalf("SetSearchDefaults failed: %v", err)
+		}
+		if ids, dists, err := ve.SearchTopK(vec, 1); err != nil || len(ids) != 1 || dists[0] != 0 {
+			t.Errorf("Expected an exact match with the default nprobe=32, got %v %v %v", ids, dists, err)
+		}
+	})
+
+	t.Run("Rebuild index", func(t *testing.T) {
+		if err := ve.RebuildIndex(0); err != nil {
+			t.Fatalf("RebuildIndex failed: %v", err)
+		}
+		// The current index keeps serving while the new one is built
+		if _, _, err := ve.SearchTopK(vec, 1); err != nil {
+			t.Errorf("SearchTopK during rebuild failed: %v", err)
+		}
+		deadline := time.Now().Add(30 * time.Second)
+		for ve.IsRebuilding() && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if ve.IsRebuilding() {
+			t.Fatal("Index rebuild did not finish")
+		}
+		ids, dists, err := ve.SearchTopK(vec, 1)
+		if err != nil || len(ids) != 1 || dists[0] != 0 {
+			t.Errorf("Expected an exact match after rebuild, got %v %v %v", ids, dists, err)
+		}
+	})
+
 	t.Run("Search non-existent vector", func(t *testing.T) {
 		fakeVec := randomVector(maxVectorSize)
 		ids, _, err := ve.SearchTopK(fakeVec, 1)
@@ -71,6 +71,21 @@ func TestVectorEngineImpl_InsertAndSearch_IVF32PQ4(t *testing.T) {
 		}
 	})
 
+	t.Run("Rerank with exact distances", func(t *testing.T) {
+		opts := SearchOptions{Params: map[string]float64{"nprobe": 32}, Rerank: 10}
+		ids, dists, err := ve.SearchTopKWithOptions(vec, 1, opts)
+		if err != nil {
+			t.Fatalf("SearchTopKWithOptions failed: %v", err)
+		}
+		// PQ distances are approximate; reranked ones are exact
+		if len(ids) != 1 || ids[0] != 1999 || dists[0] != 0 {
+			t.Errorf("Expected id 1999 at distance 0, got %v %v", ids, dists)
+		}
+		if _, _, err := ve.SearchTopKWithOptions(vec, 1, SearchOptions{Rerank: -1}); err == nil {
+			t.Error("Expected an error for a negative rerank factor")
+		}
+	})
+
 	t.Run("Search non-existent vector", func(t *testing.T) {
 		fakeVec := randomVector(maxVectorSize)
 		ids, _, err := ve.SearchTopK(fakeVec, 1)
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors of the conditional vector writes.
+var (
+	ErrVectorExists   = errors.New("vector already exists")
+	ErrVectorNotFound = errors.New("vector not found")
+)
+
+// VectorAttrs are the text, payload and parent written together with a
+// vector; empty fields leave the stored ones unchanged.
+type VectorAttrs struct {
+	Text    string
+	Payload string
+	Parent  string
+}
+
+// InsertVectorWithAttrs inserts a vector together with its attributes, all
+// validated before anything is written and logged as one WAL write, so that
+// a rejected payload never leaves the vector stored without it.
+func (ve *VectorEngineImpl) InsertVectorWithAttrs(id int64, vector []float32, attrs VectorAttrs) error {
+	_, err := ve.insertIf(id, vector, attrs, func(bool) error { return nil })
+	return err
+}
+
+// UpsertVector inserts a vector or replaces the one stored under id, and
+// reports whether it was new. It is InsertVectorWithAttrs with the outcome
+// made explicit.
+func (ve *VectorEngineImpl) UpsertVector(id int64, vector []float32, attrs VectorAttrs) (bool, error) {
+	return ve.insertIf(id, vector, attrs, func(bool) error { return nil })
+}
+
+// InsertVectorIfAbsent inserts a vector unless id is already stored, in
+// which case it returns ErrVectorExists and leaves the vector unchanged.
+func (ve *VectorEngineImpl) InsertVectorIfAbsent(id int64, vector []float32, attrs VectorAttrs) error {
+	_, err := ve.insertIf(id, vector, attrs, func(exists bool) error {
+		if exists {
+			return fmt.Errorf("ID %d: %w", id, ErrVectorExists)
+		}
+		return nil
+	})
+	return err
+}
+
+// UpdateVector replaces a stored vector, keeping the payload, text and
+// parent that attrs leaves empty; it returns ErrVectorNotFound when id is
+// not stored.
+func (ve *VectorEngineImpl) UpdateVector(id int64, vector []float32, attrs VectorAttrs) error {
+	_, err := ve.insertIf(id, vector, attrs, func(exists bool) error {
+		if !exists {
+			return fmt.Errorf("ID %d: %w", id, ErrVectorNotFound)
+		}
+		return nil
+	})
+	return err
+}
+
+// insertIf inserts a vector with its attributes when check, given whether
+// id is stored, returns nil, and reports whether the vector was new. The
+// check, the WAL write and the insert happen under one hold of ve.lock, so
+// that no other write to id can come in between.
+func (ve *VectorEngineImpl) insertIf(id int64, vector []float32, attrs VectorAttrs, check func(exists bool) error) (bool, error) {
+	if len(vector) != ve.maxVectorSize {
+		return false, fmt.Errorf("vector length mismatch: expected %d", ve.maxVectorSize)
+	}
+	vector, err := ve.prepareVector(vector)
+	if err != nil {
+		return false, err
+	}
+	if err := ValidatePayload(attrs.Payload); err != nil {
+		return false, err
+	}
+
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+	exists := ve.vectorExistsLocked(id)
+	if err := check(exists); err != nil {
+		return false, err
+	}
+	if ve.wal != nil {
+		entries := [][2]string{walVectorEntry(id, vector)}
+		if attrs.Text != "" {
+			entries = append(entries, [2]string{walAttrKey(walTextPrefix, id), attrs.Text})
+		}
+		if attrs.Payload != "" {
+			entries = append(entries, [2]string{walAttrKey(walPayloadPrefix, id), attrs.Payload})
+		}
+		if attrs.Parent != "" {
+			entries = append(entries, [2]string{walAttrKey(walParentPrefix, id), attrs.Parent})
+		}
+		if err := ve.wal.WriteEntries(entries); err != nil {
+			return false, err
+		}
+	}
+	if err := ve.insertBatchLocked([]int64{id}, [][]float32{vector}); err != nil {
+		return false, err
+	}
+	if attrs.Text != "" {
+		ve.setTextAfterWAL(id, attrs.Text)
+	}
+	if attrs.Payload != "" {
+		if err := ve.setPayloadLocked(id, attrs.Payload); err != nil {
+			return false, err
+		}
+	}
+	if attrs.Parent != "" {
+		if err := ve.setParentLocked(id, attrs.Parent); err != nil {
+			return false, err
+		}
+	}
+	return !exists, nil
+}
+
+// UpdatePayload applies a JSON merge patch (RFC 7396) to the payload of a
+// stored vector and returns the new payload; the vector and its index entry
+// are left as they are. Removing every member removes the payload.
+func (ve *VectorEngineImpl) UpdatePayload(id int64, patch string) (string, error) {
+	var p interface{}
+	dec := json.NewDecoder(strings.NewReader(patch))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return "", fmt.Errorf("invalid merge patch: %w", err)
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return "", errors.New("invalid merge patch: expected a JSON object")
+	}
+
+	// Check, merge and write under one hold of ve.lock, so that a concurrent
+	// patch is not lost and a concurrent removal cannot leave the payload
+	// without its vector
+	ve.walMu.RLock()
+	defer ve.walMu.RUnlock()
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+	if !ve.vectorExistsLocked(id) {
+		return "", fmt.Errorf("ID %d: %w", id, ErrVectorNotFound)
+	}
+	var current interface{}
+	if raw, ok := ve.payloads.get(id); ok {
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&current); err != nil {
+			return "", fmt.Errorf("stored payload of ID %d: %w", id, err)
+		}
+	}
+	merged, _ := mergePatch(current, p).(map[string]interface{})
+	payload := ""
+	if len(merged) > 0 {
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return "", err
+		}
+		payload = string(data)
+	}
+	if ve.wal != nil {
+		if err := ve.wal.WriteEntry(walAttrKey(walPayloadPrefix, id), payload); err != nil {
+			return "", err
+		}
+	}
+	if err := ve.setPayloadLocked(id, payload); err != nil {
+		return "", err
+	}
+	return payload, nil
+}
+
+// vectorExistsLocked reports whether a vector is stored under id, including
+// one still queued for the data file; the caller must hold ve.lock, which
+// keeps the queue from being flushed during the check.
+func (ve *VectorEngineImpl) vectorExistsLocked(id int64) bool {
+	if _, ok := ve.fileOffsets[id]; ok {
+		return true
+	}
+	if _, ok := ve.pendingAdd[id]; ok {
+		return true
+	}
+	ve.persistMu.Lock()
+	defer ve.persistMu.Unlock()
+	for _, it := range ve.persistBuf {
+		if it.id == id {
+			return true
+		}
+	}
+	return false
+}
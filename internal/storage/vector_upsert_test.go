@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/DataIntelligenceCrew/go-faiss"
+)
+
+func TestConditionalVectorWrites(t *testing.T) {
+	os.MkdirAll("testdata", 0755)
+	dataPath := "testdata/vector_cond_data.db"
+	indexPath := "testdata/vector_cond_index.faiss"
+	walPath := "testdata/vector_cond_wal.db"
+	for _, p := range []string{dataPath, indexPath, walPath} {
+		os.Remove(p)
+	}
+	t.Cleanup(func() {
+		for _, p := range []string{dataPath, indexPath, walPath} {
+			os.Remove(p)
+		}
+	})
+
+	ve, err := NewVectorEngine(dataPath, indexPath, walPath, 2, "Flat", faiss.MetricL2, true)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer ve.Close()
+
+	if err := ve.UpdateVector(1, []float32{1, 1}, VectorAttrs{}); !errors.Is(err, ErrVectorNotFound) {
+		t.Fatalf("expected ErrVectorNotFound, got %v", err)
+	}
+	if err := ve.InsertVectorIfAbsent(1, []float32{1, 1}, VectorAttrs{}); err != nil {
+		t.Fatalf("InsertVectorIfAbsent failed: %v", err)
+	}
+	if err := ve.InsertVectorIfAbsent(1, []float32{9, 9}, VectorAttrs{}); !errors.Is(err, ErrVectorExists) {
+		t.Fatalf("expected ErrVectorExists, got %v", err)
+	}
+	if err := ve.UpdateVector(1, []float32{2, 2}, VectorAttrs{}); err != nil {
+		t.Fatalf("UpdateVector failed: %v", err)
+	}
+	if created, err := ve.UpsertVector(1, []float32{3, 3}, VectorAttrs{}); err != nil || created {
+		t.Fatalf("expected upsert to replace ID 1, got created=%v (%v)", created, err)
+	}
+	if created, err := ve.UpsertVector(2, []float32{4, 4}, VectorAttrs{}); err != nil || !created {
+		t.Fatalf("expected upsert to create ID 2, got created=%v (%v)", created, err)
+	}
+	ve.flushData(true)
+	if vec, err := ve.GetVectorByID(1); err != nil || !reflect.DeepEqual(vec, []float32{3, 3}) {
+		t.Fatalf("expected the upserted vector of ID 1, got %v (%v)", vec, err)
+	}
+	ids, _, err := ve.SearchTopK([]float32{3, 3}, 1)
+	if err != nil || len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("expected ID 1 to be indexed with its new vector, got %v (%v)", ids, err)
+	}
+
+	// Attributes are written with the vector, or not at all
+	if err := ve.InsertVectorWithAttrs(3, []float32{5, 5}, VectorAttrs{Payload: `[1]`}); err == nil {
+		t.Fatal("expected an error for a non-object payload")
+	}
+	if err := ve.UpdateVector(3, []float32{5, 5}, VectorAttrs{}); !errors.Is(err, ErrVectorNotFound) {
+		t.Fatalf("expected no vector stored for a rejected payload, got %v", err)
+	}
+	attrs := VectorAttrs{Text: "red shoes", Payload: `{"color":"red"}`, Parent: "doc-3"}
+	if err := ve.InsertVectorWithAttrs(3, []float32{5, 5}, attrs); err != nil {
+		t.Fatalf("InsertVectorWithAttrs failed: %v", err)
+	}
+	if payload, err := ve.GetPayload(3); err != nil || payload != attrs.Payload {
+		t.Fatalf("expected the payload written with ID 3, got %q (%v)", payload, err)
+	}
+	if parent := ve.parents[3]; parent != "doc-3" {
+		t.Fatalf("expected parent doc-3 for ID 3, got %q", parent)
+	}
+}
+
+func TestUpdatePayload(t *testing.T) {
+	ve := openTestVectorEngine(t, t.TempDir(), 2, faiss.MetricL2, "")
+	ve.pendingAdd[2] = []float32{2, 2}
+	if err := ve.appendToDataFile(1, []float32{1, 1}); err != nil {
+		t.Fatalf("appendToDataFile failed: %v", err)
+	}
+	ve.persistBuf = append(ve.persistBuf, struct {
+		id  int64
+		vec []float32
+	}{3, []float32{3, 3}})
+
+	for id, want := range map[int64]bool{1: true, 2: true, 3: true, 4: false} {
+		if got := ve.vectorExistsLocked(id); got != want {
+			t.Errorf("vectorExistsLocked(%d) = %v, want %v", id, got, want)
+		}
+	}
+
+	if _, err := ve.UpdatePayload(4, `{"a":1}`); !errors.Is(err, ErrVectorNotFound) {
+		t.Fatalf("expected ErrVectorNotFound for a missing vector, got %v", err)
+	}
+	if _, err := ve.UpdatePayload(1, `[1]`); err == nil {
+		t.Fatal("expected an error for a non-object patch")
+	}
+
+	steps := []struct {
+		patch, want string
+	}{
+		{`{"color":"red","size":10}`, `{"color":"red","size":10}`},
+		{`{"size":12,"tags":["new"]}`, `{"color":"red","size":12,"tags":["new"]}`},
+		{`{"color":null,"size":null,"tags":null}`, ``},
+	}
+	for _, step := range steps {
+		got, err := ve.UpdatePayload(1, step.patch)
+		if err != nil {
+			t.Fatalf("UpdatePayload(%s) failed: %v", step.patch, err)
+		}
+		if got != step.want {
+			t.Fatalf("UpdatePayload(%s) = %s, want %s", step.patch, got, step.want)
+		}
+	}
+	if _, err := ve.GetPayload(1); err == nil {
+		t.Fatal("expected the emptied payload to be removed")
+	}
+
+	// Concurrent patches of different members must all be kept
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := ve.UpdatePayload(2, fmt.Sprintf(`{"k%d":%d}`, i, i)); err != nil {
+				t.Errorf("UpdatePayload failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	payload, err := ve.GetPayload(2)
+	if err != nil {
+		t.Fatalf("GetPayload failed: %v", err)
+	}
+	var merged map[string]int
+	if err := json.Unmarshal([]byte(payload), &merged); err != nil || len(merged) != 20 {
+		t.Fatalf("expected 20 members after concurrent patches, got %s (%v)", payload, err)
+	}
+}
@@ -0,0 +1,102 @@
+package textindex
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Token is an analyzed term and its position in the source text. Positions
+// count removed stop words, so phrase queries see the same gaps as documents.
+type Token struct {
+	Term string
+	Pos  int
+}
+
+// Analyzer turns text into index terms.
+type Analyzer struct {
+	name      string
+	split     func(r rune) bool
+	lowercase bool
+	stopWords map[string]struct{}
+	stem      bool
+}
+
+// Analyzers lists the supported analyzer names.
+var Analyzers = []string{"standard", "simple", "whitespace", "english"}
+
+// DefaultAnalyzer is used when a space does not name one.
+const DefaultAnalyzer = "standard"
+
+// NewAnalyzer returns the named analyzer:
+//
+//	standard   - splits on anything but letters and digits, lowercases
+//	simple     - splits on anything but letters, lowercases
+//	whitespace - splits on whitespace only, keeps case
+//	english    - standard plus English stop words and plural stemming
+func NewAnalyzer(name string) (*Analyzer, error) {
+	notAlnum := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	switch name {
+	case "", "standard":
+		return &Analyzer{name: "standard", split: notAlnum, lowercase: true}, nil
+	case "simple":
+		return &Analyzer{name: name, split: func(r rune) bool { return !unicode.IsLetter(r) }, lowercase: true}, nil
+	case "whitespace":
+		return &Analyzer{name: name, split: unicode.IsSpace}, nil
+	case "english":
+		return &Analyzer{name: name, split: notAlnum, lowercase: true, stopWords: englishStopWords, stem: true}, nil
+	}
+	return nil, fmt.Errorf("unknown analyzer '%s' (allowed: %s)", name, strings.Join(Analyzers, ", "))
+}
+
+func (a *Analyzer) Name() string {
+	return a.name
+}
+
+// Analyze splits text into tokens.
+func (a *Analyzer) Analyze(text string) []Token {
+	var tokens []Token
+	for pos, word := range strings.FieldsFunc(text, a.split) {
+		if a.lowercase {
+			word = strings.ToLower(word)
+		}
+		if _, stop := a.stopWords[word]; stop {
+			continue
+		}
+		if a.stem {
+			word = stemPlural(word)
+		}
+		tokens = append(tokens, Token{Term: word, Pos: pos})
+	}
+	return tokens
+}
+
+// stemPlural is the "S" stemmer (Harman, 1991): it conflates common English
+// plural forms and leaves everything else alone.
+func stemPlural(w string) string {
+	if len(w) <= 3 {
+		return w
+	}
+	switch {
+	case strings.HasSuffix(w, "ies") && !strings.HasSuffix(w, "eies") && !strings.HasSuffix(w, "aies"):
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "es") && !strings.HasSuffix(w, "aes") && !strings.HasSuffix(w, "ees") && !strings.HasSuffix(w, "oes"):
+		return w[:len(w)-1]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "ss"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+var englishStopWords = func() map[string]struct{} {
+	words := []string{
+		"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in",
+		"into", "is", "it", "no", "not", "of", "on", "or", "such", "that", "the",
+		"their", "then", "there", "these", "they", "this", "to", "was", "will", "with",
+	}
+	m := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		m[w] = struct{}{}
+	}
+	return m
+}()
@@ -0,0 +1,318 @@
+package textindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// BM25 parameters (Robertson et al.); the usual defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// snapshotMagic starts every saved index file.
+const snapshotMagic = "SHTX1"
+
+// Index is an in-memory positional inverted index with BM25 scoring. It is
+// safe for concurrent use and can be saved to and loaded from a file.
+type Index struct {
+	mu       sync.RWMutex
+	analyzer *Analyzer
+	postings map[string]map[int64][]int32 // term -> doc -> sorted positions
+	docs     map[int64]docInfo
+	totalLen int64
+}
+
+type docInfo struct {
+	length int
+	terms  []string
+}
+
+// New returns an empty index using the given analyzer.
+func New(analyzer *Analyzer) *Index {
+	return &Index{
+		analyzer: analyzer,
+		postings: make(map[string]map[int64][]int32),
+		docs:     make(map[int64]docInfo),
+	}
+}
+
+func (ix *Index) Analyzer() *Analyzer {
+	return ix.analyzer
+}
+
+// Add indexes text under id, replacing any previous text for id.
+func (ix *Index) Add(id int64, text string) {
+	tokens := ix.analyzer.Analyze(text)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.removeLocked(id)
+	info := docInfo{length: len(tokens)}
+	for _, tok := range tokens {
+		docs, ok := ix.postings[tok.Term]
+		if !ok {
+			docs = make(map[int64][]int32)
+			ix.postings[tok.Term] = docs
+		}
+		if _, seen := docs[id]; !seen {
+			info.terms = append(info.terms, tok.Term)
+		}
+		docs[id] = append(docs[id], int32(tok.Pos))
+	}
+	ix.docs[id] = info
+	ix.totalLen += int64(info.length)
+}
+
+// Remove deletes id from the index and reports whether it was present.
+func (ix *Index) Remove(id int64) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.removeLocked(id)
+}
+
+func (ix *Index) removeLocked(id int64) bool {
+	info, ok := ix.docs[id]
+	if !ok {
+		return false
+	}
+	for _, term := range info.terms {
+		docs := ix.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.docs, id)
+	ix.totalLen -= int64(info.length)
+	return true
+}
+
+// Contains reports whether id is indexed.
+func (ix *Index) Contains(id int64) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	_, ok := ix.docs[id]
+	return ok
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search runs query and returns up to k matching IDs ordered by descending
+// BM25 score (ties by ascending ID). A k <= 0 returns every match.
+func (ix *Index) Search(query string, k int) ([]int64, []float64, error) {
+	n, err := parseQuery(query, ix.analyzer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n == nil {
+		return []int64{}, []float64{}, nil
+	}
+
+	ix.mu.RLock()
+	scores := n.eval(ix)
+	ix.mu.RUnlock()
+
+	ids := make([]int64, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if k > 0 && len(ids) > k {
+		ids = ids[:k]
+	}
+	out := make([]float64, len(ids))
+	for i, id := range ids {
+		out[i] = scores[id]
+	}
+	return ids, out, nil
+}
+
+func (ix *Index) idf(df int) float64 {
+	n := float64(len(ix.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+func (ix *Index) tfNorm(tf float64, docLen int) float64 {
+	avg := 1.0
+	if len(ix.docs) > 0 && ix.totalLen > 0 {
+		avg = float64(ix.totalLen) / float64(len(ix.docs))
+	}
+	return tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(docLen)/avg))
+}
+
+// Save writes the index to path atomically (write to a temporary file, sync,
+// rename). The layout, with varint integers, is
+//
+//	magic | analyzer | docCount | (id, length)* | termCount | (term, docCount, (id, posCount, posDelta*)*)*
+func (ix *Index) Save(path string) error {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create index file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	putU := func(v uint64) { w.Write(buf[:binary.PutUvarint(buf, v)]) }
+	putI := func(v int64) { w.Write(buf[:binary.PutVarint(buf, v)]) }
+	putS := func(s string) { putU(uint64(len(s))); w.WriteString(s) }
+
+	w.WriteString(snapshotMagic)
+	putS(ix.analyzer.Name())
+
+	ids := make([]int64, 0, len(ix.docs))
+	for id := range ix.docs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	putU(uint64(len(ids)))
+	for _, id := range ids {
+		putI(id)
+		putU(uint64(ix.docs[id].length))
+	}
+
+	terms := make([]string, 0, len(ix.postings))
+	for term := range ix.postings {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	putU(uint64(len(terms)))
+	for _, term := range terms {
+		docs := ix.postings[term]
+		putS(term)
+		putU(uint64(len(docs)))
+		for id, positions := range docs {
+			putI(id)
+			putU(uint64(len(positions)))
+			prev := int32(0)
+			for _, p := range positions {
+				putU(uint64(p - prev))
+				prev = p
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write index file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync index file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads an index saved with Save. The analyzer recorded in the file is
+// used, so queries analyze text the same way documents were.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, errors.New("not a text index file")
+	}
+
+	var readErr error
+	getU := func() uint64 {
+		v, err := binary.ReadUvarint(r)
+		if err != nil && readErr == nil {
+			readErr = err
+		}
+		return v
+	}
+	getI := func() int64 {
+		v, err := binary.ReadVarint(r)
+		if err != nil && readErr == nil {
+			readErr = err
+		}
+		return v
+	}
+	getS := func() string {
+		n := getU()
+		if readErr != nil || n > 1<<20 {
+			if readErr == nil {
+				readErr = errors.New("string too long")
+			}
+			return ""
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil && readErr == nil {
+			readErr = err
+		}
+		return string(b)
+	}
+
+	analyzer, err := NewAnalyzer(getS())
+	if readErr != nil {
+		return nil, fmt.Errorf("read text index: %w", readErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ix := New(analyzer)
+
+	for n := getU(); n > 0 && readErr == nil; n-- {
+		id := getI()
+		length := int(getU())
+		ix.docs[id] = docInfo{length: length}
+		ix.totalLen += int64(length)
+	}
+	for n := getU(); n > 0 && readErr == nil; n-- {
+		term := getS()
+		docs := make(map[int64][]int32)
+		for d := getU(); d > 0 && readErr == nil; d-- {
+			id := getI()
+			count := getU()
+			if count > 1<<24 {
+				readErr = errors.New("corrupt posting list")
+				break
+			}
+			positions := make([]int32, count)
+			prev := int32(0)
+			for i := range positions {
+				prev += int32(getU())
+				positions[i] = prev
+			}
+			docs[id] = positions
+			info := ix.docs[id]
+			info.terms = append(info.terms, term)
+			ix.docs[id] = info
+		}
+		ix.postings[term] = docs
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("read text index: %w", readErr)
+	}
+	return ix, nil
+}
@@ -0,0 +1,143 @@
+package textindex
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestIndex(t *testing.T, analyzer string) *Index {
+	t.Helper()
+	a, err := NewAnalyzer(analyzer)
+	if err != nil {
+		t.Fatalf("NewAnalyzer failed: %v", err)
+	}
+	ix := New(a)
+	ix.Add(1, "Wireless noise cancelling headphones with Bluetooth 5.0")
+	ix.Add(2, "Wired headphones, noise isolating, USB-C cable")
+	ix.Add(3, "Bluetooth speaker with deep bass")
+	ix.Add(4, "Cancelling the noise: a guide to wireless audio")
+	return ix
+}
+
+func search(t *testing.T, ix *Index, q string) []int64 {
+	t.Helper()
+	ids, scores, err := ix.Search(q, 0)
+	if err != nil {
+		t.Fatalf("Search(%q) failed: %v", q, err)
+	}
+	for i := 1; i < len(scores); i++ {
+		if scores[i] > scores[i-1] {
+			t.Fatalf("Search(%q) results not sorted by score: %v", q, scores)
+		}
+	}
+	return ids
+}
+
+func TestSearchQueries(t *testing.T) {
+	ix := newTestIndex(t, "standard")
+
+	cases := []struct {
+		query string
+		want  []int64
+	}{
+		{"bluetooth", []int64{3, 1}},
+		{`"noise cancelling"`, []int64{1}},
+		{`"cancelling noise"`, []int64{}},
+		{"headphones AND bluetooth", []int64{1}},
+		{"headphones -wired", []int64{1}},
+		{"headphones NOT wired", []int64{1}},
+		{"+wireless headphones", []int64{1, 4}},
+		{"speaker OR (wired AND cable)", []int64{2, 3}},
+		{"usb-c", []int64{2}},
+		{"missing", []int64{}},
+	}
+	for _, c := range cases {
+		got := search(t, ix, c.query)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Search(%q) = %v, want %v", c.query, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{`"open phrase`, "(a OR b", "a AND", ")"} {
+		if _, _, err := ix.Search(bad, 0); err == nil {
+			t.Errorf("Search(%q) expected a parse error", bad)
+		}
+	}
+}
+
+func TestBM25Ranking(t *testing.T) {
+	a, _ := NewAnalyzer("standard")
+	ix := New(a)
+	ix.Add(1, "apple banana cherry date elderberry fig grape")
+	ix.Add(2, "apple apple apple")
+	ix.Add(3, "banana")
+
+	// Higher term frequency in a shorter document ranks first
+	if got := search(t, ix, "apple"); !reflect.DeepEqual(got, []int64{2, 1}) {
+		t.Fatalf("unexpected ranking %v", got)
+	}
+	// Rarer terms weigh more
+	if got := search(t, ix, "cherry banana"); got[0] != 1 {
+		t.Fatalf("expected doc 1 first, got %v", got)
+	}
+
+	ix.Add(2, "cherry")
+	if got := search(t, ix, "apple"); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("expected replaced document to drop out, got %v", got)
+	}
+	if !ix.Remove(3) || ix.Remove(3) || ix.Len() != 2 {
+		t.Fatal("unexpected Remove result")
+	}
+}
+
+func TestEnglishAnalyzer(t *testing.T) {
+	a, _ := NewAnalyzer("english")
+	tokens := a.Analyze("The Batteries of the phones")
+	want := []Token{{Term: "battery", Pos: 1}, {Term: "phone", Pos: 4}}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("Analyze = %+v, want %+v", tokens, want)
+	}
+
+	ix := New(a)
+	ix.Add(1, "batteries for phones")
+	if got := search(t, ix, `"battery for phone"`); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("expected phrase across a stop word to match, got %v", got)
+	}
+	if _, err := NewAnalyzer("klingon"); err == nil {
+		t.Fatal("expected an error for an unknown analyzer")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	ix := newTestIndex(t, "english")
+	path := filepath.Join(t.TempDir(), "text_index.dat")
+	if err := ix.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("temporary file left behind")
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Analyzer().Name() != "english" || loaded.Len() != ix.Len() {
+		t.Fatalf("loaded index differs: analyzer %s, %d docs", loaded.Analyzer().Name(), loaded.Len())
+	}
+	for _, q := range []string{"headphones", `"noise cancelling"`, "wireless -guide"} {
+		wantIDs, wantScores, _ := ix.Search(q, 0)
+		gotIDs, gotScores, _ := loaded.Search(q, 0)
+		if !reflect.DeepEqual(gotIDs, wantIDs) || !reflect.DeepEqual(gotScores, wantScores) {
+			t.Fatalf("Search(%q) after load = %v %v, want %v %v", q, gotIDs, gotScores, wantIDs, wantScores)
+		}
+	}
+
+	// Removing a loaded document must clean up all of its postings
+	loaded.Remove(1)
+	if got := search(t, loaded, "bluetooth"); !reflect.DeepEqual(got, []int64{3}) {
+		t.Fatalf("unexpected results after remove: %v", got)
+	}
+}
@@ -0,0 +1,325 @@
+package textindex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Query syntax:
+//
+//	wireless headphones          either term (scores add up)
+//	"noise cancelling"           phrase
+//	+wireless -wired             required / prohibited clause
+//	wireless AND (bluetooth OR "usb c") NOT refurbished
+//
+// AND binds tighter than OR; juxtaposed clauses are combined with OR. Terms
+// are analyzed with the index analyzer, and a term that analyzes to several
+// tokens is matched as a phrase.
+
+type node interface {
+	// eval returns the score of every matching document
+	eval(ix *Index) map[int64]float64
+}
+
+type termNode struct {
+	term string
+}
+
+type phraseNode struct {
+	tokens []Token
+}
+
+type boolNode struct {
+	must    []node
+	should  []node
+	mustNot []node
+}
+
+type occur int
+
+const (
+	occurShould occur = iota
+	occurMust
+	occurMustNot
+)
+
+type queryParser struct {
+	tokens   []string
+	pos      int
+	analyzer *Analyzer
+}
+
+func parseQuery(q string, analyzer *Analyzer) (node, error) {
+	tokens, err := lexQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, analyzer: analyzer}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid query: unexpected '%s'", p.tokens[p.pos])
+	}
+	return n, nil
+}
+
+// lexQuery splits a query into words, quoted phrases (kept with their
+// quotes), parentheses and +/- prefixes.
+func lexQuery(q string) ([]string, error) {
+	var tokens []string
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, string(r))
+			i++
+		case (r == '+' || r == '-') && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, string(r))
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("invalid query: unterminated phrase")
+			}
+			tokens = append(tokens, string(runes[i:end+1]))
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '(' && runes[end] != ')' && runes[end] != '"' {
+				end++
+			}
+			tokens = append(tokens, string(runes[i:end]))
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) parseOr() (node, error) {
+	b := &boolNode{}
+	for p.pos < len(p.tokens) && p.peek() != ")" {
+		if p.peek() == "OR" {
+			p.pos++
+			continue
+		}
+		n, occ, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		b.add(n, occ)
+	}
+	if len(b.must)+len(b.should)+len(b.mustNot) == 0 {
+		return nil, nil
+	}
+	if len(b.must) == 0 && len(b.mustNot) == 0 && len(b.should) == 1 {
+		return b.should[0], nil
+	}
+	return b, nil
+}
+
+func (p *queryParser) parseAnd() (node, occur, error) {
+	first, occ, err := p.parseUnary()
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.peek() != "AND" && p.peek() != "NOT" {
+		return first, occ, nil
+	}
+
+	// "a AND b", "a NOT b" and "a AND NOT b" form one required group
+	b := &boolNode{}
+	if occ == occurShould {
+		occ = occurMust
+	}
+	b.add(first, occ)
+	for p.peek() == "AND" || p.peek() == "NOT" {
+		if p.peek() == "AND" {
+			p.pos++
+		}
+		n, occ, err := p.parseUnary()
+		if err != nil {
+			return nil, 0, err
+		}
+		if occ == occurShould {
+			occ = occurMust
+		}
+		b.add(n, occ)
+	}
+	return b, occurShould, nil
+}
+
+func (p *queryParser) parseUnary() (node, occur, error) {
+	occ := occurShould
+	switch p.peek() {
+	case "NOT", "-":
+		occ = occurMustNot
+		p.pos++
+	case "+":
+		occ = occurMust
+		p.pos++
+	}
+	n, err := p.parsePrimary()
+	return n, occ, err
+}
+
+func (p *queryParser) parsePrimary() (node, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return nil, errors.New("invalid query: unexpected end of query")
+	case tok == "(":
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("invalid query: missing ')'")
+		}
+		p.pos++
+		return n, nil
+	case tok == ")" || tok == "AND" || tok == "OR" || tok == "NOT":
+		return nil, fmt.Errorf("invalid query: unexpected '%s'", tok)
+	}
+	p.pos++
+
+	text := tok
+	if strings.HasPrefix(tok, `"`) {
+		text = tok[1 : len(tok)-1]
+	}
+	tokens := p.analyzer.Analyze(text)
+	switch len(tokens) {
+	case 0:
+		// Only stop words or punctuation; the clause matches nothing and is dropped
+		return nil, nil
+	case 1:
+		return termNode{term: tokens[0].Term}, nil
+	}
+	return phraseNode{tokens: tokens}, nil
+}
+
+func (b *boolNode) add(n node, occ occur) {
+	if n == nil {
+		return
+	}
+	switch occ {
+	case occurMust:
+		b.must = append(b.must, n)
+	case occurMustNot:
+		b.mustNot = append(b.mustNot, n)
+	default:
+		b.should = append(b.should, n)
+	}
+}
+
+func (t termNode) eval(ix *Index) map[int64]float64 {
+	postings := ix.postings[t.term]
+	scores := make(map[int64]float64, len(postings))
+	idf := ix.idf(len(postings))
+	for doc, positions := range postings {
+		scores[doc] = idf * ix.tfNorm(float64(len(positions)), ix.docs[doc].length)
+	}
+	return scores
+}
+
+func (ph phraseNode) eval(ix *Index) map[int64]float64 {
+	lists := make([]map[int64][]int32, len(ph.tokens))
+	idfSum := 0.0
+	for i, tok := range ph.tokens {
+		lists[i] = ix.postings[tok.Term]
+		if len(lists[i]) == 0 {
+			return map[int64]float64{}
+		}
+		idfSum += ix.idf(len(lists[i]))
+	}
+
+	scores := make(map[int64]float64)
+	for doc, firstPositions := range lists[0] {
+		freq := 0
+		for _, start := range firstPositions {
+			matched := true
+			for i := 1; i < len(ph.tokens) && matched; i++ {
+				want := start + int32(ph.tokens[i].Pos-ph.tokens[0].Pos)
+				matched = containsPosition(lists[i][doc], want)
+			}
+			if matched {
+				freq++
+			}
+		}
+		if freq > 0 {
+			scores[doc] = idfSum * ix.tfNorm(float64(freq), ix.docs[doc].length)
+		}
+	}
+	return scores
+}
+
+func (b *boolNode) eval(ix *Index) map[int64]float64 {
+	var scores map[int64]float64
+	if len(b.must) > 0 {
+		for i, n := range b.must {
+			res := n.eval(ix)
+			if i == 0 {
+				scores = res
+				continue
+			}
+			for doc, s := range scores {
+				if extra, ok := res[doc]; ok {
+					scores[doc] = s + extra
+				} else {
+					delete(scores, doc)
+				}
+			}
+		}
+		for _, n := range b.should {
+			for doc, s := range n.eval(ix) {
+				if _, ok := scores[doc]; ok {
+					scores[doc] += s
+				}
+			}
+		}
+	} else {
+		scores = make(map[int64]float64)
+		for _, n := range b.should {
+			for doc, s := range n.eval(ix) {
+				scores[doc] += s
+			}
+		}
+	}
+	for _, n := range b.mustNot {
+		for doc := range n.eval(ix) {
+			delete(scores, doc)
+		}
+	}
+	return scores
+}
+
+// containsPosition reports whether the sorted positions include want.
+func containsPosition(positions []int32, want int32) bool {
+	lo, hi := 0, len(positions)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if positions[mid] < want {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo < len(positions) && positions[lo] == want
+}
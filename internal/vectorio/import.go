@@ -0,0 +1,92 @@
+package vectorio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultBatchSize is how many vectors an import sends per insert.
+const DefaultBatchSize = 1000
+
+// maxReportedErrors caps the rejected-row messages kept in a Progress.
+const maxReportedErrors = 20
+
+// Progress counts the rows of an import so far.
+type Progress struct {
+	Rows     int      `json:"rows"`
+	Imported int      `json:"imported"`
+	Rejected int      `json:"rejected"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+func (p *Progress) reject(err error) {
+	p.Rejected++
+	if len(p.Errors) < maxReportedErrors {
+		p.Errors = append(p.Errors, err.Error())
+	}
+}
+
+// Import streams the records of r to insert in batches of batchSize. Rows
+// that cannot be read, whose dimension differs from dim or that check, when
+// set, refuses are rejected and counted; dim 0 takes the dimension of the
+// first valid row. progress, when set, is called after every batch. A
+// failing insert stops the import.
+func Import(r Reader, dim, batchSize int, check func(Record) error, insert func([]Record) error, progress func(Progress)) (Progress, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	var p Progress
+	batch := make([]Record, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := insert(batch); err != nil {
+			return fmt.Errorf("insert rows %d-%d: %w", batch[0].Row, batch[len(batch)-1].Row, err)
+		}
+		p.Imported += len(batch)
+		batch = batch[:0]
+		if progress != nil {
+			progress(p)
+		}
+		return nil
+	}
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			p.Rows++
+			p.reject(rowErr)
+			continue
+		}
+		if err != nil {
+			return p, err
+		}
+		p.Rows++
+		if dim == 0 {
+			dim = len(rec.Vector)
+		}
+		if len(rec.Vector) != dim {
+			p.reject(&RowError{Row: rec.Row, Err: fmt.Errorf("dimension %d, expected %d", len(rec.Vector), dim)})
+			continue
+		}
+		if check != nil {
+			if err := check(rec); err != nil {
+				p.reject(&RowError{Row: rec.Row, Err: err})
+				continue
+			}
+		}
+		batch = append(batch, rec)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return p, err
+			}
+		}
+	}
+	return p, flush()
+}
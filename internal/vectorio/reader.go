@@ -0,0 +1,359 @@
+// Package vectorio reads vector files (.fvecs, .bvecs, NumPy .npy and JSONL)
+// for bulk loading into vector spaces.
+package vectorio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shibudb.org/shibudb-server/internal/float16"
+)
+
+// Formats lists the supported file formats.
+var Formats = []string{"fvecs", "bvecs", "npy", "jsonl"}
+
+// Record is one vector read from a file. Files without IDs (.fvecs, .bvecs,
+// .npy) number their rows from the reader's first ID. Key is set instead of
+// ID when a JSONL row has a string ID.
+type Record struct {
+	Row     int
+	ID      int64
+	Key     string
+	Vector  []float32
+	Payload string
+	Parent  string
+}
+
+// RowError reports a row that could not be read; reading can go on with the
+// next row.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// Reader reads records one at a time. Next returns io.EOF at the end of the
+// file and a *RowError for a rejected row; any other error ends the file.
+type Reader interface {
+	Next() (Record, error)
+	Close() error
+}
+
+// Open opens a vector file. An empty format is taken from the file
+// extension; firstID numbers the rows of files without IDs.
+func Open(path, format string, firstID int64) (Reader, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if format == "json" || format == "ndjson" {
+			format = "jsonl"
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(f, 1<<20)
+	switch format {
+	case "fvecs":
+		return &vecsReader{f: f, r: br, elemSize: 4, nextID: firstID}, nil
+	case "bvecs":
+		return &vecsReader{f: f, r: br, elemSize: 1, nextID: firstID}, nil
+	case "npy":
+		r, err := newNpyReader(f, br, firstID)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return r, nil
+	case "jsonl":
+		sc := bufio.NewScanner(br)
+		sc.Buffer(make([]byte, 1<<20), 64<<20)
+		return &jsonlReader{f: f, sc: sc}, nil
+	}
+	f.Close()
+	return nil, fmt.Errorf("unsupported vector file format '%s' (use %s)", format, strings.Join(Formats, ", "))
+}
+
+// checkFinite rejects vectors with NaN or infinite values.
+func checkFinite(v []float32) error {
+	for i, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return fmt.Errorf("value %d is not a finite number", i)
+		}
+	}
+	return nil
+}
+
+// vecsReader reads the TEXMEX .fvecs/.bvecs layout: every vector is an int32
+// dimension followed by that many float32 (fvecs) or uint8 (bvecs) values.
+type vecsReader struct {
+	f        *os.File
+	r        *bufio.Reader
+	elemSize int
+	row      int
+	nextID   int64
+}
+
+func (vr *vecsReader) Next() (Record, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(vr.r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Record{}, fmt.Errorf("row %d: truncated vector header", vr.row)
+		}
+		return Record{}, err
+	}
+	dim := int(int32(binary.LittleEndian.Uint32(head[:])))
+	if dim <= 0 || dim > 1<<20 {
+		return Record{}, fmt.Errorf("row %d: invalid dimension %d", vr.row, dim)
+	}
+	buf := make([]byte, dim*vr.elemSize)
+	if _, err := io.ReadFull(vr.r, buf); err != nil {
+		return Record{}, fmt.Errorf("row %d: truncated vector", vr.row)
+	}
+	vec := make([]float32, dim)
+	for i := range vec {
+		if vr.elemSize == 1 {
+			vec[i] = float32(buf[i])
+		} else {
+			vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+		}
+	}
+	rec := Record{Row: vr.row, ID: vr.nextID, Vector: vec}
+	vr.row++
+	vr.nextID++
+	if err := checkFinite(vec); err != nil {
+		return rec, &RowError{Row: rec.Row, Err: err}
+	}
+	return rec, nil
+}
+
+func (vr *vecsReader) Close() error {
+	return vr.f.Close()
+}
+
+var (
+	npyDescr   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// npyReader reads a two-dimensional, C-ordered NumPy array of float32,
+// float64, float16, int8 or uint8 values, one vector per row.
+type npyReader struct {
+	f        *os.File
+	r        *bufio.Reader
+	order    binary.ByteOrder
+	kind     byte // 'f', 'i' or 'u'
+	size     int
+	rows     int
+	dim      int
+	row      int
+	nextID   int64
+	rowBytes []byte
+}
+
+func newNpyReader(f *os.File, r *bufio.Reader, firstID int64) (*npyReader, error) {
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic[:6]) != "\x93NUMPY" {
+		return nil, errors.New("not a NumPy .npy file")
+	}
+	var headerLen int
+	switch magic[6] {
+	case 1:
+		var n [2]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return nil, err
+		}
+		headerLen = int(binary.LittleEndian.Uint16(n[:]))
+	case 2, 3:
+		var n [4]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return nil, err
+		}
+		headerLen = int(binary.LittleEndian.Uint32(n[:]))
+	default:
+		return nil, fmt.Errorf("unsupported .npy version %d", magic[6])
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.New("truncated .npy header")
+	}
+
+	m := npyDescr.FindSubmatch(header)
+	if m == nil || len(m[1]) < 3 {
+		return nil, errors.New(".npy header has no dtype")
+	}
+	descr := string(m[1])
+	nr := &npyReader{f: f, r: r, order: binary.LittleEndian, kind: descr[1], nextID: firstID}
+	if descr[0] == '>' {
+		nr.order = binary.BigEndian
+	}
+	nr.size, _ = strconv.Atoi(descr[2:])
+	switch {
+	case nr.kind == 'f' && (nr.size == 2 || nr.size == 4 || nr.size == 8):
+	case (nr.kind == 'i' || nr.kind == 'u') && nr.size == 1:
+	default:
+		return nil, fmt.Errorf("unsupported .npy dtype '%s' (use float16, float32, float64, int8 or uint8)", descr)
+	}
+	if m := npyFortran.FindSubmatch(header); m != nil && string(m[1]) == "True" {
+		return nil, errors.New("Fortran-ordered .npy arrays are not supported")
+	}
+	m = npyShape.FindSubmatch(header)
+	if m == nil {
+		return nil, errors.New(".npy header has no shape")
+	}
+	var shape []int
+	for _, s := range strings.Split(string(m[1]), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid .npy shape '%s'", m[1])
+			}
+			shape = append(shape, n)
+		}
+	}
+	if len(shape) != 2 || shape[1] <= 0 {
+		return nil, fmt.Errorf(".npy array must have shape (rows, dimension), got (%s)", m[1])
+	}
+	nr.rows, nr.dim = shape[0], shape[1]
+	nr.rowBytes = make([]byte, nr.dim*nr.size)
+	return nr, nil
+}
+
+func (nr *npyReader) Next() (Record, error) {
+	if nr.row >= nr.rows {
+		return Record{}, io.EOF
+	}
+	if _, err := io.ReadFull(nr.r, nr.rowBytes); err != nil {
+		return Record{}, fmt.Errorf("row %d: truncated .npy data", nr.row)
+	}
+	vec := make([]float32, nr.dim)
+	b := nr.rowBytes
+	for i := range vec {
+		switch {
+		case nr.kind == 'u':
+			vec[i] = float32(b[i])
+		case nr.kind == 'i':
+			vec[i] = float32(int8(b[i]))
+		case nr.size == 2:
+			vec[i] = float16.ToFloat32(nr.order.Uint16(b[2*i:]))
+		case nr.size == 4:
+			vec[i] = math.Float32frombits(nr.order.Uint32(b[4*i:]))
+		default:
+			vec[i] = float32(math.Float64frombits(nr.order.Uint64(b[8*i:])))
+		}
+	}
+	rec := Record{Row: nr.row, ID: nr.nextID, Vector: vec}
+	nr.row++
+	nr.nextID++
+	if err := checkFinite(vec); err != nil {
+		return rec, &RowError{Row: rec.Row, Err: err}
+	}
+	return rec, nil
+}
+
+func (nr *npyReader) Close() error {
+	return nr.f.Close()
+}
+
+// jsonlReader reads one JSON object per line:
+//
+//	{"id": 7, "vector": [0.1, 0.2], "payload": {"lang": "en"}}
+//
+// The ID may be a number or a string key; the payload is optional.
+type jsonlReader struct {
+	f   *os.File
+	sc  *bufio.Scanner
+	row int
+}
+
+type jsonlRow struct {
+	ID      json.RawMessage `json:"id"`
+	Vector  []float32       `json:"vector"`
+	Payload json.RawMessage `json:"payload"`
+	Parent  string          `json:"parent"`
+}
+
+// WriteJSONL writes rec as a JSON lines row that the jsonl reader reads
+// back: a record with a string key gets it as its id.
+func WriteJSONL(w io.Writer, rec Record) error {
+	row := struct {
+		ID      interface{}     `json:"id"`
+		Vector  []float32       `json:"vector"`
+		Payload json.RawMessage `json:"payload,omitempty"`
+		Parent  string          `json:"parent,omitempty"`
+	}{ID: rec.ID, Vector: rec.Vector, Parent: rec.Parent}
+	if rec.Key != "" {
+		row.ID = rec.Key
+	}
+	if rec.Payload != "" {
+		row.Payload = json.RawMessage(rec.Payload)
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (jr *jsonlReader) Next() (Record, error) {
+	for jr.sc.Scan() {
+		line := strings.TrimSpace(jr.sc.Text())
+		row := jr.row
+		jr.row++
+		if line == "" {
+			continue
+		}
+		rec := Record{Row: row}
+		var r jsonlRow
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return rec, &RowError{Row: row, Err: fmt.Errorf("invalid JSON: %v", err)}
+		}
+		if len(r.ID) == 0 {
+			return rec, &RowError{Row: row, Err: errors.New("missing id")}
+		}
+		if err := json.Unmarshal(r.ID, &rec.ID); err != nil {
+			if err := json.Unmarshal(r.ID, &rec.Key); err != nil || rec.Key == "" {
+				return rec, &RowError{Row: row, Err: errors.New("id must be an integer or a non-empty string")}
+			}
+		}
+		if len(r.Vector) == 0 {
+			return rec, &RowError{Row: row, Err: errors.New("missing vector")}
+		}
+		rec.Vector = r.Vector
+		if err := checkFinite(rec.Vector); err != nil {
+			return rec, &RowError{Row: row, Err: err}
+		}
+		if len(r.Payload) > 0 && string(r.Payload) != "null" {
+			var obj map[string]interface{}
+			if err := json.Unmarshal(r.Payload, &obj); err != nil {
+				return rec, &RowError{Row: row, Err: errors.New("payload must be a JSON object")}
+			}
+			rec.Payload = string(r.Payload)
+		}
+		rec.Parent = r.Parent
+		return rec, nil
+	}
+	if err := jr.sc.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func (jr *jsonlReader) Close() error {
+	return jr.f.Close()
+}
@@ -0,0 +1,241 @@
+package vectorio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, path, format string, firstID int64) ([]Record, []int) {
+	t.Helper()
+	r, err := Open(path, format, firstID)
+	if err != nil {
+		t.Fatalf("Open(%s) failed: %v", path, err)
+	}
+	defer r.Close()
+	var recs []Record
+	var rejected []int
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return recs, rejected
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rejected = append(rejected, rowErr.Row)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+}
+
+func TestReadVecs(t *testing.T) {
+	dir := t.TempDir()
+
+	var fvecs []byte
+	for _, v := range [][]float32{{1, 2, 3}, {4, float32(math.NaN()), 6}, {7, 8, 9}} {
+		fvecs = binary.LittleEndian.AppendUint32(fvecs, uint32(len(v)))
+		for _, x := range v {
+			fvecs = binary.LittleEndian.AppendUint32(fvecs, math.Float32bits(x))
+		}
+	}
+	path := filepath.Join(dir, "base.fvecs")
+	os.WriteFile(path, fvecs, 0644)
+	recs, rejected := readAll(t, path, "", 100)
+	if len(recs) != 2 || recs[0].ID != 100 || recs[1].ID != 102 || !reflect.DeepEqual(recs[1].Vector, []float32{7, 8, 9}) {
+		t.Fatalf("fvecs records = %+v", recs)
+	}
+	if !reflect.DeepEqual(rejected, []int{1}) {
+		t.Fatalf("fvecs rejected rows = %v, want [1]", rejected)
+	}
+
+	bvecs := []byte{2, 0, 0, 0, 0, 255, 2, 0, 0, 0, 7, 8}
+	path = filepath.Join(dir, "base.bvecs")
+	os.WriteFile(path, bvecs, 0644)
+	recs, _ = readAll(t, path, "", 0)
+	if len(recs) != 2 || !reflect.DeepEqual(recs[0].Vector, []float32{0, 255}) || recs[1].ID != 1 {
+		t.Fatalf("bvecs records = %+v", recs)
+	}
+}
+
+func writeNpy(t *testing.T, path, descr, shape string, data []byte) {
+	t.Helper()
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, shape)
+	// The header is padded so the data starts 64-byte aligned
+	pad := 64 - (10+len(header)+1)%64
+	header += strings.Repeat(" ", pad) + "\n"
+	buf := []byte("\x93NUMPY\x01\x00")
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(header)))
+	buf = append(buf, header...)
+	buf = append(buf, data...)
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadNpy(t *testing.T) {
+	dir := t.TempDir()
+
+	var f32 []byte
+	for _, x := range []float32{1, 2, 3, 4, 5, 6} {
+		f32 = binary.LittleEndian.AppendUint32(f32, math.Float32bits(x))
+	}
+	path := filepath.Join(dir, "emb.npy")
+	writeNpy(t, path, "<f4", "3, 2", f32)
+	recs, _ := readAll(t, path, "", 0)
+	if len(recs) != 3 || !reflect.DeepEqual(recs[2].Vector, []float32{5, 6}) || recs[2].ID != 2 {
+		t.Fatalf("float32 records = %+v", recs)
+	}
+
+	var f64 []byte
+	for _, x := range []float64{0.5, -1.5} {
+		f64 = binary.LittleEndian.AppendUint64(f64, math.Float64bits(x))
+	}
+	writeNpy(t, path, "<f8", "1, 2", f64)
+	recs, _ = readAll(t, path, "npy", 0)
+	if len(recs) != 1 || !reflect.DeepEqual(recs[0].Vector, []float32{0.5, -1.5}) {
+		t.Fatalf("float64 records = %+v", recs)
+	}
+
+	// 1.0, -2.0 and 2^-24 (the smallest subnormal) as float16
+	writeNpy(t, path, "<f2", "1, 3", []byte{0x00, 0x3c, 0x00, 0xc0, 0x01, 0x00})
+	recs, _ = readAll(t, path, "npy", 0)
+	if len(recs) != 1 || !reflect.DeepEqual(recs[0].Vector, []float32{1, -2, float32(math.Ldexp(1, -24))}) {
+		t.Fatalf("float16 records = %+v", recs)
+	}
+
+	writeNpy(t, path, "|i1", "2, 2", []byte{1, 0xff, 3, 4})
+	recs, _ = readAll(t, path, "npy", 0)
+	if len(recs) != 2 || !reflect.DeepEqual(recs[0].Vector, []float32{1, -1}) {
+		t.Fatalf("int8 records = %+v", recs)
+	}
+
+	for _, c := range []struct{ descr, shape string }{{"<i8", "1, 2"}, {"<f4", "4"}} {
+		writeNpy(t, path, c.descr, c.shape, make([]byte, 16))
+		if _, err := Open(path, "", 0); err == nil {
+			t.Errorf("Open accepted dtype %s shape (%s)", c.descr, c.shape)
+		}
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.jsonl")
+	os.WriteFile(path, []byte(`{"id": 1, "vector": [0.1, 0.2], "payload": {"lang": "en"}}
+{"id": "doc-a", "vector": [0.3, 0.4]}
+
+not json
+{"id": 3}
+{"id": 4, "vector": [1, 2], "payload": [1]}
+{"id": 5, "vector": [5, 6], "payload": null}
+`), 0644)
+	recs, rejected := readAll(t, path, "", 0)
+	if len(recs) != 3 {
+		t.Fatalf("records = %+v", recs)
+	}
+	if recs[0].ID != 1 || recs[0].Payload != `{"lang": "en"}` {
+		t.Errorf("first record = %+v", recs[0])
+	}
+	if recs[1].Key != "doc-a" || recs[2].ID != 5 || recs[2].Payload != "" {
+		t.Errorf("records = %+v", recs)
+	}
+	if !reflect.DeepEqual(rejected, []int{3, 4, 5}) {
+		t.Errorf("rejected rows = %v, want [3 4 5]", rejected)
+	}
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	want := []Record{
+		{Row: 0, ID: 7, Vector: []float32{0.5, -1}, Payload: `{"lang":"en"}`, Parent: "doc-7"},
+		{Row: 1, Key: "chunk-a", Vector: []float32{2, 3}, Parent: "doc-a"},
+		{Row: 2, ID: -3, Vector: []float32{4, 5}},
+	}
+	var buf strings.Builder
+	for _, rec := range want {
+		if err := WriteJSONL(&buf, rec); err != nil {
+			t.Fatalf("WriteJSONL failed: %v", err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "export.jsonl")
+	os.WriteFile(path, []byte(buf.String()), 0644)
+
+	got, rejected := readAll(t, path, "", 0)
+	if len(rejected) != 0 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("read back %+v (rejected %v), want %+v", got, rejected, want)
+	}
+}
+
+func TestImportBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows.jsonl")
+	var sb strings.Builder
+	for i := 0; i < 7; i++ {
+		if i == 4 {
+			sb.WriteString(`{"id": 4, "vector": [1, 2, 3]}` + "\n")
+			continue
+		}
+		fmt.Fprintf(&sb, `{"id": %d, "vector": [%d, 1]}`+"\n", i, i)
+	}
+	sb.WriteString("{bad\n")
+	os.WriteFile(path, []byte(sb.String()), 0644)
+
+	r, err := Open(path, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var batches [][]int64
+	var calls int
+	p, err := Import(r, 2, 4, nil, func(batch []Record) error {
+		var ids []int64
+		for _, rec := range batch {
+			ids = append(ids, rec.ID)
+		}
+		batches = append(batches, ids)
+		return nil
+	}, func(Progress) { calls++ })
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !reflect.DeepEqual(batches, [][]int64{{0, 1, 2, 3}, {5, 6}}) || calls != 2 {
+		t.Fatalf("batches = %v, progress calls = %d", batches, calls)
+	}
+	if p.Rows != 8 || p.Imported != 6 || p.Rejected != 2 || len(p.Errors) != 2 {
+		t.Fatalf("progress = %+v", p)
+	}
+
+	// Rows refused by check are rejected without failing their batch
+	r3, _ := Open(path, "", 0)
+	defer r3.Close()
+	var inserted []int64
+	p, err = Import(r3, 2, 4, func(rec Record) error {
+		if rec.ID%2 == 1 {
+			return errors.New("odd ID")
+		}
+		return nil
+	}, func(batch []Record) error {
+		for _, rec := range batch {
+			inserted = append(inserted, rec.ID)
+		}
+		return nil
+	}, nil)
+	if err != nil || !reflect.DeepEqual(inserted, []int64{0, 2, 6}) || p.Rejected != 5 || p.Imported != 3 {
+		t.Fatalf("inserted %v, progress %+v (%v)", inserted, p, err)
+	}
+
+	// A failing insert stops the import
+	r2, _ := Open(path, "", 0)
+	defer r2.Close()
+	_, err = Import(r2, 0, 3, nil, func([]Record) error { return errors.New("space is full") }, nil)
+	if err == nil || !strings.Contains(err.Error(), "space is full") {
+		t.Fatalf("Import error = %v", err)
+	}
+}
@@ -10,6 +10,9 @@ import (
 type WAL struct {
 	file *os.File
 	lock sync.Mutex
+	// offsets of the commit flags of entries written since the last
+	// MarkCommitted or Clear
+	pending []int64
 }
 
 func OpenWAL(filename string) (*WAL, error) {
@@ -37,15 +40,54 @@ func (w *WAL) WriteEntry(key, value string) error {
 	copy(buf[9:9+len(keyBytes)], keyBytes)
 	copy(buf[9+len(keyBytes):], valBytes)
 
-	_, err := w.file.Write(buf)
+	pos, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.Write(buf)
 	if err != nil {
 		return err
 	}
+	w.pending = append(w.pending, pos+8)
 
 	// Force sync to ensure data is written before unlocking
 	return w.file.Sync()
 }
 
+// WriteEntries appends several pending entries with a single write and fsync.
+func (w *WAL) WriteEntries(entries [][2]string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	pos, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	size := 0
+	for _, e := range entries {
+		size += 9 + len(e[0]) + len(e[1])
+	}
+	buf := make([]byte, 0, size)
+	flags := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		flags = append(flags, pos+int64(len(buf))+8)
+		var header [9]byte
+		binary.LittleEndian.PutUint32(header[0:4], uint32(len(e[0])))
+		binary.LittleEndian.PutUint32(header[4:8], uint32(len(e[1])))
+		header[8] = 'P'
+		buf = append(buf, header[:]...)
+		buf = append(buf, e[0]...)
+		buf = append(buf, e[1]...)
+	}
+
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	w.pending = append(w.pending, flags...)
+	return w.file.Sync()
+}
+
 func (w *WAL) WriteDelete(key string) error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
@@ -67,20 +109,24 @@ func (w *WAL) WriteDelete(key string) error {
 	return w.file.Sync()
 }
 
+// MarkCommitted flags every entry written since the previous call as
+// committed, so that Replay skips them.
 func (w *WAL) MarkCommitted() error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
 
-	_, err := w.file.Seek(8, io.SeekStart)
-	if err != nil {
-		return err
+	if len(w.pending) == 0 {
+		return nil
 	}
 
 	commitByte := []byte{'C'}
-	_, err = w.file.Write(commitByte)
-	if err != nil {
-		return err
+	for _, off := range w.pending {
+		// WriteAt leaves the file offset at the end for later appends
+		if _, err := w.file.WriteAt(commitByte, off); err != nil {
+			return err
+		}
 	}
+	w.pending = w.pending[:0]
 
 	return w.file.Sync() // Ensure changes are flushed
 }
@@ -108,8 +154,12 @@ func (w *WAL) Replay() ([][2]string, error) {
 		valSize := binary.LittleEndian.Uint32(header[4:8])
 		commitFlag := header[8]
 
-		if commitFlag == 'C' {
-			continue // Skip already committed transactions
+		if commitFlag == 'C' || commitFlag == 'D' {
+			// Skip already committed transactions and delete markers
+			if _, err := w.file.Seek(int64(keySize)+int64(valSize), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		keyBytes := make([]byte, keySize)
@@ -138,7 +188,13 @@ func (w *WAL) Replay() ([][2]string, error) {
 func (w *WAL) Clear() error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
-	return os.Truncate(w.file.Name(), 0)
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	w.pending = w.pending[:0]
+	// Rewind so the next entry starts at offset 0 instead of leaving a hole
+	_, err := w.file.Seek(0, io.SeekStart)
+	return err
 }
 
 func (w *WAL) ShouldCheckpoint() bool {
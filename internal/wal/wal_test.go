@@ -110,3 +110,161 @@ func TestWAL(t *testing.T) {
 		printWALState("After Clear")
 	})
 }
+
+func TestWALClearRewinds(t *testing.T) {
+	os.Remove("test_wal_clear.db")
+	defer os.Remove("test_wal_clear.db")
+
+	w, err := OpenWAL("test_wal_clear.db")
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.WriteEntry("old-key", "old-value"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+	if err := w.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if err := w.WriteEntry("new", "entry"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+
+	entries, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0][0] != "new" || entries[0][1] != "entry" {
+		t.Fatalf("expected only the entry written after Clear, got %q", entries)
+	}
+}
+
+func TestWALReplaySkipsCommittedAndDeleted(t *testing.T) {
+	os.Remove("test_wal_skip.db")
+	defer os.Remove("test_wal_skip.db")
+
+	w, err := OpenWAL("test_wal_skip.db")
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	defer w.Close()
+
+	// The first entry is committed; its key and value must be skipped too
+	if err := w.WriteEntry("committed-key", "committed-value"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+	if err := w.WriteDelete("gone"); err != nil {
+		t.Fatalf("WriteDelete failed: %v", err)
+	}
+	if err := w.MarkCommitted(); err != nil {
+		t.Fatalf("MarkCommitted failed: %v", err)
+	}
+	if err := w.WriteEntry("pending", "value"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+
+	entries, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0] != [2]string{"pending", "value"} {
+		t.Fatalf("expected only the pending entry, got %q", entries)
+	}
+}
+
+func TestWALAppendsAfterMarkCommitted(t *testing.T) {
+	os.Remove("test_wal_mark.db")
+	defer os.Remove("test_wal_mark.db")
+
+	w, err := OpenWAL("test_wal_mark.db")
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.WriteEntry("first", "1"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+	if err := w.MarkCommitted(); err != nil {
+		t.Fatalf("MarkCommitted failed: %v", err)
+	}
+	// Must be appended rather than written over the first entry
+	if err := w.WriteEntry("second", "2"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+
+	entries, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0] != [2]string{"second", "2"} {
+		t.Fatalf("expected only the entry written after the commit, got %q", entries)
+	}
+}
+
+func TestWALWriteEntries(t *testing.T) {
+	os.Remove("test_wal_batch.db")
+	defer os.Remove("test_wal_batch.db")
+
+	w, err := OpenWAL("test_wal_batch.db")
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	defer w.Close()
+
+	batch := [][2]string{{"a", "1"}, {"b", ""}, {"c", "333"}}
+	if err := w.WriteEntries(batch); err != nil {
+		t.Fatalf("WriteEntries failed: %v", err)
+	}
+	if err := w.WriteEntry("d", "4"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+
+	entries, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	want := append(batch, [2]string{"d", "4"})
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %q", len(want), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Fatalf("entry %d: expected %q, got %q", i, want[i], entries[i])
+		}
+	}
+}
+
+func TestWALMarkCommittedBatch(t *testing.T) {
+	os.Remove("test_wal_commit_batch.db")
+	defer os.Remove("test_wal_commit_batch.db")
+
+	w, err := OpenWAL("test_wal_commit_batch.db")
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	defer w.Close()
+
+	// Every entry of the batch, not just the first one, must be committed
+	if err := w.WriteEntries([][2]string{{"a", "1"}, {"b", "2"}, {"c", "3"}}); err != nil {
+		t.Fatalf("WriteEntries failed: %v", err)
+	}
+	if err := w.WriteEntry("d", "4"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+	if err := w.MarkCommitted(); err != nil {
+		t.Fatalf("MarkCommitted failed: %v", err)
+	}
+	if err := w.WriteEntry("e", "5"); err != nil {
+		t.Fatalf("WriteEntry failed: %v", err)
+	}
+
+	entries, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0] != [2]string{"e", "5"} {
+		t.Fatalf("expected only the entry written after the commit, got %q", entries)
+	}
+}
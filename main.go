@@ -22,13 +22,16 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -37,6 +40,8 @@ import (
 	"github.com/shibudb.org/shibudb-server/cmd/server"
 	"github.com/shibudb.org/shibudb-server/internal/auth"
 	"github.com/shibudb.org/shibudb-server/internal/models"
+	"github.com/shibudb.org/shibudb-server/internal/storage"
+	"github.com/shibudb.org/shibudb-server/internal/vectorio"
 )
 
 const (
@@ -96,7 +101,7 @@ func isServerRunning() (bool, int) {
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: shibudb [start <port> [max_connections] | stop | connect <port> | manager <port> <command> | --version | --help]")
+		fmt.Println("Usage: shibudb [start <port> [max_connections] | stop | connect <port> | manager <port> <command> | eval-recall <port> <space> | --version | --help]")
 		return
 	}
 
@@ -149,6 +154,12 @@ func main() {
 			return
 		}
 		handleManagerCommand(os.Args[2:])
+	case "eval-recall":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: shibudb eval-recall <port> <space> [--k N] [--sample N] [--queries <file>] [--settings \"nprobe=1;nprobe=16\"]")
+			return
+		}
+		evalRecall(os.Args[2], os.Args[3], strings.Join(os.Args[4:], " "))
 	case "--help":
 		printHelp()
 	default:
@@ -171,6 +182,7 @@ Usage:
   sudo shibudb stop                             Stop the ShibuDB background server
   shibudb connect <port>                        Connect to the ShibuDB CLI client
   shibudb manager <port> <command>              Manage connection limits at runtime
+  shibudb eval-recall <port> <space> [options]  Measure recall@k and latency of a vector space's index
   shibudb --version                             Show version information
   shibudb --help                                Show this help message
 
@@ -192,6 +204,13 @@ Manager Commands:
   decrease [amount]         Decrease connection limit by amount (default: 100)
   health                    Check server health
 
+Recall Evaluation Options:
+  --k N                     Neighbours per query (default: 10)
+  --sample N                Stored vectors sampled as queries (default: 100)
+  --queries <file>          Query vectors from an fvecs, bvecs, npy or jsonl file
+  --settings "P;P..."       Search parameter settings to compare, e.g. "nprobe=1;nprobe=16"
+                            (default: an nprobe or efSearch sweep for the index type)
+
 Examples:
   sudo shibudb start 9090              # Start with default 1000 connections
   sudo shibudb start 9090 500          # Start with 500 connection limit
@@ -199,6 +218,7 @@ Examples:
   shibudb manager 9090 limit 2000      # Set limit to 2000
   shibudb manager 9090 increase 500    # Increase limit by 500
   kill -USR1 <pid>                     # Increase limit by 100 via signal
+  shibudb eval-recall 9090 docs --k 10 # Compare recall of the docs space's index
 
 Note: Start and stop commands require sudo privileges.`)
 }
@@ -273,9 +293,23 @@ func connectToServer(port string) {
 		parts := strings.Fields(line)
 
 		var commandsRequiringSpace = map[string]bool{
-			"put":    true,
-			"get":    true,
-			"delete": true,
+			"put":           true,
+			"get":           true,
+			"delete":        true,
+			"create-index":  true,
+			"find":          true,
+			"insert-doc":    true,
+			"get-doc":       true,
+			"update-doc":    true,
+			"delete-doc":    true,
+			"find-docs":     true,
+			"insert-point":  true,
+			"query-series":  true,
+			"index-doc":     true,
+			"delete-text":   true,
+			"search-text":   true,
+			"insert-sparse": true,
+			"search-sparse": true,
 		}
 		if commandsRequiringSpace[strings.ToLower(parts[0])] && space == "" {
 			fmt.Println("No space selected. Use 'USE <space>' first.")
@@ -369,13 +403,18 @@ func connectToServer(port string) {
 			query = models.Query{Type: models.TypeGetUser, Data: parts[1]}
 		case "create-space":
 			if len(parts) < 2 {
-				fmt.Println("Usage: create-space <name> [--engine key-value|vector] [--dimension N] [--index-type TYPE] [--metric METRIC] [--enable-wal] [--disable-wal]")
+				fmt.Println("Usage: create-space <name> [--engine key-value|document|timeseries|text|vector|sparse-vector|binary-vector] [--dimension N] [--index-type TYPE] [--metric METRIC] [--retention DURATION] [--partition DURATION] [--analyzer standard|simple|whitespace|english] [--search-params nprobe=N,efSearch=N] [--encoding float32|float16|int8] [--enable-wal] [--disable-wal]")
 				continue
 			}
 			engineType := "key-value"
 			dimension := 0
 			indexType := "Flat"
-			metric := "L2"
+			metric := ""
+			retention := ""
+			partitionSize := ""
+			analyzer := ""
+			var searchParams map[string]float64
+			encoding := ""
 			enableWAL := false // Will be set based on engine type
 			walExplicitlySet := false
 			for i := 2; i < len(parts); i++ {
@@ -395,6 +434,26 @@ func connectToServer(port string) {
 					metricStr := parts[i+1]
 					metric = metricStr
 					i++
+				} else if parts[i] == "--retention" && i+1 < len(parts) {
+					retention = parts[i+1]
+					i++
+				} else if parts[i] == "--partition" && i+1 < len(parts) {
+					partitionSize = parts[i+1]
+					i++
+				} else if parts[i] == "--analyzer" && i+1 < len(parts) {
+					analyzer = parts[i+1]
+					i++
+				} else if parts[i] == "--search-params" && i+1 < len(parts) {
+					params, err := storage.ParseSearchParams(parts[i+1])
+					if err != nil {
+						fmt.Println("Invalid search parameters:", err)
+					} else {
+						searchParams = params
+					}
+					i++
+				} else if parts[i] == "--encoding" && i+1 < len(parts) {
+					encoding = parts[i+1]
+					i++
 				} else if parts[i] == "--enable-wal" {
 					enableWAL = true
 					walExplicitlySet = true
@@ -406,14 +465,22 @@ func connectToServer(port string) {
 
 			// Set default WAL based on engine type if not explicitly set
 			if !walExplicitlySet {
-				enableWAL = (engineType == "key-value") // Default to WAL enabled for key-value, disabled for vector
+				enableWAL = (engineType == "key-value" || engineType == "document" || engineType == "text" || engineType == "sparse-vector" || engineType == "timeseries") // Default to WAL enabled for key-value, document, text, sparse-vector and timeseries, disabled for vector
 			}
 
+			// Binary vectors are always compared by Hamming distance
+			if metric == "" && engineType != "binary-vector" {
+				metric = "L2"
+			}
 			if engineType == "vector" && dimension <= 0 {
 				fmt.Println("For vector engine, you must specify --dimension <N> (e.g., 128)")
 				continue
 			}
-			query = models.Query{Type: models.TypeCreateSpace, Space: parts[1], User: username, EngineType: engineType, Dimension: dimension, IndexType: indexType, Metric: metric, EnableWAL: enableWAL}
+			if engineType == "binary-vector" && (dimension <= 0 || dimension%8 != 0) {
+				fmt.Println("For binary-vector engine, you must specify --dimension <bits>, a multiple of 8 (e.g., 256)")
+				continue
+			}
+			query = models.Query{Type: models.TypeCreateSpace, Space: parts[1], User: username, EngineType: engineType, Dimension: dimension, IndexType: indexType, Metric: metric, EnableWAL: enableWAL, Retention: retention, PartitionSize: partitionSize, Analyzer: analyzer, SearchParams: searchParams, VectorEncoding: encoding}
 		case "delete-space":
 			if len(parts) < 2 {
 				fmt.Println("Usage: delete-space <name>")
@@ -432,23 +499,344 @@ func connectToServer(port string) {
 			query = models.Query{Type: models.TypeGet, Key: parts[1], Space: space, User: username}
 		case "delete":
 			query = models.Query{Type: models.TypeDelete, Key: parts[1], Space: space, User: username}
-		case "insert-vector":
+		case "create-index":
+			if len(parts) < 2 {
+				fmt.Println("Usage: create-index <json_path>")
+				continue
+			}
+			query = models.Query{Type: models.TypeCreateIndex, Path: parts[1], Space: space, User: username}
+		case "find":
+			if len(parts) < 3 {
+				fmt.Println("Usage: find <json_path> <value> | find <json_path> [--min VALUE] [--max VALUE]")
+				continue
+			}
+			query = models.Query{Type: models.TypeFind, Path: parts[1], Space: space, User: username}
+			if !strings.HasPrefix(parts[2], "--") {
+				query.Value = parts[2]
+				query.Equal = true
+			}
+			for i := 2; i < len(parts); i++ {
+				if parts[i] == "--min" && i+1 < len(parts) {
+					query.Min = parts[i+1]
+					i++
+				} else if parts[i] == "--max" && i+1 < len(parts) {
+					query.Max = parts[i+1]
+					i++
+				}
+			}
+		case "insert-doc":
+			doc := strings.TrimSpace(line[len(parts[0]):])
+			if doc == "" {
+				fmt.Println("Usage: insert-doc <json-document>")
+				continue
+			}
+			query = models.Query{Type: models.TypeInsertDocument, Value: doc, Space: space, User: username}
+		case "get-doc":
+			if len(parts) < 2 {
+				fmt.Println("Usage: get-doc <id> [field ...]")
+				continue
+			}
+			query = models.Query{Type: models.TypeGetDocument, Key: parts[1], Fields: parts[2:], Space: space, User: username}
+		case "update-doc":
+			if len(parts) < 3 {
+				fmt.Println("Usage: update-doc <id> <json-merge-patch>")
+				continue
+			}
+			patch := strings.TrimSpace(strings.TrimSpace(line[len(parts[0]):])[len(parts[1]):])
+			query = models.Query{Type: models.TypeUpdateDocument, Key: parts[1], Value: patch, Space: space, User: username}
+		case "delete-doc":
+			if len(parts) < 2 {
+				fmt.Println("Usage: delete-doc <id>")
+				continue
+			}
+			query = models.Query{Type: models.TypeDeleteDocument, Key: parts[1], Space: space, User: username}
+		case "find-docs":
+			// Everything after the command is the filter expression
+			query = models.Query{Type: models.TypeFindDocuments, Filter: strings.TrimSpace(line[len(parts[0]):]), Space: space, User: username}
+		case "insert-point":
+			if len(parts) < 3 {
+				fmt.Println("Usage: insert-point <series> <value> [--ts UNIX_MS] [--tag key=value ...]")
+				continue
+			}
+			query = models.Query{Type: models.TypeInsertPoint, Key: parts[1], Value: parts[2], Space: space, User: username}
+			tags, rest := parseTagFlags(parts[3:])
+			query.Tags = tags
+			for i := 0; i < len(rest); i++ {
+				if rest[i] == "--ts" && i+1 < len(rest) {
+					ts, err := strconv.ParseInt(rest[i+1], 10, 64)
+					if err == nil {
+						query.Timestamp = ts
+					}
+					i++
+				}
+			}
+		case "query-series":
+			if len(parts) < 4 {
+				fmt.Println("Usage: query-series <series> <start_ms> <end_ms> [--step MS] [--agg min|max|avg|sum|count] [--tag key=value ...]")
+				continue
+			}
+			start, err1 := strconv.ParseInt(parts[2], 10, 64)
+			end, err2 := strconv.ParseInt(parts[3], 10, 64)
+			if err1 != nil || err2 != nil {
+				fmt.Println("Invalid start or end timestamp")
+				continue
+			}
+			query = models.Query{Type: models.TypeQuerySeries, Key: parts[1], Start: start, End: end, Space: space, User: username}
+			tags, rest := parseTagFlags(parts[4:])
+			query.Tags = tags
+			for i := 0; i < len(rest); i++ {
+				if rest[i] == "--step" && i+1 < len(rest) {
+					step, err := strconv.ParseInt(rest[i+1], 10, 64)
+					if err == nil {
+						query.Step = step
+					}
+					i++
+				} else if rest[i] == "--agg" && i+1 < len(rest) {
+					query.Aggregation = rest[i+1]
+					i++
+				}
+			}
+		case "index-doc":
+			if len(parts) < 3 {
+				fmt.Println("Usage: index-doc <id> <text>")
+				continue
+			}
+			text := strings.TrimSpace(strings.TrimSpace(line[len(parts[0]):])[len(parts[1]):])
+			query = models.Query{Type: models.TypeIndexDoc, Key: parts[1], Value: text, Space: space, User: username}
+		case "delete-text":
+			if len(parts) < 2 {
+				fmt.Println("Usage: delete-text <id>")
+				continue
+			}
+			query = models.Query{Type: models.TypeDeleteDoc, Key: parts[1], Space: space, User: username}
+		case "search-text":
+			if len(parts) < 2 {
+				fmt.Println("Usage: search-text [--k N] <query>")
+				continue
+			}
+			query = models.Query{Type: models.TypeSearchText, Space: space, User: username}
+			rest := strings.TrimSpace(line[len(parts[0]):])
+			if parts[1] == "--k" && len(parts) > 3 {
+				k, err := strconv.Atoi(parts[2])
+				if err != nil || k <= 0 {
+					fmt.Println("Invalid value for k")
+					continue
+				}
+				query.Limit = k
+				rest = strings.TrimSpace(strings.TrimSpace(rest[len(parts[1]):])[len(parts[2]):])
+			}
+			query.Value = rest
+		case "insert-sparse":
+			if len(parts) != 3 {
+				fmt.Println("Usage: insert-sparse <id> <index:weight,...>")
+				continue
+			}
+			sv, err := parseSparseVector(parts[2])
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			query = models.Query{Type: models.TypeInsertSparse, Key: parts[1], Sparse: sv, Space: space, User: username}
+		case "search-sparse":
+			if len(parts) != 3 {
+				fmt.Println("Usage: search-sparse <index:weight,...> <k>")
+				continue
+			}
+			sv, err := parseSparseVector(parts[1])
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			k, err := strconv.Atoi(parts[2])
+			if err != nil || k <= 0 {
+				fmt.Println("Invalid value for k")
+				continue
+			}
+			query = models.Query{Type: models.TypeSearchSparse, Sparse: sv, Limit: k, Space: space, User: username}
+		case "insert-vector", "upsert-vector", "insert-vector-if-absent", "update-vector":
 			if space == "" {
 				fmt.Println("No space selected. Use 'USE <space>' first.")
 				continue
 			}
 			if len(parts) < 3 {
-				fmt.Println("Usage: insert-vector <id> <comma-separated-floats>")
+				fmt.Printf("Usage: %s <id> <comma-separated-floats|hex|base64> [--text <text>] [--payload <json>] [--parent <document-id>]\n", strings.ToLower(parts[0]))
 				continue
 			}
-			query = models.Query{Type: models.TypeInsertVector, Key: parts[1], Value: parts[2], Space: space, User: username}
+			// insert-vector replaces an existing vector, like upsert-vector
+			queryType := models.TypeInsertVector
+			switch strings.ToLower(parts[0]) {
+			case "upsert-vector":
+				queryType = models.TypeUpsertVector
+			case "insert-vector-if-absent":
+				queryType = models.TypeInsertVectorIfAbsent
+			case "update-vector":
+				queryType = models.TypeUpdateVector
+			}
+			query = models.Query{Type: queryType, Key: parts[1], Value: parts[2], Space: space, User: username}
+			opts := parseTextFlags(line, "--text", "--payload", "--parent")
+			query.Text = opts["--text"]
+			query.Payload = opts["--payload"]
+			query.Parent = opts["--parent"]
+		case "import-vectors":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 2 || strings.HasPrefix(parts[1], "--") {
+				fmt.Println("Usage: import-vectors <file.fvecs|.bvecs|.npy|.jsonl> [--format fvecs|bvecs|npy|jsonl] [--batch N] [--first-id N] [--server]")
+				continue
+			}
+			opts := parseTextFlags(line, "--format", "--batch", "--first-id", "--server")
+			batchSize := vectorio.DefaultBatchSize
+			if b, ok := opts["--batch"]; ok {
+				n, err := strconv.Atoi(b)
+				if err != nil || n <= 0 {
+					fmt.Println("Invalid value for --batch")
+					continue
+				}
+				batchSize = n
+			}
+			var firstID int64
+			if f, ok := opts["--first-id"]; ok {
+				n, err := strconv.ParseInt(f, 10, 64)
+				if err != nil {
+					fmt.Println("Invalid value for --first-id")
+					continue
+				}
+				firstID = n
+			}
+			if _, onServer := opts["--server"]; !onServer {
+				if err := importVectors(conn, serverReader, parts[1], opts["--format"], firstID, batchSize, space, username); err != nil {
+					fmt.Println("Import failed:", err)
+				}
+				continue
+			}
+			// The server reads the file from its own filesystem
+			query = models.Query{Type: models.TypeImportVectors, Path: parts[1], Format: opts["--format"], FirstID: firstID, BatchSize: batchSize, Space: space, User: username}
+		case "list-vector-ids":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			opts := parseTextFlags(line, "--limit", "--cursor", "--min", "--max")
+			query = models.Query{Type: models.TypeListVectorIDs, Cursor: opts["--cursor"], Min: opts["--min"], Max: opts["--max"], Space: space, User: username}
+			if l, ok := opts["--limit"]; ok {
+				n, err := strconv.Atoi(l)
+				if err != nil || n <= 0 {
+					fmt.Println("Usage: list-vector-ids [--limit N] [--cursor CURSOR] [--min ID] [--max ID]")
+					continue
+				}
+				query.Limit = n
+			}
+		case "export-vectors":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 2 || strings.HasPrefix(parts[1], "--") {
+				fmt.Println("Usage: export-vectors <file.jsonl> [--batch N] [--min ID] [--max ID]")
+				continue
+			}
+			opts := parseTextFlags(line, "--batch", "--min", "--max")
+			export := models.Query{Type: models.TypeExportVectors, Min: opts["--min"], Max: opts["--max"], Space: space, User: username}
+			if b, ok := opts["--batch"]; ok {
+				n, err := strconv.Atoi(b)
+				if err != nil || n <= 0 {
+					fmt.Println("Invalid value for --batch")
+					continue
+				}
+				export.Limit = n
+			}
+			if err := exportVectors(conn, serverReader, parts[1], export); err != nil {
+				fmt.Println("Export failed:", err)
+			}
+			continue
+		case "insert-vectors":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 2 {
+				fmt.Println(`Usage: insert-vectors [{"id":1,"vector":[0.1,0.2]}, {"key":"doc-a","vector":[0.3,0.4]}, ...]`)
+				continue
+			}
+			query = models.Query{Type: models.TypeInsertVectors, Space: space, User: username}
+			if err := json.Unmarshal([]byte(strings.TrimSpace(line[len(parts[0]):])), &query.Vectors); err != nil {
+				fmt.Println("Invalid vectors JSON:", err)
+				continue
+			}
+		case "search-topk-batch":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 3 {
+				fmt.Println("Usage: search-topk-batch <k> <comma-separated-floats>... [--params nprobe=N,efSearch=N] [--rerank N] [--diversity LAMBDA] [--filter <json>]")
+				continue
+			}
+			k, err := strconv.Atoi(parts[1])
+			if err != nil || k <= 0 {
+				fmt.Println("Invalid value for k")
+				continue
+			}
+			query = models.Query{Type: models.TypeSearchTopKBatch, Space: space, User: username, Dimension: k}
+			if !parseSearchFlags(line, &query) {
+				continue
+			}
+			valid := true
+			for _, p := range parts[2:] {
+				if strings.HasPrefix(p, "--") {
+					break
+				}
+				var vec []float32
+				for _, f := range strings.Split(p, ",") {
+					v, err := strconv.ParseFloat(strings.TrimSpace(f), 32)
+					if err != nil {
+						valid = false
+						break
+					}
+					vec = append(vec, float32(v))
+				}
+				query.Queries = append(query.Queries, vec)
+			}
+			if !valid {
+				fmt.Println("Invalid query vector")
+				continue
+			}
+		case "hybrid-search":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 4 {
+				fmt.Println("Usage: hybrid-search <comma-separated-floats> <k> [--fusion rrf|weighted] [--alpha A] <query text>")
+				continue
+			}
+			k, err := strconv.Atoi(parts[2])
+			if err != nil || k <= 0 {
+				fmt.Println("Invalid value for k")
+				continue
+			}
+			query = models.Query{Type: models.TypeHybridSearch, Value: parts[1], Space: space, User: username, Dimension: k}
+			i := 3
+			for ; i+1 < len(parts) && strings.HasPrefix(parts[i], "--"); i += 2 {
+				if parts[i] == "--fusion" {
+					query.Fusion = parts[i+1]
+				} else if parts[i] == "--alpha" {
+					alpha, err := strconv.ParseFloat(parts[i+1], 64)
+					if err == nil {
+						query.Alpha = &alpha
+					}
+				}
+			}
+			query.Text = strings.Join(parts[i:], " ")
 		case "search-topk":
 			if space == "" {
 				fmt.Println("No space selected. Use 'USE <space>' first.")
 				continue
 			}
 			if len(parts) < 3 {
-				fmt.Println("Usage: search-topk <comma-separated-floats> <k>")
+				fmt.Println("Usage: search-topk <comma-separated-floats|hex|base64> <k> [--params nprobe=N,efSearch=N] [--rerank N] [--diversity LAMBDA] [--group-by] [--filter <json>]")
 				continue
 			}
 			k, err := strconv.Atoi(parts[2])
@@ -457,6 +845,39 @@ func connectToServer(port string) {
 				continue
 			}
 			query = models.Query{Type: models.TypeSearchTopK, Value: parts[1], Space: space, User: username, Dimension: k}
+			if !parseSearchFlags(line, &query) {
+				continue
+			}
+			_, query.GroupBy = parseTextFlags(line, "--group-by", "--params", "--rerank", "--diversity", "--filter")["--group-by"]
+		case "search-by-id":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 3 {
+				fmt.Println("Usage: search-by-id <comma-separated-ids> <k> [--negative <comma-separated-ids>] [--params nprobe=N,efSearch=N] [--filter <json>]")
+				continue
+			}
+			positive, err := parseIDList(parts[1])
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			k, err := strconv.Atoi(parts[2])
+			if err != nil || k <= 0 {
+				fmt.Println("Invalid value for k")
+				continue
+			}
+			query = models.Query{Type: models.TypeSearchByID, Positive: positive, Space: space, User: username, Dimension: k}
+			if neg := parseTextFlags(line, "--negative", "--params", "--filter", "--rerank", "--diversity")["--negative"]; neg != "" {
+				if query.Negative, err = parseIDList(neg); err != nil {
+					fmt.Println(err)
+					continue
+				}
+			}
+			if !parseSearchFlags(line, &query) {
+				continue
+			}
 		case "get-vector":
 			if space == "" {
 				fmt.Println("No space selected. Use 'USE <space>' first.")
@@ -473,7 +894,7 @@ func connectToServer(port string) {
 				continue
 			}
 			if len(parts) < 3 {
-				fmt.Println("Usage: range-search <comma-separated-floats> <radius>")
+				fmt.Println("Usage: range-search <comma-separated-floats|hex|base64> <radius> [--params nprobe=N,efSearch=N] [--filter <json>]")
 				continue
 			}
 			radius, err := strconv.ParseFloat(parts[2], 32)
@@ -482,6 +903,71 @@ func connectToServer(port string) {
 				continue
 			}
 			query = models.Query{Type: models.TypeRangeSearch, Value: parts[1], Space: space, User: username, Radius: float32(radius)}
+			if !parseSearchFlags(line, &query) {
+				continue
+			}
+		case "compact-space":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			query = models.Query{Type: models.TypeCompactSpace, Space: space, User: username}
+		case "migrate-index":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			flags := parseTextFlags(line, "--index-type", "--metric")
+			if flags["--index-type"] == "" && flags["--metric"] == "" {
+				fmt.Println("Usage: migrate-index [--index-type TYPE] [--metric METRIC]")
+				continue
+			}
+			query = models.Query{Type: models.TypeMigrateIndex, Space: space, User: username, IndexType: flags["--index-type"], Metric: flags["--metric"]}
+		case "rebuild-index":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			query = models.Query{Type: models.TypeRebuildIndex, Space: space, User: username}
+			if len(parts) > 2 && parts[1] == "--sample" {
+				n, err := strconv.Atoi(parts[2])
+				if err != nil || n <= 0 {
+					fmt.Println("Usage: rebuild-index [--sample N]")
+					continue
+				}
+				query.Limit = n
+			}
+		case "update-payload":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 3 {
+				fmt.Println("Usage: update-payload <id> <json-merge-patch>")
+				continue
+			}
+			patch := strings.TrimSpace(strings.TrimSpace(line[len(parts[0]):])[len(parts[1]):])
+			query = models.Query{Type: models.TypeUpdatePayload, Key: parts[1], Payload: patch, Space: space, User: username}
+		case "get-payload":
+			if space == "" {
+				fmt.Println("No space selected. Use 'USE <space>' first.")
+				continue
+			}
+			if len(parts) < 2 {
+				fmt.Println("Usage: get-payload <id>")
+				continue
+			}
+			query = models.Query{Type: models.TypeGetPayload, Key: parts[1], Space: space, User: username}
+		case "describe-space":
+			name := space
+			if len(parts) > 1 {
+				name = parts[1]
+			}
+			if name == "" {
+				fmt.Println("Usage: describe-space [name]")
+				continue
+			}
+			query = models.Query{Type: models.TypeDescribeSpace, Space: name, User: username}
 		default:
 			fmt.Println("Unknown command:", parts[0])
 			continue
@@ -530,6 +1016,381 @@ func printResponse(resp string) {
 	}
 }
 
+// parseTagFlags extracts "--tag key=value" pairs and returns the remaining arguments.
+func parseTagFlags(args []string) (map[string]string, []string) {
+	tags := make(map[string]string)
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--tag" && i+1 < len(args) {
+			if kv := strings.SplitN(args[i+1], "=", 2); len(kv) == 2 {
+				tags[kv[0]] = kv[1]
+			}
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	return tags, rest
+}
+
+// parseTextFlags extracts flags whose value is free text (JSON, sentences):
+// each value runs from its flag to the next of the given flags or the end of
+// the line.
+func parseTextFlags(line string, names ...string) map[string]string {
+	type span struct {
+		name  string
+		start int
+	}
+	var spans []span
+	for _, name := range names {
+		// Flags may also end the line
+		if i := strings.Index(line+" ", " "+name+" "); i >= 0 {
+			spans = append(spans, span{name, i + 1})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	values := make(map[string]string)
+	for i, sp := range spans {
+		end := len(line)
+		if i+1 < len(spans) {
+			end = spans[i+1].start
+		}
+		values[sp.name] = strings.TrimSpace(line[sp.start+len(sp.name) : end])
+	}
+	return values
+}
+
+// parseIDList parses comma-separated vector IDs.
+func parseIDList(s string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector ID '%s'", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// importVectors streams a local vector file into a space as INSERT_VECTORS
+// batches, printing progress and the rejected rows.
+func importVectors(conn net.Conn, serverReader *bufio.Reader, path, format string, firstID int64, batchSize int, space, username string) error {
+	// Check rows against the space's dimension rather than the file's
+	// first row, so that rows of the wrong size are rejected one by one
+	dim, metric, err := describeVectorSpace(conn, serverReader, space, username)
+	if err != nil {
+		return err
+	}
+	// Rows the server would refuse are rejected here, so that they do not
+	// fail the batch they are sent in
+	check := func(rec vectorio.Record) error {
+		if metric != "Cosine" {
+			return nil
+		}
+		for _, x := range rec.Vector {
+			if x != 0 {
+				return nil
+			}
+		}
+		return errors.New("cosine metric requires a non-zero, finite vector")
+	}
+	r, err := vectorio.Open(path, format, firstID)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	start := time.Now()
+	insert := func(batch []vectorio.Record) error {
+		query := models.Query{Type: models.TypeInsertVectors, Space: space, User: username}
+		for _, rec := range batch {
+			query.Vectors = append(query.Vectors, models.VectorRecord{ID: rec.ID, Key: rec.Key, Vector: rec.Vector, Payload: rec.Payload, Parent: rec.Parent})
+		}
+		_, err := sendQuery(conn, serverReader, query)
+		return err
+	}
+	progress, err := vectorio.Import(r, dim, batchSize, check, insert, func(p vectorio.Progress) {
+		fmt.Printf("\rImported %d vectors, %d rejected", p.Imported, p.Rejected)
+	})
+	fmt.Println()
+	for _, msg := range progress.Errors {
+		fmt.Println("  rejected", msg)
+	}
+	if more := progress.Rejected - len(progress.Errors); more > 0 {
+		fmt.Printf("  ... and %d more rejected rows\n", more)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d of %d rows in %v (%d rejected)\n", progress.Imported, progress.Rows, time.Since(start).Round(time.Millisecond), progress.Rejected)
+	return nil
+}
+
+// describeVectorSpace returns the dimension and metric of a vector space
+// from DESCRIBE_SPACE.
+func describeVectorSpace(conn net.Conn, serverReader *bufio.Reader, space, username string) (int, string, error) {
+	msg, err := sendQuery(conn, serverReader, models.Query{Type: models.TypeDescribeSpace, Space: space, User: username})
+	if err != nil {
+		return 0, "", err
+	}
+	var desc struct {
+		Space struct {
+			EngineType string `json:"engine_type"`
+			Dimension  int    `json:"dimension"`
+			Metric     string `json:"metric"`
+		} `json:"space"`
+	}
+	if err := json.Unmarshal([]byte(msg), &desc); err != nil {
+		return 0, "", fmt.Errorf("invalid space description: %w", err)
+	}
+	if desc.Space.EngineType != "vector" || desc.Space.Dimension <= 0 {
+		return 0, "", fmt.Errorf("space %s is not a vector space", space)
+	}
+	return desc.Space.Dimension, desc.Space.Metric, nil
+}
+
+// sendQuery sends a query and returns the message of an OK response.
+func sendQuery(conn net.Conn, serverReader *bufio.Reader, query models.Query) (string, error) {
+	data, _ := json.Marshal(query)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return "", err
+	}
+	resp, err := serverReader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	var parsed struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(resp), &parsed); err != nil {
+		return "", fmt.Errorf("invalid server response: %s", strings.TrimSpace(resp))
+	}
+	if !strings.EqualFold(parsed.Status, "OK") {
+		return "", fmt.Errorf("server: %s", parsed.Message)
+	}
+	return parsed.Message, nil
+}
+
+// exportVectors pages through EXPORT_VECTORS and writes the vectors to a
+// JSON lines file that import-vectors reads back: vectors with a string key
+// get it as their id.
+func exportVectors(conn net.Conn, serverReader *bufio.Reader, path string, query models.Query) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	start := time.Now()
+	exported := 0
+	for {
+		msg, err := sendQuery(conn, serverReader, query)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Vectors    []storage.ExportedVector `json:"vectors"`
+			NextCursor string                   `json:"next_cursor"`
+		}
+		if err := json.Unmarshal([]byte(msg), &page); err != nil {
+			return fmt.Errorf("invalid export page: %w", err)
+		}
+		for _, rec := range page.Vectors {
+			row := vectorio.Record{ID: rec.ID, Key: rec.Key, Vector: rec.Vector, Payload: string(rec.Payload), Parent: rec.Parent}
+			if err := vectorio.WriteJSONL(w, row); err != nil {
+				return err
+			}
+		}
+		exported += len(page.Vectors)
+		fmt.Printf("\rExported %d vectors", exported)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	fmt.Println()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d vectors to %s in %v\n", exported, path, time.Since(start).Round(time.Millisecond))
+	return f.Close()
+}
+
+// evalRecall logs in, asks the server to evaluate the recall of a vector
+// space's index and prints one row per search parameter setting.
+func evalRecall(port, space, args string) {
+	query := models.Query{Type: models.TypeEvalRecall, Space: space, Dimension: 10}
+	flags := parseTextFlags(" "+args, "--k", "--sample", "--queries", "--settings")
+	if v, ok := flags["--k"]; ok {
+		k, err := strconv.Atoi(v)
+		if err != nil || k <= 0 {
+			fmt.Println("Invalid value for --k")
+			return
+		}
+		query.Dimension = k
+	}
+	if v, ok := flags["--sample"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			fmt.Println("Invalid value for --sample")
+			return
+		}
+		query.Limit = n
+	}
+	if v, ok := flags["--settings"]; ok {
+		for _, setting := range strings.Split(strings.Trim(v, `"'`), ";") {
+			params, err := storage.ParseSearchParams(strings.TrimSpace(setting))
+			if err != nil {
+				fmt.Println("Invalid search parameters:", err)
+				return
+			}
+			query.SearchSettings = append(query.SearchSettings, params)
+		}
+	}
+	if path, ok := flags["--queries"]; ok {
+		queries, err := readQueryFile(path)
+		if err != nil {
+			fmt.Println("Failed to read queries:", err)
+			return
+		}
+		query.Queries = queries
+	}
+
+	conn, err := net.Dial("tcp", "localhost:"+port)
+	if err != nil {
+		fmt.Printf("Failed to connect to server: %v\n", err)
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(os.Stdin)
+	serverReader := bufio.NewReader(conn)
+
+	username := readLine("Username: ", reader)
+	password := readLine("Password: ", reader)
+	login, _ := json.Marshal(models.LoginRequest{Username: username, Password: password})
+	conn.Write(append(login, '\n'))
+	resp, err := serverReader.ReadString('\n')
+	if err != nil || !strings.Contains(resp, `"status":"OK"`) {
+		fmt.Println("Authentication failed. Server response:", strings.TrimSpace(resp))
+		return
+	}
+
+	query.User = username
+	data, _ := json.Marshal(query)
+	conn.Write(append(data, '\n'))
+	fmt.Println("Evaluating recall, this scans every stored vector...")
+	resp, err = serverReader.ReadString('\n')
+	if err != nil {
+		fmt.Println("Failed to read server response:", err)
+		return
+	}
+	var parsed struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(resp), &parsed); err != nil || !strings.EqualFold(parsed.Status, "OK") {
+		printResponse(resp)
+		return
+	}
+	var report storage.RecallReport
+	if err := json.Unmarshal([]byte(parsed.Message), &report); err != nil {
+		printResponse(resp)
+		return
+	}
+	fmt.Printf("Index %s, %d vectors, %d queries, ground truth in %.1f ms\n", report.IndexType, report.Vectors, report.Queries, report.GroundTruthMs)
+	fmt.Printf("%-24s %10s %10s %10s %10s %10s %10s\n", "params", fmt.Sprintf("recall@%d", report.K), "mean ms", "p50 ms", "p95 ms", "p99 ms", "qps")
+	for _, r := range report.Results {
+		var names []string
+		for name, v := range r.Params {
+			names = append(names, fmt.Sprintf("%s=%g", name, v))
+		}
+		sort.Strings(names)
+		label := strings.Join(names, ",")
+		if label == "" {
+			label = "default"
+		}
+		fmt.Printf("%-24s %10.4f %10.3f %10.3f %10.3f %10.3f %10.1f\n", label, r.Recall, r.MeanMs, r.P50Ms, r.P95Ms, r.P99Ms, r.QPS)
+	}
+}
+
+// readQueryFile reads the vectors of a local vector file as a query set.
+func readQueryFile(path string) ([][]float32, error) {
+	r, err := vectorio.Open(path, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var queries [][]float32
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return queries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, rec.Vector)
+	}
+}
+
+// parseSparseVector parses a sparse vector written as index:weight pairs,
+// e.g. "12:0.5,407:1.25".
+func parseSparseVector(s string) (*models.SparseVector, error) {
+	sv := &models.SparseVector{}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid sparse entry '%s' (expected index:weight)", part)
+		}
+		idx, err := strconv.ParseUint(kv[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sparse index '%s'", kv[0])
+		}
+		w, err := strconv.ParseFloat(kv[1], 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sparse weight '%s'", kv[1])
+		}
+		sv.Indices = append(sv.Indices, uint32(idx))
+		sv.Values = append(sv.Values, float32(w))
+	}
+	return sv, nil
+}
+
+// parseSearchFlags reads the --params, --rerank, --diversity and --filter
+// options of the vector search commands into query, reporting invalid values.
+func parseSearchFlags(line string, query *models.Query) bool {
+	flags := parseTextFlags(line, "--params", "--filter", "--rerank", "--diversity", "--group-by")
+	query.Filter = flags["--filter"]
+	if r, ok := flags["--rerank"]; ok {
+		n, err := strconv.Atoi(r)
+		if err != nil || n <= 0 {
+			fmt.Println("Invalid value for --rerank")
+			return false
+		}
+		query.Rerank = n
+	}
+	if d, ok := flags["--diversity"]; ok {
+		lambda, err := strconv.ParseFloat(d, 64)
+		if err != nil || lambda < 0 || lambda > 1 {
+			fmt.Println("Invalid value for --diversity (expected a lambda between 0 and 1)")
+			return false
+		}
+		query.Diversity = &lambda
+	}
+	if p, ok := flags["--params"]; ok {
+		params, err := storage.ParseSearchParams(p)
+		if err != nil {
+			fmt.Println("Invalid search parameters:", err)
+			return false
+		}
+		query.SearchParams = params
+	}
+	return true
+}
+
 func readLine(prompt string, reader *bufio.Reader) string {
 	fmt.Print(prompt)
 	line, _ := reader.ReadString('\n')